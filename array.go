@@ -0,0 +1,219 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Array is an array for TypeArray values. Elem is the type of each
+// element, such as TypeInt64. Set Elem before scanning into an Array.
+//
+// A nil item is null and an Array item is a sub-array of a
+// multi-dimensional array. Nil Items is a null array.
+//
+// The text form is "{1,2,NULL,\"a b\"}". Elements that are empty or
+// contain white space or any of `{}",\` are quoted.
+//
+// The binary form is specific to rdb and is not a database wire format.
+// It is the element type and the number of items as big-endian uint32
+// values, followed by each item as a big-endian
+// int32 length and the item bytes. A null item has a length of -1.
+// The binary form only supports one dimension.
+type Array struct {
+	Elem  Type
+	Items []interface{}
+}
+
+// NewArray returns an array of elem type with the items.
+func NewArray(elem Type, items ...interface{}) Array {
+	if items == nil {
+		items = []interface{}{}
+	}
+	return Array{Elem: elem, Items: items}
+}
+
+func (a Array) writeText(buf *bytes.Buffer) error {
+	buf.WriteByte('{')
+	for i, item := range a.Items {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		switch v := item.(type) {
+		case nil:
+			buf.WriteString("NULL")
+		case Array:
+			if err := v.writeText(buf); err != nil {
+				return err
+			}
+		default:
+			s, err := formatElem(v)
+			if err != nil {
+				return err
+			}
+			if strings.EqualFold(s, "NULL") {
+				s = `"` + s + `"`
+			} else {
+				s = quoteElem(s, `{}",\`)
+			}
+			buf.WriteString(s)
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a Array) MarshalText() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := a.writeText(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Elements are parsed as the Elem type. A leading dimension
+// decoration, such as "[1:3]=", is ignored.
+func (a *Array) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if strings.HasPrefix(s, "[") {
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	p := &elemParser{s: s}
+	items, err := p.array(a.Elem)
+	if err == nil {
+		p.space()
+		if p.i < len(p.s) {
+			err = fmt.Errorf("Unexpected text after array")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid array %q: %v", text, err)
+	}
+	a.Items = items
+	return nil
+}
+
+// array reads a brace enclosed list of elements.
+func (p *elemParser) array(t Type) ([]interface{}, error) {
+	p.space()
+	if p.peek() != '{' {
+		return nil, fmt.Errorf("Missing opening brace")
+	}
+	p.i++
+	items := []interface{}{}
+	p.space()
+	if p.peek() == '}' {
+		p.i++
+		return items, nil
+	}
+	for {
+		p.space()
+		if p.peek() == '{' {
+			sub, err := p.array(t)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, Array{Elem: t, Items: sub})
+		} else {
+			v, err := p.elem(t, ",}", true)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		p.space()
+		switch p.peek() {
+		case ',':
+			p.i++
+		case '}':
+			p.i++
+			return items, nil
+		default:
+			return nil, fmt.Errorf("Missing closing brace")
+		}
+	}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (a Array) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 8, 8+8*len(a.Items))
+	binary.BigEndian.PutUint32(b[0:], uint32(a.Elem))
+	binary.BigEndian.PutUint32(b[4:], uint32(len(a.Items)))
+	for _, item := range a.Items {
+		if _, is := item.(Array); is {
+			return nil, fmt.Errorf("Binary array encoding supports one dimension")
+		}
+		var err error
+		if b, err = appendItem(b, a.Elem, item); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+// The Elem type is read from the data.
+func (a *Array) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return fmt.Errorf("Invalid binary array length %d", len(data))
+	}
+	elem := Type(binary.BigEndian.Uint32(data[0:]))
+	n := binary.BigEndian.Uint32(data[4:])
+	data = data[8:]
+	if uint64(n)*4 > uint64(len(data)) {
+		return fmt.Errorf("Invalid binary array item count %d", n)
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		item, rest, err := readItem(elem, data)
+		if err != nil {
+			return err
+		}
+		items[i], data = item, rest
+	}
+	if len(data) != 0 {
+		return fmt.Errorf("Invalid binary array, %d bytes after last item", len(data))
+	}
+	a.Elem, a.Items = elem, items
+	return nil
+}
+
+// Scan implements sql.Scanner. Text is parsed as the Elem type.
+// A null value sets Items to nil.
+func (a *Array) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		a.Items = nil
+		return nil
+	case Array:
+		*a = v
+		return nil
+	case []byte:
+		return a.UnmarshalText(v)
+	case string:
+		return a.UnmarshalText([]byte(v))
+	}
+	return fmt.Errorf("Cannot convert %T to rdb.Array", value)
+}
+
+// Value implements driver.Valuer. The array is sent as text.
+func (a Array) Value() (driver.Value, error) {
+	if a.Items == nil {
+		return nil, nil
+	}
+	b, err := a.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
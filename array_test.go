@@ -0,0 +1,149 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+)
+
+func TestArrayText(t *testing.T) {
+	list := []struct {
+		array rdb.Array
+		text  string
+	}{
+		{rdb.NewArray(rdb.TypeInt64, int64(1), nil, int64(-3)), `{1,NULL,-3}`},
+		{rdb.NewArray(rdb.TypeInt64), `{}`},
+		{rdb.NewArray(rdb.TypeText, "a b", "null", "", `q"\`, "x"), `{"a b","null","","q\"\\",x}`},
+		{rdb.NewArray(rdb.TypeText, "{a}", "a,b"), `{"{a}","a,b"}`},
+		{rdb.NewArray(rdb.TypeInt32, rdb.NewArray(rdb.TypeInt32, int64(1), int64(2)), rdb.NewArray(rdb.TypeInt32, int64(3), int64(4))), `{{1,2},{3,4}}`},
+		{rdb.NewArray(rdb.TypeFloat64, 1.5, -2.0), `{1.5,-2}`},
+		{rdb.NewArray(rdb.TypeBool, true, false), `{true,false}`},
+		{rdb.NewArray(rdb.TypeBinary, []byte{1, 0xab}), `{"\\x01ab"}`},
+		{rdb.NewArray(rdb.TypeDecimal, rdb.NewNumeric(125, 2)), `{1.25}`},
+		{rdb.NewArray(rdb.TypeTimestampz, time.Date(2016, 3, 4, 5, 6, 7, 0, time.UTC)), `{2016-03-04T05:06:07Z}`},
+		{rdb.NewArray(rdb.TypeDuration, time.Second), `{1s}`},
+		{rdb.NewArray(rdb.TypeEnum, rdb.Enum("red")), `{red}`},
+	}
+	for _, item := range list {
+		text, err := item.array.MarshalText()
+		if err != nil {
+			t.Errorf("%s: %v", item.text, err)
+			continue
+		}
+		if string(text) != item.text {
+			t.Errorf("got %s, want %s", text, item.text)
+		}
+		back := rdb.Array{Elem: item.array.Elem}
+		if err = back.UnmarshalText(text); err != nil {
+			t.Errorf("%s: %v", item.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(back, item.array) {
+			t.Errorf("%s: got %#v, want %#v", item.text, back, item.array)
+		}
+	}
+}
+
+func TestArrayParse(t *testing.T) {
+	list := []struct {
+		text string
+		want rdb.Array
+	}{
+		{` { 1 , NULL ,-3 } `, rdb.NewArray(rdb.TypeInt64, int64(1), nil, int64(-3))},
+		{`{1,null,}`, rdb.NewArray(rdb.TypeInt64, int64(1), nil, nil)},
+		{`[1:2]={4,5}`, rdb.NewArray(rdb.TypeInt64, int64(4), int64(5))},
+		{`{"NULL"}`, rdb.NewArray(rdb.TypeText, "NULL")},
+		{`{a b}`, rdb.NewArray(rdb.TypeText, "a b")},
+	}
+	for _, item := range list {
+		got := rdb.Array{Elem: item.want.Elem}
+		if err := got.Scan(item.text); err != nil {
+			t.Errorf("%s: %v", item.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, item.want) {
+			t.Errorf("%s: got %#v, want %#v", item.text, got, item.want)
+		}
+	}
+
+	for _, bad := range []string{``, `1,2}`, `{1,2`, `{1,2}x`, `{"a}`, `{a}`, `{{1}`} {
+		a := rdb.Array{Elem: rdb.TypeInt64}
+		if err := a.UnmarshalText([]byte(bad)); err == nil {
+			t.Errorf("%s: expected error, got %#v", bad, a)
+		}
+	}
+}
+
+func TestArrayBinary(t *testing.T) {
+	list := []rdb.Array{
+		rdb.NewArray(rdb.TypeInt32, int64(1), nil, int64(2)),
+		rdb.NewArray(rdb.TypeUint64, uint64(1<<63)),
+		rdb.NewArray(rdb.TypeFloat64, 1.5),
+		rdb.NewArray(rdb.TypeBool, true, false),
+		rdb.NewArray(rdb.TypeText, "a", ""),
+		rdb.NewArray(rdb.TypeBinary, []byte{1, 2}),
+		rdb.NewArray(rdb.TypeDecimal, rdb.NewNumeric(125, 2)),
+		rdb.NewArray(rdb.TypeTimestampz, time.Date(2016, 3, 4, 5, 6, 7, 8, time.UTC)),
+		rdb.NewArray(rdb.TypeDuration, time.Second),
+		rdb.NewArray(rdb.TypeUUID, mustUUID(t, testUUID)),
+		rdb.NewArray(rdb.TypeJSON, rdb.JSON(`[1]`)),
+		rdb.NewArray(rdb.TypeInt64),
+	}
+	for _, a := range list {
+		data, err := a.MarshalBinary()
+		if err != nil {
+			t.Errorf("%v: %v", a, err)
+			continue
+		}
+		var back rdb.Array
+		if err = back.UnmarshalBinary(data); err != nil {
+			t.Errorf("%v: %v", a, err)
+			continue
+		}
+		if !reflect.DeepEqual(back, a) {
+			t.Errorf("got %#v, want %#v", back, a)
+		}
+		if err = back.UnmarshalBinary(data[:len(data)-1]); err == nil && len(a.Items) > 0 {
+			t.Errorf("%v: expected error for truncated data", a)
+		}
+	}
+
+	nested := rdb.NewArray(rdb.TypeInt32, rdb.NewArray(rdb.TypeInt32, int64(1)))
+	if _, err := nested.MarshalBinary(); err == nil {
+		t.Error("nested: expected error")
+	}
+	if _, err := rdb.NewArray(rdb.TypeInt32, "x").MarshalBinary(); err == nil {
+		t.Error("text in integer array: expected error")
+	}
+	var a rdb.Array
+	for _, bad := range [][]byte{nil, {0, 0, 4, 15, 0, 0, 0, 9}} {
+		if err := a.UnmarshalBinary(bad); err == nil {
+			t.Errorf("%v: expected error", bad)
+		}
+	}
+}
+
+func TestArrayValue(t *testing.T) {
+	a := rdb.Array{Elem: rdb.TypeInt64}
+	if err := a.Scan([]byte(`{1}`)); err != nil || !reflect.DeepEqual(a.Items, []interface{}{int64(1)}) {
+		t.Errorf("scan: got %v, %v", a.Items, err)
+	}
+	if v, err := a.Value(); err != nil || v != `{1}` {
+		t.Errorf("value: got %v, %v", v, err)
+	}
+	if err := a.Scan(nil); err != nil || a.Items != nil {
+		t.Errorf("scan null: got %v, %v", a.Items, err)
+	}
+	if v, err := a.Value(); err != nil || v != nil {
+		t.Errorf("null value: got %v, %v", v, err)
+	}
+	if err := a.Scan(int64(1)); err == nil {
+		t.Error("scan integer: expected error")
+	}
+}
@@ -48,6 +48,10 @@ type Config struct {
 	// Ignored if Secure is false.
 	InsecureSkipVerify bool
 
+	// Types inferred for parameters with TypeUnknown that may be sent
+	// as more than one type.
+	TypeDefaults TypeDefaults
+
 	KV map[string]interface{}
 }
 
@@ -63,6 +67,8 @@ type Config struct {
 //      init_cap=<int>:               PoolInitCapacity
 //      max_cap=<int>:                PoolMaxCapacity
 //      idle_timeout=<time.Duration>: PoolIdleTimeout
+//      text_type=<rdb.Type>:         TypeDefaults.Text
+//      time_type=<rdb.Type>:         TypeDefaults.Time
 func ParseConfigURL(connectionString string) (*Config, error) {
 	u, err := url.Parse(connectionString)
 	if err != nil {
@@ -126,10 +132,29 @@ func ParseConfigURL(connectionString string) (*Config, error) {
 	}
 	val.Del("max_cap")
 
+	if st := val.Get("text_type"); len(st) != 0 {
+		conf.TypeDefaults.Text, err = ParseType(st)
+		if err != nil {
+			return nil, err
+		}
+	}
+	val.Del("text_type")
+
+	if st := val.Get("time_type"); len(st) != 0 {
+		conf.TypeDefaults.Time, err = ParseType(st)
+		if err != nil {
+			return nil, err
+		}
+	}
+	val.Del("time_type")
+
 	if len(u.Path) > 0 {
 		conf.Instance = u.Path[1:]
 	}
 
+	if len(val) != 0 {
+		conf.KV = make(map[string]interface{}, len(val))
+	}
 	for key, value := range val {
 		conf.KV[key] = value
 	}
@@ -0,0 +1,44 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"testing"
+
+	"github.com/kardianos/rdb"
+)
+
+func TestParseConfigTypeDefaults(t *testing.T) {
+	list := []struct {
+		url  string
+		want rdb.TypeDefaults
+		ok   bool
+	}{
+		{"mem://", rdb.TypeDefaults{}, true},
+		{"mem://?text_type=TypeAnsiVarChar", rdb.TypeDefaults{Text: rdb.TypeAnsiVarChar}, true},
+		{"mem://?time_type=typetimestamp&text_type=TypeVarChar", rdb.TypeDefaults{Text: rdb.TypeVarChar, Time: rdb.TypeTimestamp}, true},
+		{"mem://?text_type=varchar", rdb.TypeDefaults{}, false},
+		{"mem://?time_type=Type(x)", rdb.TypeDefaults{}, false},
+	}
+	for _, item := range list {
+		conf, err := rdb.ParseConfigURL(item.url)
+		if !item.ok {
+			if err == nil {
+				t.Errorf("%s: expected error", item.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", item.url, err)
+			continue
+		}
+		if conf.TypeDefaults != item.want {
+			t.Errorf("%s: got %+v, want %+v", item.url, conf.TypeDefaults, item.want)
+		}
+		if _, found := conf.KV["text_type"]; found {
+			t.Errorf("%s: text_type left in KV", item.url)
+		}
+	}
+}
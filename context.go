@@ -29,6 +29,17 @@ func FromContext(ctx context.Context) (Pool, bool) {
 
 var (
 	errNoPoolContext = errors.New("No Pool in context")
+	errNoColumns     = errors.New("No columns in result")
+)
+
+var (
+	// ErrNoRows is returned by QueryOne and QueryScalar when the query
+	// returns no rows.
+	ErrNoRows = errors.New("No rows in result")
+
+	// ErrTooManyRows is returned by QueryOne and QueryScalar when the
+	// query returns more then one row.
+	ErrTooManyRows = errors.New("More then one row in result")
 )
 
 type nextError struct {
@@ -84,3 +95,35 @@ func QuerySet(ctx context.Context, cmd *Command, params ...Param) (BufferSet, er
 		set = append(set, b)
 	}
 }
+
+// queryOne calls read with the single row of the first result.
+func queryOne(ctx context.Context, q Queryer, cmd *Command, params []Param, read func(schema Schema, row Row) error) error {
+	next := q.Query(ctx, cmd, params...)
+	defer next.Close()
+
+	res, err := next.Result()
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return ErrNoRows
+	}
+	row, err := res.Scan()
+	if err != nil {
+		return err
+	}
+	if row == nil {
+		return ErrNoRows
+	}
+	if err = read(res.Schema(), row); err != nil {
+		return err
+	}
+	row, err = res.Scan()
+	if err != nil {
+		return err
+	}
+	if row != nil {
+		return ErrTooManyRows
+	}
+	return nil
+}
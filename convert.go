@@ -0,0 +1,290 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"database/sql"
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	bytesType = reflect.TypeOf([]byte(nil))
+	timeType  = reflect.TypeOf(time.Time{})
+)
+
+// convertAssign sets dv to src, converting between compatible types.
+// Numbers are range checked. Pointers are allocated as needed.
+func convertAssign(dv reflect.Value, src interface{}) error {
+	if dv.CanAddr() {
+		if s, ok := dv.Addr().Interface().(sql.Scanner); ok {
+			return s.Scan(src)
+		}
+	}
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("Cannot assign null to %s", dv.Type())
+	}
+	if dv.Kind() == reflect.Ptr {
+		nv := reflect.New(dv.Type().Elem())
+		if err := convertAssign(nv.Elem(), src); err != nil {
+			return err
+		}
+		dv.Set(nv)
+		return nil
+	}
+
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dv.Type()) {
+		if b, ok := src.([]byte); ok {
+			// Drivers may reuse the buffer.
+			sv = reflect.ValueOf(append([]byte(nil), b...))
+		}
+		dv.Set(sv)
+		return nil
+	}
+
+	switch dv.Kind() {
+	case reflect.String:
+		switch v := src.(type) {
+		case string:
+			dv.SetString(v)
+			return nil
+		case []byte:
+			dv.SetString(string(v))
+			return nil
+		case time.Time:
+			dv.SetString(v.Format(time.RFC3339Nano))
+			return nil
+		case Numeric:
+			dv.SetString(v.String())
+			return nil
+		case encoding.TextMarshaler:
+			b, err := v.MarshalText()
+			if err != nil {
+				return err
+			}
+			dv.SetString(string(b))
+			return nil
+		}
+		switch sv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			dv.SetString(strconv.FormatInt(sv.Int(), 10))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			dv.SetString(strconv.FormatUint(sv.Uint(), 10))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			dv.SetString(strconv.FormatFloat(sv.Float(), 'g', -1, sv.Type().Bits()))
+			return nil
+		case reflect.Bool:
+			dv.SetString(strconv.FormatBool(sv.Bool()))
+			return nil
+		case reflect.String:
+			dv.SetString(sv.String())
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := asInt64(src)
+		if err != nil {
+			return err
+		}
+		if dv.OverflowInt(n) {
+			return fmt.Errorf("Value %d overflows %s", n, dv.Type())
+		}
+		dv.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := asUint64(src)
+		if err != nil {
+			return err
+		}
+		if dv.OverflowUint(n) {
+			return fmt.Errorf("Value %d overflows %s", n, dv.Type())
+		}
+		dv.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := asFloat64(src)
+		if err != nil {
+			return err
+		}
+		if dv.OverflowFloat(f) {
+			return fmt.Errorf("Value %g overflows %s", f, dv.Type())
+		}
+		dv.SetFloat(f)
+		return nil
+	case reflect.Bool:
+		switch v := src.(type) {
+		case bool:
+			dv.SetBool(v)
+			return nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("Cannot convert %q to bool", v)
+			}
+			dv.SetBool(b)
+			return nil
+		case []byte:
+			b, err := strconv.ParseBool(string(v))
+			if err != nil {
+				return fmt.Errorf("Cannot convert %q to bool", v)
+			}
+			dv.SetBool(b)
+			return nil
+		}
+		if n, err := asInt64(src); err == nil && (n == 0 || n == 1) {
+			dv.SetBool(n == 1)
+			return nil
+		}
+	case reflect.Slice:
+		if dv.Type().Elem().Kind() == reflect.Uint8 {
+			switch v := src.(type) {
+			case string:
+				dv.SetBytes([]byte(v))
+				return nil
+			case []byte:
+				dv.SetBytes(append([]byte(nil), v...))
+				return nil
+			}
+		}
+	case reflect.Struct:
+		if dv.Type() == timeType {
+			if s, ok := src.(string); ok {
+				t, err := time.Parse(time.RFC3339Nano, s)
+				if err != nil {
+					return fmt.Errorf("Cannot convert %q to time", s)
+				}
+				dv.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+	}
+	if sv.Kind() == dv.Kind() && sv.Type().ConvertibleTo(dv.Type()) {
+		dv.Set(sv.Convert(dv.Type()))
+		return nil
+	}
+	return fmt.Errorf("Cannot convert %T to %s", src, dv.Type())
+}
+
+func asInt64(src interface{}) (int64, error) {
+	sv := reflect.ValueOf(src)
+	switch sv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := sv.Uint()
+		if u > math.MaxInt64 {
+			return 0, fmt.Errorf("Value %d overflows int64", u)
+		}
+		return int64(u), nil
+	case reflect.Float32, reflect.Float64:
+		f := sv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, fmt.Errorf("Value %g is not an integer", f)
+		}
+		return int64(f), nil
+	case reflect.String:
+		return parseInt(sv.String())
+	}
+	switch v := src.(type) {
+	case []byte:
+		return parseInt(string(v))
+	case Numeric:
+		n, ok := v.Int64()
+		if !ok {
+			return 0, fmt.Errorf("Value %s is not an int64", v)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("Cannot convert %T to an integer", src)
+}
+
+func parseInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Cannot convert %q to an integer", s)
+	}
+	return n, nil
+}
+
+func asUint64(src interface{}) (uint64, error) {
+	sv := reflect.ValueOf(src)
+	switch sv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return sv.Uint(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := sv.Int()
+		if n < 0 {
+			return 0, fmt.Errorf("Value %d overflows unsigned integer", n)
+		}
+		return uint64(n), nil
+	case reflect.Float32, reflect.Float64:
+		f := sv.Float()
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+			return 0, fmt.Errorf("Value %g is not an unsigned integer", f)
+		}
+		return uint64(f), nil
+	case reflect.String:
+		return parseUint(sv.String())
+	}
+	switch v := src.(type) {
+	case []byte:
+		return parseUint(string(v))
+	case Numeric:
+		r := v.Rat()
+		if !r.IsInt() || !r.Num().IsUint64() {
+			return 0, fmt.Errorf("Value %s is not a uint64", v)
+		}
+		return r.Num().Uint64(), nil
+	}
+	return 0, fmt.Errorf("Cannot convert %T to an unsigned integer", src)
+}
+
+func parseUint(s string) (uint64, error) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Cannot convert %q to an unsigned integer", s)
+	}
+	return n, nil
+}
+
+func asFloat64(src interface{}) (float64, error) {
+	sv := reflect.ValueOf(src)
+	switch sv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return sv.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(sv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(sv.Uint()), nil
+	case reflect.String:
+		return parseFloat(sv.String())
+	}
+	switch v := src.(type) {
+	case []byte:
+		return parseFloat(string(v))
+	case Numeric:
+		return v.Float64(), nil
+	}
+	return 0, fmt.Errorf("Cannot convert %T to a float", src)
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Cannot convert %q to a float", s)
+	}
+	return f, nil
+}
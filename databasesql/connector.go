@@ -0,0 +1,406 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/kardianos/rdb"
+	"github.com/pkg/errors"
+)
+
+var (
+	errConnectorOpen = errors.New("rdb pools must be opened with sql.OpenDB(databasesql.NewConnector(pool))")
+	errNoInsertID    = errors.New("rdb does not report last insert id")
+	errNoRowsInfo    = errors.New("rdb pool does not report rows affected")
+	errReadOnly      = errors.New("read only transactions are not supported by rdb")
+)
+
+// NewConnector returns a driver.Connector that runs queries on pool.
+// It allows any rdb.Pool to be used where a *sql.DB is required:
+//
+//	db := sql.OpenDB(databasesql.NewConnector(pool))
+//
+// Each database/sql connection shares the pool; transactions are
+// started with pool.Begin. Transactions that implement rdb.Rollbacker
+// are rolled back with it, others by cancelling the transaction context.
+// RowsAffected is reported if the rdb.Next implements rdb.RowsAffecter.
+func NewConnector(pool rdb.Pool) driver.Connector {
+	return &connector{pool: pool}
+}
+
+type connector struct {
+	pool rdb.Pool
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{pool: c.pool}, nil
+}
+func (c *connector) Driver() driver.Driver {
+	return poolDriver{}
+}
+
+// poolDriver is returned from connector.Driver. It cannot open names.
+type poolDriver struct{}
+
+func (poolDriver) Open(name string) (driver.Conn, error) {
+	return nil, errConnectorOpen
+}
+
+// conn implements driver.Conn on an rdb.Pool.
+type conn struct {
+	pool rdb.Pool
+	tx   *poolTx
+}
+
+type poolTx struct {
+	conn   *conn
+	tx     rdb.Transaction
+	ctx    context.Context
+	cancel func()
+}
+
+type poolStmt struct {
+	conn  *conn
+	query string
+}
+
+type poolRows struct {
+	next   rdb.Next
+	res    rdb.Result
+	schema rdb.Schema
+
+	pending    rdb.Result
+	pendingErr error
+	fetched    bool
+}
+
+type execResult struct {
+	rows  int64
+	known bool
+}
+
+func (c *conn) queryer() rdb.Queryer {
+	if c.tx != nil {
+		return c.tx.tx
+	}
+	return c.pool
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &poolStmt{conn: c, query: query}, nil
+}
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return &poolStmt{conn: c, query: query}, nil
+}
+
+// Close rolls back any open transaction. The pool is not closed.
+func (c *conn) Close() error {
+	if c.tx != nil {
+		return c.tx.Rollback()
+	}
+	return nil
+}
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if opts.ReadOnly {
+		return nil, errReadOnly
+	}
+	iso, err := rdbIsolation(sql.IsolationLevel(opts.Isolation))
+	if err != nil {
+		return nil, err
+	}
+	// The rdb transaction is rolled back when its context is cancelled.
+	txctx, cancel := context.WithCancel(ctx)
+	tx, err := c.pool.Begin(txctx, iso)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	c.tx = &poolTx{
+		conn:   c,
+		tx:     tx,
+		ctx:    txctx,
+		cancel: cancel,
+	}
+	return c.tx, nil
+}
+func (c *conn) Ping(ctx context.Context) error {
+	return c.pool.Ping(ctx)
+}
+
+// CheckNamedValue accepts all values. Values are passed to the rdb driver as is.
+// An sql.Out value is sent as an output parameter and an rdb.Param value
+// is sent unchanged.
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	return nil
+}
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	nx := c.queryer().Query(ctx, &rdb.Command{SQL: query}, makeParams(args)...)
+	res, err := nx.Result()
+	if err != nil {
+		nx.Close()
+		return nil, err
+	}
+	r := &poolRows{next: nx}
+	r.set(res)
+	return r, nil
+}
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	nx := c.queryer().Query(ctx, &rdb.Command{SQL: query}, makeParams(args)...)
+	defer nx.Close()
+
+	if _, err := nx.BufferSet(); err != nil {
+		return nil, err
+	}
+	var res execResult
+	if ra, is := nx.(rdb.RowsAffecter); is {
+		res.rows, res.known = ra.RowsAffected()
+	}
+	return res, nil
+}
+
+func (tx *poolTx) Commit() error {
+	defer tx.end()
+	return tx.tx.Commit(tx.ctx)
+}
+
+// Rollback rolls back the rdb transaction and returns its error.
+// If the transaction has no Rollback method the transaction context
+// is cancelled, which rolls it back.
+func (tx *poolTx) Rollback() error {
+	defer tx.end()
+	if r, is := tx.tx.(rdb.Rollbacker); is {
+		return r.Rollback(tx.ctx)
+	}
+	return nil
+}
+func (tx *poolTx) end() {
+	tx.cancel()
+	if tx.conn.tx == tx {
+		tx.conn.tx = nil
+	}
+}
+
+func (s *poolStmt) Close() error {
+	return nil
+}
+func (s *poolStmt) NumInput() int {
+	return -1
+}
+func (s *poolStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+func (s *poolStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+func (s *poolStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+func (s *poolStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+func (s *poolStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return s.conn.CheckNamedValue(nv)
+}
+
+func (r *poolRows) set(res rdb.Result) {
+	r.res = res
+	r.schema = nil
+	if res != nil {
+		r.schema = res.Schema()
+	}
+}
+func (r *poolRows) Columns() []string {
+	names := make([]string, len(r.schema))
+	for i, col := range r.schema {
+		names[i] = col.Name
+	}
+	return names
+}
+func (r *poolRows) Close() error {
+	return r.next.Close()
+}
+func (r *poolRows) Next(dest []driver.Value) error {
+	if r.res == nil {
+		return io.EOF
+	}
+	row, err := r.res.Scan()
+	if err != nil {
+		return err
+	}
+	if row == nil {
+		return io.EOF
+	}
+	for i := range dest {
+		dest[i] = driverValue(row.Getx(i))
+	}
+	return nil
+}
+func (r *poolRows) HasNextResultSet() bool {
+	if !r.fetched {
+		r.pending, r.pendingErr = r.next.Result()
+		r.fetched = true
+	}
+	return r.pending != nil
+}
+func (r *poolRows) NextResultSet() error {
+	r.HasNextResultSet()
+	res, err := r.pending, r.pendingErr
+	r.pending, r.pendingErr, r.fetched = nil, nil, false
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return io.EOF
+	}
+	r.set(res)
+	return nil
+}
+func (r *poolRows) ColumnTypeDatabaseTypeName(index int) string {
+	return databaseTypeName(r.schema[index].Type)
+}
+func (r *poolRows) ColumnTypeLength(index int) (int64, bool) {
+	col := r.schema[index]
+	switch col.Type.GenericOf() {
+	case rdb.Text, rdb.Binary:
+		if col.Length < 0 {
+			return math.MaxInt64, true
+		}
+		return int64(col.Length), true
+	}
+	return 0, false
+}
+func (r *poolRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return r.schema[index].Nullable, true
+}
+func (r *poolRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	col := r.schema[index]
+	if col.Type.GenericOf() != rdb.Decimal {
+		return 0, 0, false
+	}
+	return int64(col.Precision), int64(col.Scale), true
+}
+func (r *poolRows) ColumnTypeScanType(index int) reflect.Type {
+	switch r.schema[index].Type.GenericOf() {
+	case rdb.Text:
+		return reflect.TypeOf("")
+	case rdb.Binary:
+		return reflect.TypeOf([]byte(nil))
+	case rdb.Bool:
+		return reflect.TypeOf(false)
+	case rdb.Integer:
+		return reflect.TypeOf(int64(0))
+	case rdb.Float:
+		return reflect.TypeOf(float64(0))
+	case rdb.Time:
+		return reflect.TypeOf(time.Time{})
+	}
+	return reflect.TypeOf((*interface{})(nil)).Elem()
+}
+
+func (execResult) LastInsertId() (int64, error) {
+	return 0, errNoInsertID
+}
+func (r execResult) RowsAffected() (int64, error) {
+	if !r.known {
+		return 0, errNoRowsInfo
+	}
+	return r.rows, nil
+}
+
+// makeParams converts database/sql arguments to rdb parameters.
+func makeParams(args []driver.NamedValue) []rdb.Param {
+	params := make([]rdb.Param, len(args))
+	for i, arg := range args {
+		switch v := arg.Value.(type) {
+		case rdb.Param:
+			if len(v.Name) == 0 {
+				v.Name = arg.Name
+			}
+			params[i] = v
+		case sql.Out:
+			params[i] = rdb.Param{Name: arg.Name, Out: true, Value: v.Dest}
+		default:
+			params[i] = rdb.Param{Name: arg.Name, Value: v}
+		}
+	}
+	return params
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	nv := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		nv[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return nv
+}
+
+// driverValue widens integer and float values to the types database/sql expects.
+// Valuers, such as rdb.Numeric, are converted to their driver value.
+func driverValue(v interface{}) driver.Value {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint:
+		if uint64(v) <= math.MaxInt64 {
+			return int64(v)
+		}
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+	case float32:
+		return float64(v)
+	case driver.Valuer:
+		if dv, err := v.Value(); err == nil {
+			return dv
+		}
+	}
+	return v
+}
+
+// rdbIsolation maps a database/sql isolation level to an rdb level.
+func rdbIsolation(level sql.IsolationLevel) (rdb.Isolation, error) {
+	switch level {
+	case sql.LevelDefault:
+		return rdb.IsoDefault, nil
+	case sql.LevelReadUncommitted:
+		return rdb.IsoReadUncommited, nil
+	case sql.LevelReadCommitted:
+		return rdb.IsoReadCommited, nil
+	case sql.LevelWriteCommitted:
+		return rdb.IsoWriteCommited, nil
+	case sql.LevelRepeatableRead:
+		return rdb.IsoRepeatableRead, nil
+	case sql.LevelSnapshot:
+		return rdb.IsoSnapshot, nil
+	case sql.LevelSerializable:
+		return rdb.IsoSerializable, nil
+	case sql.LevelLinearizable:
+		return rdb.IsoLinearizable, nil
+	}
+	return rdb.IsoDefault, errors.Errorf("isolation level %v has no rdb equivalent", level)
+}
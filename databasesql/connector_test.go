@@ -0,0 +1,170 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/kardianos/rdb"
+	_ "github.com/kardianos/rdb/rdbmem"
+	"github.com/kardianos/rdb/rdbtest"
+	"github.com/pkg/errors"
+)
+
+// openMem returns a database/sql DB on a new in-memory rdb pool.
+func openMem(t *testing.T) *sql.DB {
+	t.Helper()
+	config, err := rdb.ParseConfigURL("mem://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := rdb.Open(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(NewConnector(p))
+	if _, err = db.Exec(`create table t (id int, v text)`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func countRows(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`select count(*) from t`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestConnectorQuery(t *testing.T) {
+	db := openMem(t)
+	defer db.Close()
+
+	res, err := db.Exec(`insert into t values (?, ?), (@id, @v)`, 1, "a", sql.Named("id", 2), sql.Named("v", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 2 {
+		t.Errorf("insert rows affected: got %d, %v", n, err)
+	}
+	if _, err = res.LastInsertId(); err == nil {
+		t.Error("last insert id: expected error")
+	}
+	res, err = db.Exec(`update t set v = 'c' where id = @id`, sql.Named("id", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		t.Errorf("update rows affected: got %d, %v", n, err)
+	}
+
+	rows, err := db.Query(`select id, v from t order by id; select count(*) as n from t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var id int64
+		var v string
+		if err = rows.Scan(&id, &v); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if len(got) != 2 || got[0] != "a" || got[1] != "c" {
+		t.Errorf("got values %q", got)
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := types[1].DatabaseTypeName(); name != "TEXT" {
+		t.Errorf("got database type %q, want TEXT", name)
+	}
+	if !rows.NextResultSet() || !rows.Next() {
+		t.Fatalf("missing second result: %v", rows.Err())
+	}
+	var n int
+	if err = rows.Scan(&n); err != nil || n != 2 {
+		t.Errorf("count: got %d, %v", n, err)
+	}
+	if rows.NextResultSet() {
+		t.Error("unexpected third result")
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnectorCommit(t *testing.T) {
+	db := openMem(t)
+	defer db.Close()
+
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec(`insert into t values (1, 'a')`); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, db); n != 1 {
+		t.Errorf("got %d rows after commit, want 1", n)
+	}
+	if _, err = db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true}); err == nil {
+		t.Error("read only: expected error")
+	}
+}
+
+func TestConnectorRollback(t *testing.T) {
+	db := openMem(t)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec(`insert into t values (1, 'a')`); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, db); n != 0 {
+		t.Errorf("got %d rows after rollback, want 0", n)
+	}
+	if err = tx.Rollback(); err != sql.ErrTxDone {
+		t.Errorf("second rollback: got %v, want %v", err, sql.ErrTxDone)
+	}
+}
+
+func TestConnectorRollbackError(t *testing.T) {
+	rollbackErr := errors.New("rollback failed")
+	m := rdbtest.New()
+	m.ExpectBegin()
+	m.ExpectRollback().WillReturnError(rollbackErr)
+
+	db := sql.OpenDB(NewConnector(m))
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(); err != rollbackErr {
+		t.Errorf("got %v, want %v", err, rollbackErr)
+	}
+	if err = m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
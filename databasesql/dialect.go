@@ -0,0 +1,86 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Dialect returns driver specific SQL for operations database/sql does not expose.
+type Dialect interface {
+	// SavePoint returns the SQL to create a savepoint.
+	SavePoint(name string) string
+
+	// RollbackTo returns the SQL to roll back to an existing savepoint.
+	RollbackTo(name string) string
+}
+
+type ansiDialect struct{}
+
+func (ansiDialect) SavePoint(name string) string {
+	return "SAVEPOINT " + name
+}
+func (ansiDialect) RollbackTo(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+type tsqlDialect struct{}
+
+func (tsqlDialect) SavePoint(name string) string {
+	return "SAVE TRANSACTION " + name
+}
+func (tsqlDialect) RollbackTo(name string) string {
+	return "ROLLBACK TRANSACTION " + name
+}
+
+var (
+	dialectSync = sync.RWMutex{}
+	dialectList = map[string]Dialect{
+		"postgres":  ansiDialect{},
+		"pgx":       ansiDialect{},
+		"mysql":     ansiDialect{},
+		"sqlite3":   ansiDialect{},
+		"sqlite":    ansiDialect{},
+		"mssql":     tsqlDialect{},
+		"sqlserver": tsqlDialect{},
+	}
+)
+
+// RegisterDialect sets the dialect used for the named database/sql driver.
+func RegisterDialect(driverName string, d Dialect) {
+	dialectSync.Lock()
+	defer dialectSync.Unlock()
+
+	dialectList[driverName] = d
+}
+
+func lookupDialect(driverName string) (Dialect, error) {
+	dialectSync.RLock()
+	defer dialectSync.RUnlock()
+
+	d, found := dialectList[driverName]
+	if !found {
+		return nil, errors.Errorf("no dialect registered for driver %q", driverName)
+	}
+	return d, nil
+}
+
+// checkSavePointName ensures the name can be used as a bare identifier.
+func checkSavePointName(name string) error {
+	if len(name) == 0 {
+		return errors.New("missing savepoint name")
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return errors.Errorf("invalid savepoint name %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"testing"
+
+	"github.com/kardianos/rdb"
+)
+
+func parseConfig(t *testing.T, raw string) *rdb.Config {
+	t.Helper()
+	config, err := rdb.ParseConfigURL(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestDataSourceName(t *testing.T) {
+	list := []struct {
+		name   string
+		config *rdb.Config
+		want   string
+	}{
+		{
+			name:   "native postgres URL in Raw",
+			config: &rdb.Config{DriverName: "postgres", Raw: "postgres://u:p@db.example/app?sslmode=disable"},
+			want:   "postgres://u:p@db.example/app?sslmode=disable",
+		},
+		{
+			name:   "native keyword DSN in Raw",
+			config: &rdb.Config{DriverName: "postgres", Raw: "host=db.example dbname=app"},
+			want:   "host=db.example dbname=app",
+		},
+		{
+			name:   "parsed postgres URL keeps database",
+			config: parseConfig(t, "postgres://u:p@db.example/app?sslmode=disable"),
+			want:   "postgres://u:p@db.example/app?sslmode=disable",
+		},
+		{
+			name:   "parsed rdb URL drops pool options",
+			config: parseConfig(t, "postgres://u@db.example:5433/?db=app&max_cap=4"),
+			want:   "postgres://u@db.example:5433/app",
+		},
+		{
+			name:   "structured postgres",
+			config: &rdb.Config{DriverName: "pgx", Username: "u", Hostname: "h", Database: "app", Secure: true},
+			want:   "postgres://u@h/app?sslmode=verify-full",
+		},
+		{
+			name:   "structured postgres insecure",
+			config: &rdb.Config{DriverName: "postgres", Database: "app", Secure: true, InsecureSkipVerify: true},
+			want:   "postgres://localhost/app?sslmode=require",
+		},
+		{
+			name:   "structured sqlserver",
+			config: &rdb.Config{DriverName: "sqlserver", Username: "sa", Password: "pw", Hostname: "h", Instance: "SqlExpress", Database: "master", Secure: true},
+			want:   "sqlserver://sa:pw@h/SqlExpress?database=master&encrypt=true",
+		},
+		{
+			name:   "parsed mysql",
+			config: parseConfig(t, "mysql://u:p@h:3306/?db=app"),
+			want:   "u:p@tcp(h:3306)/app",
+		},
+		{
+			name:   "rdb option",
+			config: parseConfig(t, "mysql://u:p@h:3306/?db=app&decimal_numeric=true"),
+			want:   "u:p@tcp(h:3306)/app",
+		},
+		{
+			name:   "structured mysql",
+			config: &rdb.Config{DriverName: "mysql", Database: "app", Secure: true},
+			want:   "tcp(localhost)/app?tls=true",
+		},
+		{
+			name:   "unknown driver",
+			config: &rdb.Config{DriverName: "other", Raw: "other://h/x"},
+			want:   "other://h/x",
+		},
+	}
+	for _, item := range list {
+		got, err := dataSourceName(item.config)
+		if err != nil {
+			t.Errorf("%s: %v", item.name, err)
+			continue
+		}
+		if got != item.want {
+			t.Errorf("%s: got %q, want %q", item.name, got, item.want)
+		}
+	}
+}
+
+func TestSqliteDSN(t *testing.T) {
+	list := []struct {
+		name   string
+		config *rdb.Config
+		want   string
+	}{
+		{"relative instance", &rdb.Config{DriverName: "sqlite3", Instance: "data/app.sqlite3"}, "file:data/app.sqlite3"},
+		{"absolute instance", &rdb.Config{DriverName: "sqlite3", Instance: "/srv/data/app.sqlite3"}, "file:/srv/data/app.sqlite3"},
+		{"absolute URL", parseConfig(t, "sqlite3:///srv/data/app.sqlite3"), "file:/srv/data/app.sqlite3"},
+		{"drive URL", parseConfig(t, "sqlite:///C:/folder/app.sqlite3"), "file:C:/folder/app.sqlite3"},
+		{"relative URL", parseConfig(t, "sqlite://data/app.sqlite3"), "file:data/app.sqlite3"},
+		{"options", parseConfig(t, "sqlite:///srv/app.sqlite3?mode=ro"), "file:/srv/app.sqlite3?mode=ro"},
+	}
+	for _, item := range list {
+		got, err := sqliteDSN(item.config)
+		if err != nil {
+			t.Errorf("%s: %v", item.name, err)
+			continue
+		}
+		if got != item.want {
+			t.Errorf("%s: got %q, want %q", item.name, got, item.want)
+		}
+	}
+	if _, err := sqliteDSN(&rdb.Config{DriverName: "sqlite3"}); err == nil {
+		t.Error("expected error for a missing file name")
+	}
+}
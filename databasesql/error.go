@@ -0,0 +1,229 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kardianos/rdb"
+)
+
+// Error is a driver error translated by an ErrorTranslator.
+// It implements rdb.SQLError.
+type Error struct {
+	Code     int    // Driver or server specific error number.
+	Line     int    // One based line number of the command, zero if unknown.
+	SQLState string // Five character SQLSTATE, if reported.
+	Message  string // Message reported by the server.
+
+	Err error // Original driver error.
+}
+
+// Error returns the original driver error message.
+func (err *Error) Error() string {
+	if err.Err != nil {
+		return err.Err.Error()
+	}
+	if len(err.SQLState) != 0 {
+		return fmt.Sprintf("%s (SQLSTATE %s)", err.Message, err.SQLState)
+	}
+	return err.Message
+}
+
+// LineNumber of the command the error occurred on.
+func (err *Error) LineNumber() int {
+	return err.Line
+}
+
+// ErrorCode reported by the driver.
+func (err *Error) ErrorCode() int {
+	return err.Code
+}
+
+// Cause returns the original driver error.
+func (err *Error) Cause() error {
+	return err.Err
+}
+
+// Unwrap returns the original driver error.
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// ErrorTranslator converts an error returned from a driver into an *Error.
+// The query is the SQL that was run, if any, and may be used to find the
+// line number of the error. Return false if the error is not recognized.
+type ErrorTranslator func(query string, err error) (*Error, bool)
+
+var (
+	translatorSync = sync.RWMutex{}
+	translatorList = map[string]ErrorTranslator{
+		"postgres":  postgresError,
+		"pgx":       postgresError,
+		"mysql":     mysqlError,
+		"mssql":     sqlserverError,
+		"sqlserver": sqlserverError,
+		"sqlite3":   sqliteError,
+		"sqlite":    sqliteError,
+	}
+)
+
+// RegisterErrorTranslator sets the error translator for the named database/sql driver.
+func RegisterErrorTranslator(driverName string, t ErrorTranslator) {
+	translatorSync.Lock()
+	defer translatorSync.Unlock()
+
+	translatorList[driverName] = t
+}
+
+// translateError returns err as an *Error if the driver translator recognizes it.
+func translateError(driverName, query string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, is := err.(rdb.SQLError); is {
+		return err
+	}
+	translatorSync.RLock()
+	t, found := translatorList[driverName]
+	translatorSync.RUnlock()
+
+	if !found {
+		return err
+	}
+	if sqlErr, ok := t(query, err); ok {
+		if sqlErr.Err == nil {
+			sqlErr.Err = err
+		}
+		return sqlErr
+	}
+	return err
+}
+
+// The built in translators read the exported fields of the driver error
+// types so the drivers do not need to be imported.
+
+// postgresError translates *pq.Error and *pgconn.PgError.
+// The line number is computed from the one based character Position.
+func postgresError(query string, err error) (*Error, bool) {
+	v, ok := errorStruct(err, "Message", "Code", "Position")
+	if !ok {
+		return nil, false
+	}
+	e := &Error{
+		SQLState: fieldString(v, "Code"),
+		Message:  fieldString(v, "Message"),
+	}
+	if pos := fieldInt(v, "Position"); pos > 0 {
+		e.Line = lineAt(query, pos-1)
+	}
+	return e, true
+}
+
+// mysqlError translates *mysql.MySQLError.
+func mysqlError(query string, err error) (*Error, bool) {
+	v, ok := errorStruct(err, "Number", "Message")
+	if !ok {
+		return nil, false
+	}
+	e := &Error{
+		Code:    fieldInt(v, "Number"),
+		Message: fieldString(v, "Message"),
+	}
+	if f := v.FieldByName("SQLState"); f.IsValid() && f.Kind() == reflect.Array {
+		b := make([]byte, f.Len())
+		for i := range b {
+			b[i] = byte(f.Index(i).Uint())
+		}
+		e.SQLState = strings.TrimRight(string(b), "\x00")
+	}
+	return e, true
+}
+
+// sqlserverError translates mssql.Error.
+func sqlserverError(query string, err error) (*Error, bool) {
+	v, ok := errorStruct(err, "Number", "Message", "LineNo")
+	if !ok {
+		return nil, false
+	}
+	return &Error{
+		Code:    fieldInt(v, "Number"),
+		Line:    fieldInt(v, "LineNo"),
+		Message: fieldString(v, "Message"),
+	}, true
+}
+
+// sqliteError translates sqlite3.Error.
+func sqliteError(query string, err error) (*Error, bool) {
+	v, ok := errorStruct(err, "Code", "ExtendedCode")
+	if !ok {
+		return nil, false
+	}
+	return &Error{
+		Code:    fieldInt(v, "ExtendedCode"),
+		Message: err.Error(),
+	}, true
+}
+
+// errorStruct returns the struct value of err if it has all the named fields.
+func errorStruct(err error, fields ...string) (reflect.Value, bool) {
+	v := reflect.ValueOf(err)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return v, false
+	}
+	for _, name := range fields {
+		if !v.FieldByName(name).IsValid() {
+			return v, false
+		}
+	}
+	return v, true
+}
+
+func fieldString(v reflect.Value, name string) string {
+	f := v.FieldByName(name)
+	if f.Kind() == reflect.String {
+		return f.String()
+	}
+	return ""
+}
+
+func fieldInt(v reflect.Value, name string) int {
+	f := v.FieldByName(name)
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(f.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(f.Uint())
+	case reflect.String:
+		n, _ := strconv.Atoi(f.String())
+		return n
+	}
+	return 0
+}
+
+// lineAt returns the one based line number of the character offset in query.
+func lineAt(query string, offset int) int {
+	line, n := 1, 0
+	for _, r := range query {
+		if n >= offset {
+			break
+		}
+		if r == '\n' {
+			line++
+		}
+		n++
+	}
+	return line
+}
@@ -0,0 +1,165 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"testing"
+
+	"github.com/kardianos/rdb"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// The error types below have the exported fields of the driver errors.
+
+type fakePQError struct {
+	Code     string
+	Message  string
+	Position string
+}
+
+func (err *fakePQError) Error() string { return "pq: " + err.Message }
+
+type fakePgconnError struct {
+	Severity string
+	Code     string
+	Message  string
+	Position int32
+}
+
+func (err *fakePgconnError) Error() string { return err.Message }
+
+type fakeMySQLError struct {
+	Number   uint16
+	SQLState [5]byte
+	Message  string
+}
+
+func (err *fakeMySQLError) Error() string { return err.Message }
+
+type fakeMSSQLError struct {
+	Number  int32
+	LineNo  int32
+	Message string
+}
+
+func (err fakeMSSQLError) Error() string { return "mssql: " + err.Message }
+
+type fakeSqliteCode int
+
+type fakeSqliteError struct {
+	Code         fakeSqliteCode
+	ExtendedCode fakeSqliteCode
+	err          string
+}
+
+func (err fakeSqliteError) Error() string { return err.err }
+
+func TestTranslateError(t *testing.T) {
+	const query = "select\n  *\nfrom missing"
+	list := []struct {
+		name   string
+		driver string
+		err    error
+		want   Error
+	}{
+		{"pq", "postgres", &fakePQError{Code: "42P01", Message: "no table", Position: "16"}, Error{SQLState: "42P01", Message: "no table", Line: 3}},
+		{"pgconn", "pgx", &fakePgconnError{Code: "42601", Message: "syntax", Position: 1}, Error{SQLState: "42601", Message: "syntax", Line: 1}},
+		{"pgconn no position", "pgx", &fakePgconnError{Code: "42601", Message: "syntax"}, Error{SQLState: "42601", Message: "syntax"}},
+		{"mysql", "mysql", &fakeMySQLError{Number: 1146, SQLState: [5]byte{'4', '2', 'S', '0', '2'}, Message: "no table"}, Error{Code: 1146, SQLState: "42S02", Message: "no table"}},
+		{"mssql", "mssql", fakeMSSQLError{Number: 208, LineNo: 3, Message: "no table"}, Error{Code: 208, Line: 3, Message: "no table"}},
+		{"sqlserver", "sqlserver", &fakeMSSQLError{Number: 208, LineNo: 2, Message: "no table"}, Error{Code: 208, Line: 2, Message: "no table"}},
+		{"sqlite3", "sqlite3", fakeSqliteError{Code: 1, ExtendedCode: 1, err: "no such table"}, Error{Code: 1, Message: "no such table"}},
+		{"sqlite", "sqlite", fakeSqliteError{Code: 19, ExtendedCode: 2067, err: "unique"}, Error{Code: 2067, Message: "unique"}},
+	}
+	for _, item := range list {
+		err := translateError(item.driver, query, item.err)
+		got, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s: got %T, want *Error", item.name, err)
+			continue
+		}
+		if got.Err != item.err {
+			t.Errorf("%s: original error not kept", item.name)
+		}
+		if got.Error() != item.err.Error() {
+			t.Errorf("%s: got message %q, want %q", item.name, got.Error(), item.err.Error())
+		}
+		got.Err = nil
+		if *got != item.want {
+			t.Errorf("%s: got %+v, want %+v", item.name, *got, item.want)
+		}
+		if sqlErr, ok := err.(rdb.SQLError); !ok || sqlErr.ErrorCode() != item.want.Code || sqlErr.LineNumber() != item.want.Line {
+			t.Errorf("%s: not reported as rdb.SQLError", item.name)
+		}
+	}
+}
+
+func TestTranslateErrorUnknown(t *testing.T) {
+	plain := errors.New("plain")
+	list := []struct {
+		name   string
+		driver string
+		err    error
+	}{
+		{"nil", "postgres", nil},
+		{"not recognized", "postgres", plain},
+		{"no translator", "other", &fakePQError{Code: "42P01"}},
+		{"wrong driver", "mysql", &fakePQError{Code: "42P01"}},
+		{"nil pointer", "postgres", (*fakePQError)(nil)},
+	}
+	for _, item := range list {
+		if got := translateError(item.driver, "", item.err); got != item.err {
+			t.Errorf("%s: got %v, want %v", item.name, got, item.err)
+		}
+	}
+	sqlErr := &Error{Code: 1}
+	if got := translateError("postgres", "", sqlErr); got != sqlErr {
+		t.Errorf("SQLError translated again: got %v", got)
+	}
+}
+
+func TestRegisterErrorTranslator(t *testing.T) {
+	RegisterErrorTranslator("rdbfake-error", func(query string, err error) (*Error, bool) {
+		return &Error{Code: 7, Message: query}, true
+	})
+	err := translateError("rdbfake-error", "select", errors.New("failed"))
+	if e, ok := err.(*Error); !ok || e.Code != 7 || e.Message != "select" || e.Err == nil {
+		t.Errorf("got %#v", err)
+	}
+}
+
+func TestErrorPaths(t *testing.T) {
+	ctx := context.Background()
+	driverErr := &fakePQError{Code: "57P01", Message: "shutdown"}
+	isSQLError := func(name string, err error) {
+		t.Helper()
+		if _, ok := err.(rdb.SQLError); !ok {
+			t.Errorf("%s: got %T %v, want rdb.SQLError", name, err, err)
+		}
+	}
+
+	p := newFakePool("postgres", &fakeDB{err: driverErr})
+	defer p.Close()
+
+	isSQLError("query", p.Query(ctx, &rdb.Command{SQL: "select"}).Close())
+	_, err := p.Begin(ctx, rdb.IsoDefault)
+	isSQLError("begin", err)
+	isSQLError("ping", p.Ping(ctx))
+
+	conn, err := p.Connection(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isSQLError("connection query", conn.Query(ctx, &rdb.Command{SQL: "select"}).Close())
+	conn.Close()
+
+	dial := newFakePool("postgres", &fakeDB{dial: driverErr})
+	defer dial.Close()
+
+	_, err = dial.Connection(ctx)
+	isSQLError("connection", err)
+	isSQLError("ping connect", dial.Ping(ctx))
+}
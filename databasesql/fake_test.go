@@ -0,0 +1,215 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+)
+
+// fakeName is the database/sql driver name of fakeDefault.
+const fakeName = "rdbfake"
+
+var fakeDefault = &fakeDB{}
+
+func init() {
+	sql.Register(fakeName, fakeDriver{db: fakeDefault})
+}
+
+// fakeDB is a database/sql driver for tests. Every query returns the
+// result sets in sets. Statements and transactions are recorded in run.
+type fakeDB struct {
+	mu   sync.Mutex
+	sets []fakeSet
+	err  error // Returned by queries, begin and ping if set.
+	dial error // Returned when a connection is opened if set.
+	run  []string
+	args []driver.NamedValue
+	iso  []driver.IsolationLevel
+}
+
+// fakeSet is a single result set.
+type fakeSet struct {
+	cols  []string
+	types []string // Database type names of cols.
+	rows  [][]driver.Value
+}
+
+// newFakePool returns a Pool on db for the named driver.
+func newFakePool(driverName string, db *fakeDB) *Pool {
+	return &Pool{
+		DB:         sql.OpenDB(db),
+		DriverName: driverName,
+	}
+}
+
+func (db *fakeDB) record(s string) {
+	db.mu.Lock()
+	db.run = append(db.run, s)
+	db.mu.Unlock()
+}
+
+func (db *fakeDB) statements() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]string(nil), db.run...)
+}
+
+func (db *fakeDB) Connect(ctx context.Context) (driver.Conn, error) {
+	if db.dial != nil {
+		return nil, db.dial
+	}
+	return &fakeConn{db: db}, nil
+}
+func (db *fakeDB) Driver() driver.Driver {
+	return fakeDriver{db: db}
+}
+
+type fakeDriver struct {
+	db *fakeDB
+}
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{db: d.db}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+type fakeRows struct {
+	sets []fakeSet
+	set  int
+	pos  int
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+func (c *fakeConn) Close() error {
+	return nil
+}
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if c.db.err != nil {
+		return nil, c.db.err
+	}
+	c.db.iso = append(c.db.iso, opts.Isolation)
+	c.db.run = append(c.db.run, "BEGIN")
+	return &fakeTx{db: c.db}, nil
+}
+func (c *fakeConn) Ping(ctx context.Context) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	return c.db.err
+}
+
+// CheckNamedValue accepts all values, including sql.Out.
+func (c *fakeConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return nil
+}
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.db.run = append(c.db.run, query)
+	c.db.args = args
+	if c.db.err != nil {
+		return nil, c.db.err
+	}
+	return &fakeRows{sets: c.db.sets}, nil
+}
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.db.run = append(c.db.run, query)
+	c.db.args = args
+	if c.db.err != nil {
+		return nil, c.db.err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.db.record("COMMIT")
+	return nil
+}
+func (tx *fakeTx) Rollback() error {
+	tx.db.record("ROLLBACK")
+	return nil
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, driver.ErrSkip
+}
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+func (s *fakeStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return nil
+}
+
+func (r *fakeRows) Columns() []string {
+	if r.set >= len(r.sets) {
+		return nil
+	}
+	return r.sets[r.set].cols
+}
+func (r *fakeRows) Close() error {
+	return nil
+}
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.set >= len(r.sets) || r.pos >= len(r.sets[r.set].rows) {
+		return io.EOF
+	}
+	copy(dest, r.sets[r.set].rows[r.pos])
+	r.pos++
+	return nil
+}
+func (r *fakeRows) HasNextResultSet() bool {
+	return r.set+1 < len(r.sets)
+}
+func (r *fakeRows) NextResultSet() error {
+	if !r.HasNextResultSet() {
+		return io.EOF
+	}
+	r.set++
+	r.pos = 0
+	return nil
+}
+func (r *fakeRows) ColumnTypeDatabaseTypeName(index int) string {
+	types := r.sets[r.set].types
+	if index < len(types) {
+		return types[index]
+	}
+	return ""
+}
@@ -6,8 +6,10 @@ package databasesql
 
 import (
 	"database/sql"
+	"strconv"
 
 	"github.com/kardianos/rdb"
+	"github.com/pkg/errors"
 	"golang.org/x/net/context"
 )
 
@@ -16,6 +18,10 @@ func init() {
 	rdb.RegisterOpener(o)
 }
 
+// kvDecimalNumeric is the config KV key that sets Pool.DecimalNumeric.
+// It is not passed to the driver.
+const kvDecimalNumeric = "decimal_numeric"
+
 // Opener implements an rdb.Opener.
 type Opener struct{}
 
@@ -29,14 +35,92 @@ func (o *Opener) CanOpen(config *rdb.Config) bool {
 	return false
 }
 
-// Open opens the config.
+// Open opens the config. The pool settings of the config are applied
+// to the sql.DB and PoolInitCapacity connections are opened. An init
+// capacity greater than a set max capacity is an error.
+// See RegisterDSN for how the driver connection string is chosen.
+//
+// The config KV value "decimal_numeric" sets Pool.DecimalNumeric,
+// such as "postgres://host/db?decimal_numeric=true".
 func (o *Opener) Open(ctx context.Context, config *rdb.Config) (rdb.Pool, error) {
-	db, err := sql.Open(config.DriverName, config.Raw)
+	if config.PoolMaxCapacity > 0 && config.PoolInitCapacity > config.PoolMaxCapacity {
+		return nil, errors.Errorf("init capacity %d is greater then max capacity %d", config.PoolInitCapacity, config.PoolMaxCapacity)
+	}
+	numeric, err := kvBool(config, kvDecimalNumeric)
+	if err != nil {
+		return nil, err
+	}
+	name, err := dataSourceName(config)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(config.DriverName, name)
 	if err != nil {
 		return nil, err
 	}
+	if config.PoolMaxCapacity > 0 {
+		db.SetMaxOpenConns(config.PoolMaxCapacity)
+		db.SetMaxIdleConns(config.PoolMaxCapacity)
+	} else if config.PoolInitCapacity > 0 {
+		db.SetMaxIdleConns(config.PoolInitCapacity)
+	}
+	if config.PoolIdleTimeout > 0 {
+		db.SetConnMaxIdleTime(config.PoolIdleTimeout)
+	}
+	if err = warm(ctx, db, config.PoolInitCapacity); err != nil {
+		db.Close()
+		return nil, err
+	}
 	pool := &Pool{
-		DB: db,
+		DB:           db,
+		DriverName:   config.DriverName,
+		TypeDefaults: config.TypeDefaults,
+
+		DecimalNumeric: numeric,
 	}
 	return pool, nil
 }
+
+// kvBool returns the named config KV value as a bool. A missing value
+// is false.
+func kvBool(config *rdb.Config, key string) (bool, error) {
+	var s string
+	switch v := config.KV[key].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case string:
+		s = v
+	case []string:
+		if len(v) == 0 {
+			return false, nil
+		}
+		s = v[0]
+	default:
+		return false, errors.Errorf("config %s must be a bool, got %T", key, v)
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, errors.Errorf("config %s must be a bool, got %q", key, s)
+	}
+	return b, nil
+}
+
+// warm opens count connections and returns them to the idle pool.
+func warm(ctx context.Context, db *sql.DB, count int) error {
+	conns := make([]*sql.Conn, 0, count)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < count; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+	"golang.org/x/net/context"
+)
+
+func TestOpenPoolSettings(t *testing.T) {
+	ctx := context.Background()
+	list := []struct {
+		name      string
+		init, max int
+		capacity  int
+	}{
+		{"unset", 0, 0, 0},
+		{"init only", 3, 0, 0},
+		{"init and max", 2, 4, 4},
+		{"init equals max", 3, 3, 3},
+	}
+	for _, item := range list {
+		p, err := rdb.Open(ctx, &rdb.Config{
+			DriverName:       fakeName,
+			PoolInitCapacity: item.init,
+			PoolMaxCapacity:  item.max,
+			PoolIdleTimeout:  time.Minute,
+		})
+		if err != nil {
+			t.Errorf("%s: %v", item.name, err)
+			continue
+		}
+		st := p.Status()
+		if st.Capacity() != item.capacity {
+			t.Errorf("%s: got capacity %d, want %d", item.name, st.Capacity(), item.capacity)
+		}
+		if st.Available() != item.init {
+			t.Errorf("%s: got %d available, want %d", item.name, st.Available(), item.init)
+		}
+		p.Close()
+	}
+}
+
+func TestOpenInitGreaterThanMax(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := rdb.Open(ctx, &rdb.Config{DriverName: fakeName, PoolInitCapacity: 3, PoolMaxCapacity: 2})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("open waited for a connection")
+	}
+}
+
+func TestOpenDecimalNumeric(t *testing.T) {
+	ctx := context.Background()
+	list := []struct {
+		value interface{}
+		want  bool
+		ok    bool
+	}{
+		{nil, false, true},
+		{[]string{"true"}, true, true},
+		{"1", true, true},
+		{false, false, true},
+		{[]string{"yes"}, false, false},
+		{5, false, false},
+	}
+	for _, item := range list {
+		config := &rdb.Config{DriverName: fakeName}
+		if item.value != nil {
+			config.KV = map[string]interface{}{"decimal_numeric": item.value}
+		}
+		p, err := rdb.Open(ctx, config)
+		if !item.ok {
+			if err == nil {
+				p.Close()
+				t.Errorf("%v: expected error", item.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", item.value, err)
+			continue
+		}
+		if got := p.(*Pool).DecimalNumeric; got != item.want {
+			t.Errorf("%v: got %t, want %t", item.value, got, item.want)
+		}
+		p.Close()
+	}
+}
@@ -6,15 +6,22 @@
 //
 // TODO (DT): complete wrapping this package.
 // Limitations:
-//   Cannot cancel a query in progress due to underlying database/sql limitations.
-//   Does not respect rdb.Command.TextAsBytes parameter as the result data type is not available.
 //
-//   import _ "github.com/kardianos/rdb/databasesql"
-//   import _ "my-database-sql-driver"
+//	Does not respect rdb.Command.TextAsBytes parameter as the result data type is not available.
+//
+//	import _ "github.com/kardianos/rdb/databasesql"
+//	import _ "my-database-sql-driver"
 package databasesql // import "github.com/kardianos/rdb/databasesql"
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/kardianos/rdb"
 	"github.com/pkg/errors"
@@ -22,13 +29,24 @@ import (
 )
 
 var (
-	errTODO         = errors.New("TODO")
-	errNotSupported = errors.New("not supported for database/sql based driver")
+	errClosed = errors.New("query closed")
 )
 
 // Pool implements rdb.Pool.
 type Pool struct {
 	DB *sql.DB
+
+	// DriverName is the database/sql driver name used to open DB.
+	// It selects driver specific type mappings.
+	DriverName string
+
+	// TypeDefaults are used to infer parameter types.
+	TypeDefaults rdb.TypeDefaults
+
+	// DecimalNumeric returns the values of Decimal columns as rdb.Numeric.
+	// If false, the database/sql driver value is returned; a value may
+	// still be read into an rdb.Numeric with Row.Into or Result.Prep.
+	DecimalNumeric bool
 }
 
 type next struct {
@@ -38,6 +56,19 @@ type next struct {
 	cancel func()
 
 	textAsBytes bool
+	numeric     bool
+	driverName  string
+	query       string
+	schema      rdb.Schema
+
+	// started is set after the first result is returned,
+	// done after the last result has been read.
+	started bool
+	done    bool
+
+	// Values registered with Prep and Prepx for the current result.
+	prepName  map[string]interface{}
+	prepIndex map[int]interface{}
 }
 
 type statement struct {
@@ -45,12 +76,27 @@ type statement struct {
 	stmt *sql.Stmt
 
 	truncateLongText bool
+	validate         bool
 	textAsBytes      bool
+	numeric          bool
+	driverName       string
+	query            string
+	types            rdb.TypeDefaults
 }
 
 type transaction struct {
-	ctx context.Context
-	tx  *sql.Tx
+	ctx        context.Context
+	tx         *sql.Tx
+	numeric    bool
+	driverName string
+	types      rdb.TypeDefaults
+}
+type connection struct {
+	conn       *sql.Conn
+	cancel     func()
+	numeric    bool
+	driverName string
+	types      rdb.TypeDefaults
 }
 type result struct {
 	rows *sql.Rows
@@ -58,6 +104,7 @@ type result struct {
 
 func (n *next) init() {
 	if n.err != nil {
+		n.err = translateError(n.driverName, n.query, n.err)
 		return
 	}
 	n.ctx, n.cancel = context.WithCancel(n.ctx)
@@ -69,59 +116,203 @@ func (n *next) init() {
 	}()
 }
 func (n *next) Prep(name string, value interface{}) rdb.Result {
-	panic(errTODO)
+	if n.prepName == nil {
+		n.prepName = make(map[string]interface{})
+	}
+	n.prepName[name] = value
 	return n
 }
 func (n *next) Prepx(index int, value interface{}) rdb.Result {
-	panic(errTODO)
+	if n.prepIndex == nil {
+		n.prepIndex = make(map[int]interface{})
+	}
+	n.prepIndex[index] = value
 	return n
 }
+
+// prepped returns the value registered for the column, if any.
+func (n *next) prepped(index int, name string) (interface{}, bool) {
+	if v, ok := n.prepIndex[index]; ok {
+		return v, true
+	}
+	v, ok := n.prepName[name]
+	return v, ok
+}
+
 func (n *next) Scan() (rdb.Row, error) {
-	return nil, errTODO
+	if n.err != nil {
+		return nil, n.err
+	}
+	if !n.rows.Next() {
+		n.err = translateError(n.driverName, n.query, n.rows.Err())
+		return nil, n.err
+	}
+	names, err := n.rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for name := range n.prepName {
+		found := false
+		for _, col := range names {
+			if col == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("prepared column %q not found in result", name)
+		}
+	}
+	values := make([]interface{}, len(names))
+	dest := make([]interface{}, len(names))
+	var writers map[int]io.Writer
+	for i, name := range names {
+		pv, ok := n.prepped(i, name)
+		if !ok {
+			dest[i] = &values[i]
+			continue
+		}
+		if w, is := pv.(io.Writer); is {
+			if writers == nil {
+				writers = make(map[int]io.Writer)
+			}
+			writers[i] = w
+			dest[i] = &sql.RawBytes{}
+			continue
+		}
+		dest[i] = pv
+	}
+	if err = n.rows.Scan(dest...); err != nil {
+		return nil, translateError(n.driverName, n.query, err)
+	}
+	for i, w := range writers {
+		if _, err = w.Write(*dest[i].(*sql.RawBytes)); err != nil {
+			return nil, err
+		}
+	}
+	schema := n.Schema()
+	if n.numeric {
+		decimalValues(schema, values)
+	}
+	return rdb.NewDataRow(schema, values), nil
+}
+
+// decimalValues replaces the values of Decimal columns with rdb.Numeric.
+// The driver value is kept if it is not a plain number, such as money text.
+func decimalValues(schema rdb.Schema, values []interface{}) {
+	for i, v := range values {
+		if v == nil || i >= len(schema) || schema[i].Generic != rdb.Decimal {
+			continue
+		}
+		var d rdb.Numeric
+		if d.Scan(v) == nil {
+			values[i] = d
+		}
+	}
 }
+
 func (n *next) Schema() rdb.Schema {
-	names, _ := n.rows.Columns()
-	sch := make([]rdb.Column, len(names))
-	for i, name := range names {
-		sch[i] = rdb.Column{
-			Name:  name,
-			Index: i,
+	if n.schema != nil {
+		return n.schema
+	}
+	types, err := n.rows.ColumnTypes()
+	if err != nil {
+		names, _ := n.rows.Columns()
+		n.schema = make([]rdb.Column, len(names))
+		for i, name := range names {
+			n.schema[i] = rdb.Column{
+				Name:  name,
+				Index: i,
+			}
 		}
+		return n.schema
+	}
+	n.schema = make([]rdb.Column, len(types))
+	for i, ct := range types {
+		n.schema[i] = makeColumn(n.driverName, i, ct)
 	}
-	return sch
+	return n.schema
 }
 
 func (n *next) Result() (rdb.Result, error) {
-	return n, n.err
+	if n.err != nil {
+		return nil, n.err
+	}
+	if n.done {
+		return nil, nil
+	}
+	if err := n.ctx.Err(); err != nil {
+		n.done = true
+		n.err = err
+		return nil, err
+	}
+	if n.started && !n.rows.NextResultSet() {
+		n.done = true
+		n.err = translateError(n.driverName, n.query, n.rows.Err())
+		n.cancel()
+		return nil, n.err
+	}
+	n.started = true
+	n.schema = nil
+	n.prepName = nil
+	n.prepIndex = nil
+	return n, nil
 }
 func (n *next) Buffer() (*rdb.Buffer, error) {
-	return nil, errTODO
+	res, err := n.Result()
+	if err != nil || res == nil {
+		return nil, err
+	}
+	buf := &rdb.Buffer{
+		Schema: res.Schema(),
+	}
+	for {
+		r, err := res.Scan()
+		if err != nil {
+			return buf, err
+		}
+		if r == nil {
+			return buf, nil
+		}
+		buf.Row = append(buf.Row, r)
+	}
 }
 
-// BufferSet will only return a single buffer for database/sql drivers.
+// BufferSet reads each remaining result into a buffer.
 func (n *next) BufferSet() (rdb.BufferSet, error) {
-	buf, err := n.Buffer()
-	if buf != nil {
-		return rdb.BufferSet{buf}, err
+	var set rdb.BufferSet
+	for {
+		buf, err := n.Buffer()
+		if buf != nil {
+			set = append(set, buf)
+		}
+		if err != nil || buf == nil {
+			return set, err
+		}
 	}
-	return nil, err
 }
 
 func (n *next) Close() error {
-	n.cancel()
-	return n.err
+	if n.cancel != nil {
+		n.cancel()
+	}
+	err := n.err
+	if err == nil {
+		n.err = errClosed
+	}
+	return err
 }
 
 func (st *statement) Exec(ctx context.Context, params ...rdb.Param) rdb.Next {
 	if err := ctx.Err(); err != nil {
 		return &next{err: err}
 	}
-	rows, err := st.stmt.Query(makeArgs(st.truncateLongText, params))
-	if cerr := ctx.Err(); cerr != nil {
-		rows.Close()
-		err = cerr
+	args, err := makeArgs(st.types, st.validate, st.truncateLongText, params)
+	if err != nil {
+		return &next{err: err}
 	}
-	n := &next{err: err, rows: rows, ctx: ctx, textAsBytes: st.textAsBytes}
+	rows, err := st.stmt.QueryContext(ctx, args...)
+	n := &next{err: err, rows: rows, ctx: ctx, textAsBytes: st.textAsBytes, numeric: st.numeric, driverName: st.driverName, query: st.query}
 	n.init()
 	return n
 }
@@ -130,33 +321,197 @@ func (tx *transaction) Query(ctx context.Context, cmd *rdb.Command, params ...rd
 	if err := ctx.Err(); err != nil {
 		return &next{err: err}
 	}
-	rows, err := tx.tx.Query(cmd.SQL, makeArgs(cmd.TruncLongText, params)...)
-	if cerr := ctx.Err(); cerr != nil {
-		rows.Close()
-		err = cerr
+	args, err := makeArgs(tx.types, cmd.Validate, cmd.TruncLongText, params)
+	if err != nil {
+		return &next{err: err}
 	}
-	n := &next{err: err, rows: rows, ctx: ctx, textAsBytes: cmd.TextAsBytes}
+	rows, err := tx.tx.QueryContext(ctx, cmd.SQL, args...)
+	n := &next{err: err, rows: rows, ctx: ctx, textAsBytes: cmd.TextAsBytes, numeric: tx.numeric, driverName: tx.driverName, query: cmd.SQL}
 	n.init()
 	return n
 }
+
+// RollbackTo rolls back to the named savepoint. The savepoint SQL is
+// taken from the Dialect registered for the driver.
 func (tx *transaction) RollbackTo(ctx context.Context, name string) error {
-	return tx.tx.Rollback()
+	return tx.execDialect(ctx, name, Dialect.RollbackTo)
 }
 
-// SavePoint is not supported by database/sql.
+// SavePoint creates a savepoint. The savepoint SQL is taken from the
+// Dialect registered for the driver.
 func (tx *transaction) SavePoint(ctx context.Context, name string) error {
-	return errNotSupported
+	return tx.execDialect(ctx, name, Dialect.SavePoint)
+}
+func (tx *transaction) execDialect(ctx context.Context, name string, stmt func(Dialect, string) string) error {
+	d, err := lookupDialect(tx.driverName)
+	if err != nil {
+		return err
+	}
+	if err = checkSavePointName(name); err != nil {
+		return err
+	}
+	query := stmt(d, name)
+	_, err = tx.tx.ExecContext(ctx, query)
+	return translateError(tx.driverName, query, err)
 }
 func (tx *transaction) Commit(ctx context.Context) error {
-	return tx.tx.Commit()
+	return translateError(tx.driverName, "", tx.tx.Commit())
+}
+
+var _ rdb.Rollbacker = &transaction{}
+
+// Rollback the transaction.
+func (tx *transaction) Rollback(ctx context.Context) error {
+	return translateError(tx.driverName, "", tx.tx.Rollback())
+}
+
+// Close returns the connection to the pool.
+func (c *connection) Close() {
+	c.cancel()
+	c.conn.Close()
+}
+
+func (c *connection) Query(ctx context.Context, cmd *rdb.Command, params ...rdb.Param) rdb.Next {
+	if err := ctx.Err(); err != nil {
+		return &next{err: err}
+	}
+	args, err := makeArgs(c.types, cmd.Validate, cmd.TruncLongText, params)
+	if err != nil {
+		return &next{err: err}
+	}
+	rows, err := c.conn.QueryContext(ctx, cmd.SQL, args...)
+	n := &next{err: err, rows: rows, ctx: ctx, textAsBytes: cmd.TextAsBytes, numeric: c.numeric, driverName: c.driverName, query: cmd.SQL}
+	n.init()
+	return n
 }
 
-func makeArgs(tuncLongText bool, params []rdb.Param) []interface{} {
+// isolationLevel maps an rdb isolation level to a database/sql level.
+func isolationLevel(iso rdb.Isolation) (sql.IsolationLevel, error) {
+	switch iso {
+	case rdb.IsoDefault:
+		return sql.LevelDefault, nil
+	case rdb.IsoReadUncommited:
+		return sql.LevelReadUncommitted, nil
+	case rdb.IsoReadCommited:
+		return sql.LevelReadCommitted, nil
+	case rdb.IsoWriteCommited:
+		return sql.LevelWriteCommitted, nil
+	case rdb.IsoRepeatableRead:
+		return sql.LevelRepeatableRead, nil
+	case rdb.IsoSerializable:
+		return sql.LevelSerializable, nil
+	case rdb.IsoSnapshot:
+		return sql.LevelSnapshot, nil
+	case rdb.IsoLinearizable:
+		return sql.LevelLinearizable, nil
+	}
+	return sql.LevelDefault, errors.Errorf("isolation level %d has no database/sql equivalent", iso)
+}
+
+// makeArgs converts rdb parameters into database/sql arguments.
+// Parameters are first resolved with rdb.ResolveParams, then checked with
+// rdb.ValidateParams if validate is set.
+// Named parameters are passed with sql.Named, output parameters with sql.Out.
+func makeArgs(types rdb.TypeDefaults, validate, truncLongText bool, params []rdb.Param) ([]interface{}, error) {
+	params, err := rdb.ResolveParams(params, types)
+	if err != nil {
+		return nil, err
+	}
+	if validate {
+		if err = rdb.ValidateParams(params, truncLongText); err != nil {
+			return nil, err
+		}
+	}
 	out := make([]interface{}, len(params))
-	for i := range params {
-		out[i] = params[i].Value
+	for i, p := range params {
+		var arg interface{}
+		if p.Out {
+			v := reflect.ValueOf(p.Value)
+			if v.Kind() != reflect.Ptr || v.IsNil() {
+				return nil, errors.Errorf("output parameter %s must be a non-nil pointer", paramName(i, p))
+			}
+			arg = sql.Out{Dest: p.Value}
+		} else {
+			v, err := paramValue(truncLongText, i, p)
+			if err != nil {
+				return nil, err
+			}
+			arg = v
+		}
+		if name := strings.TrimLeft(p.Name, "@:$"); len(name) != 0 {
+			arg = sql.Named(name, arg)
+		}
+		out[i] = arg
+	}
+	return out, nil
+}
+
+// paramValue returns the input value of the parameter, converted to match
+// the parameter type and checked against the parameter length.
+func paramValue(truncLongText bool, index int, p rdb.Param) (interface{}, error) {
+	value := p.Value
+	if r, is := value.(io.Reader); is {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read parameter %s", paramName(index, p))
+		}
+		value = b
+	}
+	if valuer, is := value.(driver.Valuer); is {
+		v, err := valuer.Value()
+		if err != nil {
+			return nil, errors.Wrapf(err, "parameter %s value", paramName(index, p))
+		}
+		value = v
 	}
-	return out
+	generic := p.Type.GenericOf()
+	switch v := value.(type) {
+	case string:
+		if generic == rdb.Binary {
+			value = []byte(v)
+		}
+	case []byte:
+		if generic == rdb.Text {
+			value = string(v)
+		}
+	}
+	if p.Length <= 0 {
+		return value, nil
+	}
+	switch v := value.(type) {
+	case string:
+		if utf8.RuneCountInString(v) <= p.Length {
+			break
+		}
+		if !truncLongText {
+			return nil, errors.Errorf("parameter %s text longer than length %d", paramName(index, p), p.Length)
+		}
+		n := 0
+		for i := range v {
+			if n == p.Length {
+				value = v[:i]
+				break
+			}
+			n++
+		}
+	case []byte:
+		if len(v) <= p.Length {
+			break
+		}
+		if !truncLongText {
+			return nil, errors.Errorf("parameter %s binary longer than length %d", paramName(index, p), p.Length)
+		}
+		value = v[:p.Length]
+	}
+	return value, nil
+}
+
+// paramName names the parameter in error messages.
+func paramName(index int, p rdb.Param) string {
+	if len(p.Name) != 0 {
+		return strconv.Quote(p.Name)
+	}
+	return strconv.Itoa(index)
 }
 
 // Query sends a database query.
@@ -164,53 +519,64 @@ func (p *Pool) Query(ctx context.Context, cmd *rdb.Command, params ...rdb.Param)
 	if err := ctx.Err(); err != nil {
 		return &next{err: err}
 	}
-	rows, err := p.DB.Query(cmd.SQL, makeArgs(cmd.TruncLongText, params)...)
-	if cerr := ctx.Err(); cerr != nil {
-		rows.Close()
-		err = cerr
+	args, err := makeArgs(p.TypeDefaults, cmd.Validate, cmd.TruncLongText, params)
+	if err != nil {
+		return &next{err: err}
 	}
-	n := &next{err: err, rows: rows, ctx: ctx, textAsBytes: cmd.TextAsBytes}
+	rows, err := p.DB.QueryContext(ctx, cmd.SQL, args...)
+	n := &next{err: err, rows: rows, ctx: ctx, textAsBytes: cmd.TextAsBytes, numeric: p.DecimalNumeric, driverName: p.DriverName, query: cmd.SQL}
 	n.init()
 	return n
 }
 
+// Prepare a statement. The statement is closed when ctx is cancelled.
 func (p *Pool) Prepare(ctx context.Context, cmd *rdb.Command) (rdb.Statement, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	s, err := p.DB.Prepare(cmd.SQL)
+	s, err := p.DB.PrepareContext(ctx, cmd.SQL)
 	if err != nil {
-		return nil, err
+		return nil, translateError(p.DriverName, cmd.SQL, err)
 	}
-	if err := ctx.Err(); err != nil {
+	go func() {
+		<-ctx.Done()
 		s.Close()
-		return nil, err
-	}
+	}()
 	st := &statement{
 		ctx:  ctx,
 		stmt: s,
 
 		truncateLongText: cmd.TruncLongText,
+		validate:         cmd.Validate,
 		textAsBytes:      cmd.TextAsBytes,
+		numeric:          p.DecimalNumeric,
+		driverName:       p.DriverName,
+		query:            cmd.SQL,
+		types:            p.TypeDefaults,
 	}
 	return st, nil
 }
 
-// Begin starts a transaction.
+// Begin starts a transaction. The transaction is rolled back if ctx
+// is cancelled before it is committed.
 func (p *Pool) Begin(ctx context.Context, iso rdb.Isolation) (rdb.Transaction, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	tx, err := p.DB.Begin()
+	level, err := isolationLevel(iso)
 	if err != nil {
 		return nil, err
 	}
-	if err := ctx.Err(); err != nil {
-		return nil, err
+	tx, err := p.DB.BeginTx(ctx, &sql.TxOptions{Isolation: level})
+	if err != nil {
+		return nil, translateError(p.DriverName, "", err)
 	}
 	t := &transaction{
-		ctx: ctx,
-		tx:  tx,
+		ctx:        ctx,
+		tx:         tx,
+		numeric:    p.DecimalNumeric,
+		driverName: p.DriverName,
+		types:      p.TypeDefaults,
 	}
 	return t, nil
 }
@@ -220,14 +586,34 @@ func (p *Pool) Close() {
 	p.DB.Close()
 }
 
-// Connection is not supported for database/sql drivers.
+// Connection returns a dedicated connection from the pool. The connection is
+// returned to the pool when Close is called or ctx is cancelled.
 func (p *Pool) Connection(ctx context.Context) (rdb.Connection, error) {
-	return nil, errNotSupported
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return nil, translateError(p.DriverName, "", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c := &connection{
+		conn:       conn,
+		cancel:     cancel,
+		numeric:    p.DecimalNumeric,
+		driverName: p.DriverName,
+		types:      p.TypeDefaults,
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	return c, nil
 }
 
 // Ping the server to ensure it is alive.
 func (p *Pool) Ping(ctx context.Context) error {
-	return p.DB.Ping()
+	return translateError(p.DriverName, "", p.DB.PingContext(ctx))
 }
 
 // Status returns the number of connections in the pool.
@@ -235,13 +621,13 @@ func (p *Pool) Status() rdb.PoolStatus {
 	return p
 }
 
-// Capacity returns the same as Available for database/sql drivers.
+// Capacity returns the maximum number of open connections.
+// Zero means the number of connections is not limited.
 func (p *Pool) Capacity() int {
-	// No way to get true capacity.
-	return p.DB.Stats().OpenConnections
+	return p.DB.Stats().MaxOpenConnections
 }
 
-// Available open connections.
+// Available returns the number of idle connections.
 func (p *Pool) Available() int {
-	return p.DB.Stats().OpenConnections
+	return p.DB.Stats().Idle
 }
@@ -0,0 +1,285 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+var fakeMessages = fakeSet{
+	cols: []string{"ID", "Msg"},
+	rows: [][]driver.Value{
+		{int64(1), []byte("a")},
+		{int64(2), []byte("bb")},
+	},
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	p := newFakePool(fakeName, &fakeDB{sets: []fakeSet{fakeMessages}})
+	defer p.Close()
+
+	nx := p.Query(ctx, &rdb.Command{SQL: "select"})
+	defer nx.Close()
+
+	res, err := nx.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &bytes.Buffer{}
+	var id int64
+	res.Prep("Msg", msg).Prepx(0, &id)
+
+	var ids []int64
+	for {
+		row, err := res.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row == nil {
+			break
+		}
+		if row.Getx(0) != nil || row.Get("Msg") != nil {
+			t.Errorf("prepared values returned in row: %v, %v", row.Getx(0), row.Get("Msg"))
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("got ids %v", ids)
+	}
+	if msg.String() != "abb" {
+		t.Errorf("got message %q", msg.String())
+	}
+	if row, err := res.Scan(); row != nil || err != nil {
+		t.Errorf("scan after last row: got %v, %v", row, err)
+	}
+}
+
+func TestScanValues(t *testing.T) {
+	ctx := context.Background()
+	p := newFakePool(fakeName, &fakeDB{sets: []fakeSet{fakeMessages}})
+	defer p.Close()
+
+	nx := p.Query(ctx, &rdb.Command{SQL: "select"})
+	defer nx.Close()
+
+	res, err := nx.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := res.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.Getx(0) != int64(1) || string(row.Get("Msg").([]byte)) != "a" {
+		t.Errorf("got %v, %v", row.Getx(0), row.Get("Msg"))
+	}
+	if schema := res.Schema(); len(schema) != 2 || schema[1].Name != "Msg" || schema[1].Index != 1 {
+		t.Errorf("got schema %+v", schema)
+	}
+}
+
+func TestScanPrepMissingColumn(t *testing.T) {
+	ctx := context.Background()
+	p := newFakePool(fakeName, &fakeDB{sets: []fakeSet{fakeMessages}})
+	defer p.Close()
+
+	nx := p.Query(ctx, &rdb.Command{SQL: "select"})
+	defer nx.Close()
+
+	res, err := nx.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v string
+	if _, err = res.Prep("Missing", &v).Scan(); err == nil {
+		t.Fatal("expected error for a missing prepared column")
+	}
+}
+
+func TestBufferSet(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeDB{sets: []fakeSet{
+		fakeMessages,
+		{cols: []string{"X"}},
+		{cols: []string{"Y"}, rows: [][]driver.Value{{"z"}}},
+	}}
+	p := newFakePool(fakeName, db)
+	defer p.Close()
+
+	set, err := p.Query(ctx, &rdb.Command{SQL: "select"}).BufferSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 3 {
+		t.Fatalf("got %d buffers, want 3", len(set))
+	}
+	for i, n := range []int{2, 0, 1} {
+		if len(set[i].Row) != n {
+			t.Errorf("buffer %d: got %d rows, want %d", i, len(set[i].Row), n)
+		}
+	}
+	if set[1].Schema[0].Name != "X" || set[2].Row[0].Get("Y") != "z" {
+		t.Errorf("got %+v, %v", set[1].Schema, set[2].Row[0].Get("Y"))
+	}
+
+	nx := p.Query(ctx, &rdb.Command{SQL: "select"})
+	defer nx.Close()
+	buf, err := nx.Buffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf.Row) != 2 || buf.Row[1].Getx(0) != int64(2) {
+		t.Errorf("first buffer: got %v", buf.Row)
+	}
+	rest, err := nx.BufferSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 2 {
+		t.Errorf("got %d remaining buffers, want 2", len(rest))
+	}
+	if buf, err = nx.Buffer(); buf != nil || err != nil {
+		t.Errorf("buffer after last result: got %v, %v", buf, err)
+	}
+}
+
+func TestBufferQueryError(t *testing.T) {
+	ctx := context.Background()
+	p := newFakePool(fakeName, &fakeDB{err: errors.New("no table")})
+	defer p.Close()
+
+	set, err := p.Query(ctx, &rdb.Command{SQL: "select"}).BufferSet()
+	if err == nil || len(set) != 0 {
+		t.Fatalf("got %v, %v", set, err)
+	}
+}
+
+func TestConnection(t *testing.T) {
+	ctx := context.Background()
+	p := newFakePool(fakeName, &fakeDB{sets: []fakeSet{fakeMessages}})
+	defer p.Close()
+	p.DB.SetMaxOpenConns(1)
+
+	conn, err := p.Connection(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := conn.Query(ctx, &rdb.Command{SQL: "select"}).Buffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf.Row) != 2 {
+		t.Errorf("got %d rows, want 2", len(buf.Row))
+	}
+	if n := p.Status().Available(); n != 0 {
+		t.Errorf("connection in use: got %d available", n)
+	}
+	conn.Close()
+	if n := p.Status().Available(); n != 1 {
+		t.Errorf("connection closed: got %d available, want 1", n)
+	}
+
+	connCtx, cancel := context.WithCancel(ctx)
+	if _, err = p.Connection(connCtx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	for start := time.Now(); p.Status().Available() != 1; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("connection not returned when the context was cancelled")
+		}
+	}
+}
+
+func TestMakeArgs(t *testing.T) {
+	var out int64
+	list := []struct {
+		name     string
+		param    rdb.Param
+		truncate bool
+		want     interface{}
+	}{
+		{"positional", rdb.Param{Value: 5}, false, 5},
+		{"named", rdb.Param{Name: "@id", Value: 5}, false, sql.Named("id", 5)},
+		{"named colon", rdb.Param{Name: ":id", Value: "a"}, false, sql.Named("id", "a")},
+		{"output", rdb.Param{Name: "n", Out: true, Value: &out}, false, sql.Named("n", sql.Out{Dest: &out})},
+		{"text as binary", rdb.Param{Type: rdb.TypeBinary, Value: "ab"}, false, []byte("ab")},
+		{"binary as text", rdb.Param{Type: rdb.TypeVarChar, Value: []byte("ab")}, false, "ab"},
+		{"reader", rdb.Param{Value: strings.NewReader("ab")}, false, []byte("ab")},
+		{"valuer", rdb.Param{Value: rdb.NullInteger{Int64: 3, Valid: true}}, false, int64(3)},
+		{"truncate text", rdb.Param{Value: "héllo", Length: 2}, true, "hé"},
+		{"truncate binary", rdb.Param{Value: []byte("hello"), Length: 2}, true, []byte("he")},
+	}
+	for _, item := range list {
+		args, err := makeArgs(rdb.TypeDefaults{}, false, item.truncate, []rdb.Param{item.param})
+		if err != nil {
+			t.Errorf("%s: %v", item.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(args[0], item.want) {
+			t.Errorf("%s: got %#v, want %#v", item.name, args[0], item.want)
+		}
+	}
+}
+
+func TestMakeArgsInvalid(t *testing.T) {
+	var out int64
+	list := []struct {
+		name     string
+		param    rdb.Param
+		validate bool
+	}{
+		{"output not a pointer", rdb.Param{Out: true, Value: out}, false},
+		{"output nil", rdb.Param{Out: true, Value: (*int64)(nil)}, false},
+		{"text too long", rdb.Param{Value: "hello", Length: 2}, false},
+		{"binary too long", rdb.Param{Value: []byte("hello"), Length: 2}, false},
+		{"validate", rdb.Param{Type: rdb.TypeInt8, Value: 300}, true},
+	}
+	for _, item := range list {
+		if _, err := makeArgs(rdb.TypeDefaults{}, item.validate, false, []rdb.Param{item.param}); err == nil {
+			t.Errorf("%s: expected error", item.name)
+		}
+	}
+}
+
+func TestNamedParams(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeDB{}
+	p := newFakePool(fakeName, db)
+	defer p.Close()
+
+	var out string
+	err := p.Query(ctx, &rdb.Command{SQL: "exec"},
+		rdb.Param{Value: 1},
+		rdb.Param{Name: "@name", Value: "a"},
+		rdb.Param{Name: "@out", Out: true, Value: &out},
+	).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.args) != 3 {
+		t.Fatalf("got %d arguments, want 3", len(db.args))
+	}
+	if a := db.args[0]; a.Ordinal != 1 || len(a.Name) != 0 || a.Value != 1 {
+		t.Errorf("positional: got %+v", a)
+	}
+	if a := db.args[1]; a.Name != "name" || a.Value != "a" {
+		t.Errorf("named: got %+v", a)
+	}
+	if a := db.args[2]; a.Name != "out" || a.Value != (sql.Out{Dest: &out}) {
+		t.Errorf("output: got %+v", a)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+	"golang.org/x/net/context"
+)
+
+// waitFor waits for the last statement run on db to be want.
+func waitFor(t *testing.T, db *fakeDB, want string) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		run := db.statements()
+		if len(run) != 0 && run[len(run)-1] == want {
+			return
+		}
+	}
+	t.Fatalf("got statements %q, want last %q", db.statements(), want)
+}
+
+func TestIsolationLevel(t *testing.T) {
+	list := []struct {
+		iso  rdb.Isolation
+		want sql.IsolationLevel
+	}{
+		{rdb.IsoDefault, sql.LevelDefault},
+		{rdb.IsoReadUncommited, sql.LevelReadUncommitted},
+		{rdb.IsoReadCommited, sql.LevelReadCommitted},
+		{rdb.IsoWriteCommited, sql.LevelWriteCommitted},
+		{rdb.IsoRepeatableRead, sql.LevelRepeatableRead},
+		{rdb.IsoSerializable, sql.LevelSerializable},
+		{rdb.IsoSnapshot, sql.LevelSnapshot},
+		{rdb.IsoLinearizable, sql.LevelLinearizable},
+	}
+	for _, item := range list {
+		got, err := isolationLevel(item.iso)
+		if err != nil {
+			t.Errorf("%d: %v", item.iso, err)
+			continue
+		}
+		if got != item.want {
+			t.Errorf("%d: got %v, want %v", item.iso, got, item.want)
+		}
+		back, err := rdbIsolation(got)
+		if err != nil || back != item.iso {
+			t.Errorf("%v: got %d, %v, want %d", got, back, err, item.iso)
+		}
+	}
+	if _, err := isolationLevel(rdb.Isolation(99)); err == nil {
+		t.Error("expected error for an unknown isolation level")
+	}
+}
+
+func TestBeginIsolation(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeDB{}
+	p := newFakePool(fakeName, db)
+	defer p.Close()
+
+	tx, err := p.Begin(ctx, rdb.IsoSerializable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.iso) != 1 || db.iso[0] != driver.IsolationLevel(sql.LevelSerializable) {
+		t.Errorf("got isolation %v", db.iso)
+	}
+	if _, err = p.Begin(ctx, rdb.Isolation(99)); err == nil {
+		t.Error("expected error for an unknown isolation level")
+	}
+}
+
+func TestBeginCancelRollback(t *testing.T) {
+	db := &fakeDB{}
+	p := newFakePool(fakeName, db)
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := p.Begin(ctx, rdb.IsoDefault); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	waitFor(t, db, "ROLLBACK")
+}
+
+func TestQueryCancelled(t *testing.T) {
+	db := &fakeDB{sets: []fakeSet{fakeMessages}}
+	p := newFakePool(fakeName, db)
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Query(ctx, &rdb.Command{SQL: "select"}).BufferSet(); err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if _, err := p.Begin(ctx, rdb.IsoDefault); err != context.Canceled {
+		t.Errorf("begin: got %v, want %v", err, context.Canceled)
+	}
+	if run := db.statements(); len(run) != 0 {
+		t.Errorf("cancelled calls ran %q", run)
+	}
+}
+
+type markDialect struct{}
+
+func (markDialect) SavePoint(name string) string {
+	return "MARK " + name
+}
+func (markDialect) RollbackTo(name string) string {
+	return "UNDO " + name
+}
+
+func TestSavePoint(t *testing.T) {
+	RegisterDialect("rdbfake-dialect", markDialect{})
+
+	list := []struct {
+		driver   string
+		save     string
+		rollback string
+	}{
+		{"postgres", "SAVEPOINT a1", "ROLLBACK TO SAVEPOINT a1"},
+		{"sqlite", "SAVEPOINT a1", "ROLLBACK TO SAVEPOINT a1"},
+		{"sqlserver", "SAVE TRANSACTION a1", "ROLLBACK TRANSACTION a1"},
+		{"rdbfake-dialect", "MARK a1", "UNDO a1"},
+	}
+	ctx := context.Background()
+	for _, item := range list {
+		db := &fakeDB{}
+		p := newFakePool(item.driver, db)
+		tx, err := p.Begin(ctx, rdb.IsoDefault)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = tx.SavePoint(ctx, "a1"); err != nil {
+			t.Errorf("%s: %v", item.driver, err)
+		}
+		if err = tx.RollbackTo(ctx, "a1"); err != nil {
+			t.Errorf("%s: %v", item.driver, err)
+		}
+		if err = tx.Commit(ctx); err != nil {
+			t.Errorf("%s: %v", item.driver, err)
+		}
+		want := []string{"BEGIN", item.save, item.rollback, "COMMIT"}
+		if got := db.statements(); strings.Join(got, ";") != strings.Join(want, ";") {
+			t.Errorf("%s: got %q, want %q", item.driver, got, want)
+		}
+		p.Close()
+	}
+}
+
+func TestSavePointInvalid(t *testing.T) {
+	ctx := context.Background()
+	list := []struct {
+		driver, name string
+	}{
+		{"postgres", ""},
+		{"postgres", "1a"},
+		{"postgres", "a; drop table t"},
+		{"postgres", "a-b"},
+		{fakeName, "a"},
+	}
+	for _, item := range list {
+		db := &fakeDB{}
+		p := newFakePool(item.driver, db)
+		tx, err := p.Begin(ctx, rdb.IsoDefault)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = tx.SavePoint(ctx, item.name); err == nil {
+			t.Errorf("%s %q: expected error", item.driver, item.name)
+		}
+		if run := db.statements(); len(run) != 1 {
+			t.Errorf("%s %q: got statements %q", item.driver, item.name, run)
+		}
+		p.Close()
+	}
+}
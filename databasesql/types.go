@@ -0,0 +1,247 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"database/sql"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/kardianos/rdb"
+)
+
+var (
+	typeSync   = sync.RWMutex{}
+	typeDriver = map[string]map[string]rdb.Type{
+		"postgres":  pgTypes,
+		"pgx":       pgTypes,
+		"mysql":     myTypes,
+		"mssql":     msTypes,
+		"sqlserver": msTypes,
+		"sqlite3":   liteTypes,
+		"sqlite":    liteTypes,
+	}
+
+	// typeCommon maps database type names common to many systems.
+	typeCommon = map[string]rdb.Type{
+		"text":       rdb.TypeText,
+		"ntext":      rdb.TypeText,
+		"tinytext":   rdb.TypeText,
+		"mediumtext": rdb.TypeText,
+		"longtext":   rdb.TypeText,
+		"citext":     rdb.TypeText,
+		"varchar":    rdb.TypeAnsiVarChar,
+		"nvarchar":   rdb.TypeVarChar,
+		"char":       rdb.TypeAnsiChar,
+		"nchar":      rdb.TypeChar,
+
+		"binary":     rdb.TypeBinary,
+		"varbinary":  rdb.TypeBinary,
+		"image":      rdb.TypeBinary,
+		"bytea":      rdb.TypeBinary,
+		"blob":       rdb.TypeBinary,
+		"tinyblob":   rdb.TypeBinary,
+		"mediumblob": rdb.TypeBinary,
+		"longblob":   rdb.TypeBinary,
+
+		"bool":    rdb.TypeBool,
+		"boolean": rdb.TypeBool,
+
+		"tinyint":            rdb.TypeInt8,
+		"smallint":           rdb.TypeInt16,
+		"int2":               rdb.TypeInt16,
+		"mediumint":          rdb.TypeInt32,
+		"int":                rdb.TypeInt32,
+		"integer":            rdb.TypeInt32,
+		"int4":               rdb.TypeInt32,
+		"bigint":             rdb.TypeInt64,
+		"int8":               rdb.TypeInt64,
+		"unsigned tinyint":   rdb.TypeUint8,
+		"unsigned smallint":  rdb.TypeUint16,
+		"unsigned mediumint": rdb.TypeUint32,
+		"unsigned int":       rdb.TypeUint32,
+		"unsigned bigint":    rdb.TypeUint64,
+
+		"smallserial": rdb.TypeSerial16,
+		"serial2":     rdb.TypeSerial16,
+		"serial":      rdb.TypeSerial32,
+		"serial4":     rdb.TypeSerial32,
+		"bigserial":   rdb.TypeSerial64,
+		"serial8":     rdb.TypeSerial64,
+
+		"real":             rdb.TypeFloat32,
+		"float4":           rdb.TypeFloat32,
+		"float":            rdb.TypeFloat64,
+		"float8":           rdb.TypeFloat64,
+		"double":           rdb.TypeFloat64,
+		"double precision": rdb.TypeFloat64,
+
+		"decimal":    rdb.TypeDecimal,
+		"numeric":    rdb.TypeDecimal,
+		"money":      rdb.TypeMoney,
+		"smallmoney": rdb.TypeMoney,
+
+		"timestamptz":                 rdb.TypeTimestampz,
+		"timestamp with time zone":    rdb.TypeTimestampz,
+		"datetimeoffset":              rdb.TypeTimestampz,
+		"timestamp":                   rdb.TypeTimestamp,
+		"timestamp without time zone": rdb.TypeTimestamp,
+		"datetime":                    rdb.TypeTimestamp,
+		"datetime2":                   rdb.TypeTimestamp,
+		"smalldatetime":               rdb.TypeTimestamp,
+		"interval":                    rdb.TypeDuration,
+		"time":                        rdb.TypeTime,
+		"date":                        rdb.TypeDate,
+
+		"uuid":             rdb.TypeUUID,
+		"uniqueidentifier": rdb.TypeUUID,
+
+		"enum":      rdb.TypeEnum,
+		"int4range": rdb.TypeRange,
+		"int8range": rdb.TypeRange,
+		"numrange":  rdb.TypeRange,
+		"tsrange":   rdb.TypeRange,
+		"tstzrange": rdb.TypeRange,
+		"daterange": rdb.TypeRange,
+		"json":      rdb.TypeJSON,
+		"jsonb":     rdb.TypeJSON,
+		"xml":       rdb.TypeXML,
+	}
+
+	// Postgres bit strings and time with time zone have no rdb type
+	// and are left unknown.
+	pgTypes = map[string]rdb.Type{
+		"varchar":           rdb.TypeVarChar,
+		"character varying": rdb.TypeVarChar,
+		"char":              rdb.TypeChar,
+		"bpchar":            rdb.TypeChar,
+		"character":         rdb.TypeChar,
+	}
+	// MySQL character types use the column character set, most often utf8.
+	myTypes = map[string]rdb.Type{
+		"varchar": rdb.TypeVarChar,
+		"char":    rdb.TypeChar,
+	}
+	// SQL Server text is the deprecated ANSI large text type.
+	msTypes = map[string]rdb.Type{
+		"text":       rdb.TypeAnsiText,
+		"bit":        rdb.TypeBool,
+		"tinyint":    rdb.TypeUint8,
+		"timestamp":  rdb.TypeBinary,
+		"rowversion": rdb.TypeBinary,
+	}
+	liteTypes = map[string]rdb.Type{
+		"integer": rdb.TypeInt64,
+		"real":    rdb.TypeFloat64,
+	}
+)
+
+// RegisterType maps a database type name, as reported by
+// sql.ColumnType.DatabaseTypeName for the named driver, to an rdb.Type.
+// Driver mappings take precedence over the common mappings.
+// Type names are not case sensitive.
+func RegisterType(driverName, databaseTypeName string, t rdb.Type) {
+	typeSync.Lock()
+	defer typeSync.Unlock()
+
+	m, found := typeDriver[driverName]
+	if !found {
+		m = make(map[string]rdb.Type)
+		typeDriver[driverName] = m
+	}
+	m[strings.ToLower(databaseTypeName)] = t
+}
+
+// lookupType returns the rdb.Type for the database type name.
+// Unknown names return rdb.TypeUnknown.
+func lookupType(driverName, databaseTypeName string) rdb.Type {
+	name := strings.ToLower(databaseTypeName)
+
+	typeSync.RLock()
+	defer typeSync.RUnlock()
+
+	if t, found := typeDriver[driverName][name]; found {
+		return t
+	}
+	if t, found := typeCommon[name]; found {
+		return t
+	}
+	// Postgres reports array types with a leading underscore, "_int4".
+	if len(name) > 1 && name[0] == '_' {
+		return rdb.TypeArray
+	}
+	return rdb.TypeUnknown
+}
+
+// makeColumn fills a column from the column type reported by database/sql.
+func makeColumn(driverName string, index int, ct *sql.ColumnType) rdb.Column {
+	col := rdb.Column{
+		Name:  ct.Name(),
+		Index: index,
+		Type:  lookupType(driverName, ct.DatabaseTypeName()),
+	}
+	col.Generic = col.Type.GenericOf()
+	if length, ok := ct.Length(); ok {
+		if length == math.MaxInt64 || length > math.MaxInt32 {
+			col.Length = -1
+		} else {
+			col.Length = int(length)
+		}
+	}
+	if nullable, ok := ct.Nullable(); ok {
+		col.Nullable = nullable
+	}
+	if precision, scale, ok := ct.DecimalSize(); ok {
+		col.Precision = int(precision)
+		col.Scale = int(scale)
+	}
+	return col
+}
+
+// typeNames are the database type names reported for rdb types.
+var typeNames = map[rdb.Type]string{
+	rdb.TypeText:        "TEXT",
+	rdb.TypeAnsiText:    "TEXT",
+	rdb.TypeVarChar:     "NVARCHAR",
+	rdb.TypeAnsiVarChar: "VARCHAR",
+	rdb.TypeChar:        "NCHAR",
+	rdb.TypeAnsiChar:    "CHAR",
+	rdb.TypeBinary:      "VARBINARY",
+	rdb.TypeBool:        "BOOL",
+	rdb.TypeUint8:       "UNSIGNED TINYINT",
+	rdb.TypeUint16:      "UNSIGNED SMALLINT",
+	rdb.TypeUint32:      "UNSIGNED INT",
+	rdb.TypeUint64:      "UNSIGNED BIGINT",
+	rdb.TypeInt8:        "TINYINT",
+	rdb.TypeInt16:       "SMALLINT",
+	rdb.TypeInt32:       "INT",
+	rdb.TypeInt64:       "BIGINT",
+	rdb.TypeSerial16:    "SMALLSERIAL",
+	rdb.TypeSerial32:    "SERIAL",
+	rdb.TypeSerial64:    "BIGSERIAL",
+	rdb.TypeFloat32:     "REAL",
+	rdb.TypeFloat64:     "DOUBLE PRECISION",
+	rdb.TypeDecimal:     "DECIMAL",
+	rdb.TypeMoney:       "MONEY",
+	rdb.TypeTimestampz:  "TIMESTAMPTZ",
+	rdb.TypeDuration:    "INTERVAL",
+	rdb.TypeTime:        "TIME",
+	rdb.TypeDate:        "DATE",
+	rdb.TypeTimestamp:   "TIMESTAMP",
+	rdb.TypeUUID:        "UUID",
+	rdb.TypeEnum:        "ENUM",
+	rdb.TypeRange:       "RANGE",
+	rdb.TypeArray:       "ARRAY",
+	rdb.TypeJSON:        "JSON",
+	rdb.TypeXML:         "XML",
+	rdb.TypeTable:       "TABLE",
+}
+
+// databaseTypeName returns the upper case database type name for t.
+// Unknown types return an empty string.
+func databaseTypeName(t rdb.Type) string {
+	return typeNames[t]
+}
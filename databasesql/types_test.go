@@ -0,0 +1,123 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package databasesql
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/kardianos/rdb"
+	"golang.org/x/net/context"
+)
+
+func TestLookupType(t *testing.T) {
+	list := []struct {
+		driver, name string
+		want         rdb.Type
+	}{
+		{"postgres", "VARCHAR", rdb.TypeVarChar},
+		{"postgres", "bpchar", rdb.TypeChar},
+		{"postgres", "INT4", rdb.TypeInt32},
+		{"postgres", "TIMESTAMPTZ", rdb.TypeTimestampz},
+		{"postgres", "TIMETZ", rdb.TypeUnknown},
+		{"postgres", "BIT", rdb.TypeUnknown},
+		{"postgres", "_INT4", rdb.TypeArray},
+		{"pgx", "numeric", rdb.TypeDecimal},
+		{"mysql", "VARCHAR", rdb.TypeVarChar},
+		{"mysql", "CHAR", rdb.TypeChar},
+		{"mysql", "TINYINT", rdb.TypeInt8},
+		{"mysql", "UNSIGNED BIGINT", rdb.TypeUint64},
+		{"mysql", "BIT", rdb.TypeUnknown},
+		{"sqlserver", "VARCHAR", rdb.TypeAnsiVarChar},
+		{"sqlserver", "NVARCHAR", rdb.TypeVarChar},
+		{"sqlserver", "BIT", rdb.TypeBool},
+		{"mssql", "TINYINT", rdb.TypeUint8},
+		{"mssql", "TEXT", rdb.TypeAnsiText},
+		{"sqlserver", "text", rdb.TypeAnsiText},
+		{"postgres", "TEXT", rdb.TypeText},
+		{"mssql", "TIMESTAMP", rdb.TypeBinary},
+		{"sqlite3", "INTEGER", rdb.TypeInt64},
+		{"sqlite", "REAL", rdb.TypeFloat64},
+		{"other", "TIME", rdb.TypeTime},
+		{"other", "unknown", rdb.TypeUnknown},
+		{"other", "_", rdb.TypeUnknown},
+	}
+	for _, item := range list {
+		if got := lookupType(item.driver, item.name); got != item.want {
+			t.Errorf("%s %s: got %v, want %v", item.driver, item.name, got, item.want)
+		}
+	}
+}
+
+func TestRegisterType(t *testing.T) {
+	RegisterType("rdbfake-types", "Money64", rdb.TypeMoney)
+	if got := lookupType("rdbfake-types", "MONEY64"); got != rdb.TypeMoney {
+		t.Errorf("got %v, want %v", got, rdb.TypeMoney)
+	}
+	if got := lookupType("rdbfake-types", "int"); got != rdb.TypeInt32 {
+		t.Errorf("common type: got %v, want %v", got, rdb.TypeInt32)
+	}
+}
+
+func TestSchemaTypes(t *testing.T) {
+	ctx := context.Background()
+	p := newFakePool("postgres", &fakeDB{sets: []fakeSet{{
+		cols:  []string{"ID", "Name", "Total", "Other"},
+		types: []string{"INT8", "VARCHAR", "NUMERIC", "TIMETZ"},
+		rows:  [][]driver.Value{{int64(1), "a", []byte("12.50"), "10:00:00+02"}},
+	}}})
+	defer p.Close()
+
+	buf, err := p.Query(ctx, &rdb.Command{SQL: "select"}).Buffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		t, generic rdb.Type
+	}{
+		{rdb.TypeInt64, rdb.Integer},
+		{rdb.TypeVarChar, rdb.Text},
+		{rdb.TypeDecimal, rdb.Decimal},
+		{rdb.TypeUnknown, rdb.TypeUnknown},
+	}
+	for i, w := range want {
+		col := buf.Schema[i]
+		if col.Type != w.t || col.Generic != w.generic {
+			t.Errorf("%s: got %v (%v), want %v (%v)", col.Name, col.Type, col.Generic, w.t, w.generic)
+		}
+	}
+	if v, ok := buf.Row[0].Get("Total").([]byte); !ok || string(v) != "12.50" {
+		t.Errorf("decimal driver value: got %#v", buf.Row[0].Get("Total"))
+	}
+	var total rdb.Numeric
+	if err = buf.Row[0].Into("Total", &total).(*rdb.DataRow).Err(); err != nil || total.String() != "12.50" {
+		t.Errorf("decimal into numeric: got %s, %v", total, err)
+	}
+
+	p.DecimalNumeric = true
+	buf, err = p.Query(ctx, &rdb.Command{SQL: "select"}).Buffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := buf.Row[0].Get("Total").(rdb.Numeric); !ok || d.String() != "12.50" {
+		t.Errorf("decimal numeric value: got %#v", buf.Row[0].Get("Total"))
+	}
+}
+
+func TestDatabaseTypeName(t *testing.T) {
+	for _, tp := range []rdb.Type{rdb.TypeVarChar, rdb.TypeInt64, rdb.TypeDecimal, rdb.TypeTimestampz} {
+		name := databaseTypeName(tp)
+		if len(name) == 0 {
+			t.Errorf("%v: missing name", tp)
+			continue
+		}
+		if got := lookupType("postgres", name); got != tp {
+			t.Errorf("%v: name %s looks up %v", tp, name, got)
+		}
+	}
+	if name := databaseTypeName(rdb.TypeUnknown); len(name) != 0 {
+		t.Errorf("unknown type: got %q", name)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// JSON is an encoded JSON document for TypeJSON values.
+// A nil JSON is null.
+type JSON []byte
+
+// NewJSON returns the JSON encoding of v.
+func NewJSON(v interface{}) (JSON, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return JSON(b), nil
+}
+
+// Unmarshal decodes the document into the value pointed to by v.
+func (j JSON) Unmarshal(v interface{}) error {
+	return json.Unmarshal(j, v)
+}
+
+// set sets j to a copy of data if it is a valid document.
+func (j *JSON) set(data []byte) error {
+	if !json.Valid(data) {
+		return fmt.Errorf("Invalid JSON document")
+	}
+	*j = append((*j)[:0:0], data...)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The document is written as is.
+// An empty document is written as null.
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return jsonNull, nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	return j.set(data)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (j JSON) MarshalText() ([]byte, error) {
+	return j.MarshalJSON()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (j *JSON) UnmarshalText(text []byte) error {
+	return j.set(text)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+// The binary form is the same as the text form.
+func (j JSON) MarshalBinary() ([]byte, error) {
+	return j.MarshalJSON()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (j *JSON) UnmarshalBinary(data []byte) error {
+	return j.set(data)
+}
+
+// Scan implements sql.Scanner.
+func (j *JSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+		return nil
+	case []byte:
+		return j.set(v)
+	case string:
+		return j.set([]byte(v))
+	}
+	return fmt.Errorf("Cannot convert %T to rdb.JSON", value)
+}
+
+// Value implements driver.Valuer. The document is sent as text.
+func (j JSON) Value() (driver.Value, error) {
+	if j == nil {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// XML is an encoded XML document or fragment for TypeXML values.
+// A nil XML is null.
+type XML []byte
+
+// NewXML returns the XML encoding of v.
+func NewXML(v interface{}) (XML, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return XML(b), nil
+}
+
+// Unmarshal decodes the document into the value pointed to by v.
+func (x XML) Unmarshal(v interface{}) error {
+	return xml.Unmarshal(x, v)
+}
+
+// set sets x to a copy of data if it is well formed.
+func (x *XML) set(data []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Invalid XML document: %v", err)
+		}
+	}
+	*x = append((*x)[:0:0], data...)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (x XML) MarshalText() ([]byte, error) {
+	return x, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (x *XML) UnmarshalText(text []byte) error {
+	return x.set(text)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+// The binary form is the same as the text form.
+func (x XML) MarshalBinary() ([]byte, error) {
+	return x, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (x *XML) UnmarshalBinary(data []byte) error {
+	return x.set(data)
+}
+
+// Scan implements sql.Scanner.
+func (x *XML) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*x = nil
+		return nil
+	case []byte:
+		return x.set(v)
+	case string:
+		return x.set([]byte(v))
+	}
+	return fmt.Errorf("Cannot convert %T to rdb.XML", value)
+}
+
+// Value implements driver.Valuer. The document is sent as text.
+func (x XML) Value() (driver.Value, error) {
+	if x == nil {
+		return nil, nil
+	}
+	return string(x), nil
+}
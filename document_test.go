@@ -0,0 +1,140 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kardianos/rdb"
+)
+
+func TestJSONScan(t *testing.T) {
+	list := []struct {
+		src  interface{}
+		want rdb.JSON
+		ok   bool
+	}{
+		{`{"a":1}`, rdb.JSON(`{"a":1}`), true},
+		{[]byte(`[1, 2]`), rdb.JSON(`[1, 2]`), true},
+		{`"text"`, rdb.JSON(`"text"`), true},
+		{nil, nil, true},
+		{`{bad`, nil, false},
+		{``, nil, false},
+		{int64(1), nil, false},
+	}
+	for _, item := range list {
+		var j rdb.JSON
+		err := j.Scan(item.src)
+		if !item.ok {
+			if err == nil {
+				t.Errorf("%v: expected error", item.src)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(j, item.want) {
+			t.Errorf("%v: got %s, %v", item.src, j, err)
+		}
+	}
+
+	src := []byte(`{"a":1}`)
+	var j rdb.JSON
+	if err := j.Scan(src); err != nil {
+		t.Fatal(err)
+	}
+	src[0] = 'x'
+	if string(j) != `{"a":1}` {
+		t.Error("scanned bytes were not copied")
+	}
+}
+
+func TestJSONEncoding(t *testing.T) {
+	type doc struct {
+		A int
+		B []string
+	}
+	j, err := rdb.NewJSON(doc{A: 1, B: []string{"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(j) != `{"A":1,"B":["x"]}` {
+		t.Errorf("NewJSON: got %s", j)
+	}
+	var d doc
+	if err = j.Unmarshal(&d); err != nil || d.A != 1 || len(d.B) != 1 {
+		t.Errorf("Unmarshal: got %+v, %v", d, err)
+	}
+	if v, err := j.Value(); err != nil || v != `{"A":1,"B":["x"]}` {
+		t.Errorf("value: got %v, %v", v, err)
+	}
+	if v, err := rdb.JSON(nil).Value(); err != nil || v != nil {
+		t.Errorf("null value: got %v, %v", v, err)
+	}
+
+	// The document is embedded as is. An empty document is null.
+	data, err := json.Marshal(struct {
+		J rdb.JSON
+		N rdb.JSON
+		E rdb.JSON
+	}{J: rdb.JSON(`[1]`), E: rdb.JSON{}})
+	if err != nil || string(data) != `{"J":[1],"N":null,"E":null}` {
+		t.Errorf("marshal: got %s, %v", data, err)
+	}
+	var back struct{ J rdb.JSON }
+	if err = json.Unmarshal([]byte(`{"J": {"b": true}}`), &back); err != nil || string(back.J) != `{"b": true}` {
+		t.Errorf("unmarshal: got %s, %v", back.J, err)
+	}
+	if err = j.UnmarshalText([]byte("{")); err == nil {
+		t.Error("UnmarshalText: expected error")
+	}
+}
+
+func TestXML(t *testing.T) {
+	list := []struct {
+		src  interface{}
+		want rdb.XML
+		ok   bool
+	}{
+		{`<a>1</a>`, rdb.XML(`<a>1</a>`), true},
+		{[]byte(`<a>1</a><b/>`), rdb.XML(`<a>1</a><b/>`), true},
+		{`text`, rdb.XML(`text`), true},
+		{nil, nil, true},
+		{`<a><b></a>`, nil, false},
+		{`<a>`, nil, false},
+		{int64(1), nil, false},
+	}
+	for _, item := range list {
+		var x rdb.XML
+		err := x.Scan(item.src)
+		if !item.ok {
+			if err == nil {
+				t.Errorf("%v: expected error", item.src)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(x, item.want) {
+			t.Errorf("%v: got %s, %v", item.src, x, err)
+		}
+	}
+
+	type doc struct {
+		Name string `xml:"name"`
+	}
+	x, err := rdb.NewXML(doc{Name: "a"})
+	if err != nil || string(x) != `<doc><name>a</name></doc>` {
+		t.Fatalf("NewXML: got %s, %v", x, err)
+	}
+	var d doc
+	if err = x.Unmarshal(&d); err != nil || d.Name != "a" {
+		t.Errorf("Unmarshal: got %+v, %v", d, err)
+	}
+	if v, err := x.Value(); err != nil || v != `<doc><name>a</name></doc>` {
+		t.Errorf("value: got %v, %v", v, err)
+	}
+	if v, err := rdb.XML(nil).Value(); err != nil || v != nil {
+		t.Errorf("null value: got %v, %v", v, err)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+// Package driver provides a connection pool that implements rdb.Pool for
+// drivers that only implement a single connection.
+//
+// A driver implements Connector and Conn to speak the wire protocol, then
+// registers an opener that calls Open:
+//
+//	type opener struct{}
+//
+//	func (opener) CanOpen(config *rdb.Config) bool {
+//		return config.DriverName == "mydb"
+//	}
+//	func (opener) Open(ctx context.Context, config *rdb.Config) (rdb.Pool, error) {
+//		return driver.Open(ctx, config, &connector{config: config})
+//	}
+//
+//	func init() {
+//		rdb.RegisterOpener(opener{})
+//	}
+//
+// The pool handles connection reuse, capacity, idle timeouts, context
+// cancellation, and reading results into rdb.Result values.
+package driver // import "github.com/kardianos/rdb/driver"
+
+import (
+	"errors"
+
+	"github.com/kardianos/rdb"
+	"golang.org/x/net/context"
+)
+
+// ErrBadConn should be returned by a Conn method if the connection is no
+// longer usable. The pool closes the connection rather then reusing it.
+var ErrBadConn = errors.New("driver: bad connection")
+
+// Connector creates new connections.
+type Connector interface {
+	// Connect opens a new connection. The connection must not use ctx
+	// after Connect returns.
+	Connect(ctx context.Context) (Conn, error)
+}
+
+// Conn is a single database connection. The pool never calls Conn methods
+// concurrently and does not call another method until any Rows returned
+// from Query are closed.
+//
+// When the ctx passed to a method is cancelled the method, and for Query
+// any calls on the returned Rows, should return as soon as possible with
+// an error.
+type Conn interface {
+	// Query sends the command to the server. The params have been
+	// resolved with rdb.ResolveParams using the config TypeDefaults,
+	// and checked with rdb.ValidateParams if the command Validate is set.
+	Query(ctx context.Context, cmd *rdb.Command, params []rdb.Param) (Rows, error)
+
+	// Begin a transaction with the isolation level. Return an error
+	// if the isolation level is not supported.
+	Begin(ctx context.Context, iso rdb.Isolation) error
+
+	// Commit the current transaction.
+	Commit(ctx context.Context) error
+
+	// Rollback the current transaction.
+	Rollback(ctx context.Context) error
+
+	// SavePoint creates a save point in the current transaction.
+	SavePoint(ctx context.Context, name string) error
+
+	// RollbackTo rolls back the current transaction to the save point.
+	RollbackTo(ctx context.Context, name string) error
+
+	// Ping checks the connection is still valid.
+	Ping(ctx context.Context) error
+
+	// Close the connection.
+	Close() error
+}
+
+// Rows reads the results of a query. Rows are positioned before the first
+// result; NextResult must be called before reading the first result.
+type Rows interface {
+	// NextResult advances to the next result. It returns io.EOF when
+	// there are no more results. Errors from the command should be
+	// returned from NextResult.
+	NextResult() error
+
+	// Schema returns the columns of the current result.
+	Schema() rdb.Schema
+
+	// Next reads the next row of the current result into dest, which has
+	// one value for each column. It returns io.EOF after the last row.
+	Next(dest []interface{}) error
+
+	// Close discards any remaining results. If Close returns an error
+	// the connection is closed.
+	Close() error
+}
@@ -0,0 +1,237 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kardianos/rdb"
+	"golang.org/x/net/context"
+)
+
+// next implements rdb.Next and rdb.Result over Rows.
+type next struct {
+	mu       sync.Mutex
+	rows     Rows
+	err      error
+	done     bool // Last result has been read.
+	inResult bool
+	schema   rdb.Schema
+	values   []interface{}
+
+	prepName  map[string]interface{}
+	prepIndex map[int]interface{}
+
+	release  func(bad bool)
+	finished chan struct{}
+	once     sync.Once
+}
+
+func errNext(err error) *next {
+	return &next{err: err, done: true}
+}
+
+// newNext reads from rows. The release function is called once after the
+// last result is read, Close is called, or ctx is cancelled.
+func newNext(ctx context.Context, rows Rows, release func(bad bool)) *next {
+	n := &next{
+		rows:     rows,
+		release:  release,
+		finished: make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			n.mu.Lock()
+			if n.err == nil {
+				n.err = ctx.Err()
+			}
+			n.finish(false)
+			n.mu.Unlock()
+		case <-n.finished:
+		}
+	}()
+	return n
+}
+
+// finish closes the rows and releases the connection.
+// Must be called with mu held.
+func (n *next) finish(bad bool) {
+	if n.release == nil {
+		return
+	}
+	n.once.Do(func() {
+		n.done = true
+		n.inResult = false
+		if err := n.rows.Close(); err != nil {
+			bad = true
+		}
+		n.release(bad)
+		close(n.finished)
+	})
+}
+
+func (n *next) Result() (rdb.Result, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.err != nil {
+		return nil, n.err
+	}
+	if n.done {
+		return nil, nil
+	}
+	err := n.rows.NextResult()
+	if err == io.EOF {
+		n.finish(false)
+		return nil, nil
+	}
+	if err != nil {
+		n.err = err
+		n.finish(err == ErrBadConn)
+		return nil, err
+	}
+	n.inResult = true
+	n.schema = n.rows.Schema()
+	n.values = make([]interface{}, len(n.schema))
+	n.prepName = nil
+	n.prepIndex = nil
+	return n, nil
+}
+
+func (n *next) Buffer() (*rdb.Buffer, error) {
+	res, err := n.Result()
+	if err != nil || res == nil {
+		return nil, err
+	}
+	buf := &rdb.Buffer{
+		Schema: res.Schema(),
+	}
+	for {
+		r, err := res.Scan()
+		if err != nil {
+			return buf, err
+		}
+		if r == nil {
+			return buf, nil
+		}
+		buf.Row = append(buf.Row, r)
+	}
+}
+
+func (n *next) BufferSet() (rdb.BufferSet, error) {
+	var set rdb.BufferSet
+	for {
+		buf, err := n.Buffer()
+		if buf != nil {
+			set = append(set, buf)
+		}
+		if err != nil || buf == nil {
+			return set, err
+		}
+	}
+}
+
+// Close discards any remaining results and releases the connection.
+func (n *next) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	err := n.err
+	if n.err == nil {
+		n.err = errClosed
+	}
+	n.finish(false)
+	return err
+}
+
+func (n *next) Prep(name string, value interface{}) rdb.Result {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.prepName == nil {
+		n.prepName = make(map[string]interface{})
+	}
+	n.prepName[name] = value
+	return n
+}
+func (n *next) Prepx(index int, value interface{}) rdb.Result {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.prepIndex == nil {
+		n.prepIndex = make(map[int]interface{})
+	}
+	n.prepIndex[index] = value
+	return n
+}
+
+func (n *next) Scan() (rdb.Row, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.err != nil {
+		return nil, n.err
+	}
+	if !n.inResult {
+		return nil, nil
+	}
+	err := n.rows.Next(n.values)
+	if err == io.EOF {
+		n.inResult = false
+		return nil, nil
+	}
+	if err != nil {
+		n.err = err
+		n.finish(err == ErrBadConn)
+		return nil, err
+	}
+	values := make([]interface{}, len(n.values))
+	copy(values, n.values)
+
+	prep := make(map[int]interface{}, len(n.prepIndex)+len(n.prepName))
+	for i, v := range n.prepIndex {
+		if i < 0 || i >= len(values) {
+			return nil, fmt.Errorf("driver: prepared column index %d out of range", i)
+		}
+		prep[i] = v
+	}
+	for name, v := range n.prepName {
+		i := n.schema.Index(name)
+		if i < 0 {
+			return nil, fmt.Errorf("driver: prepared column %q not found in result", name)
+		}
+		prep[i] = v
+	}
+	for i, dest := range prep {
+		if w, is := dest.(io.Writer); is {
+			switch v := values[i].(type) {
+			case nil:
+			case []byte:
+				_, err = w.Write(v)
+			case string:
+				_, err = io.WriteString(w, v)
+			default:
+				_, err = fmt.Fprint(w, v)
+			}
+			if err != nil {
+				return nil, err
+			}
+		} else if err := rdb.Assign(dest, values[i]); err != nil {
+			return nil, fmt.Errorf("driver: column %q: %v", n.schema[i].Name, err)
+		}
+		values[i] = nil
+	}
+	return rdb.NewDataRow(n.schema, values), nil
+}
+
+func (n *next) Schema() rdb.Schema {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.schema
+}
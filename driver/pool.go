@@ -0,0 +1,315 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kardianos/rdb"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultMaxCapacity = 10
+
+	// Number of times to retry a query with a new connection when an idle
+	// connection returns ErrBadConn.
+	maxBadConnRetry = 2
+)
+
+var (
+	errPoolClosed = errors.New("driver: pool closed")
+	errClosed     = errors.New("driver: query closed")
+	errTxDone     = errors.New("driver: transaction already committed or rolled back")
+	errConnClosed = errors.New("driver: connection closed")
+	errStmtClosed = errors.New("driver: statement closed")
+)
+
+type poolConn struct {
+	conn      Conn
+	reused    bool
+	idleSince time.Time
+}
+
+// Pool implements rdb.Pool over connections created by a Connector.
+type Pool struct {
+	connector   Connector
+	slots       chan struct{} // Holds a value for each connection in use.
+	minIdle     int
+	idleTimeout time.Duration
+	types       rdb.TypeDefaults
+
+	mu     sync.Mutex
+	idle   []*poolConn // Ordered from the longest idle.
+	closed bool
+	done   chan struct{}
+}
+
+var _ rdb.Pool = &Pool{}
+
+// Open a new pool. The config PoolInitCapacity connections are opened
+// before Open returns. At most PoolMaxCapacity connections are open at once,
+// defaulting to 10. Connections idle for longer then PoolIdleTimeout are
+// closed, leaving at least PoolInitCapacity connections open.
+func Open(ctx context.Context, config *rdb.Config, connector Connector) (*Pool, error) {
+	max := config.PoolMaxCapacity
+	if max <= 0 {
+		max = defaultMaxCapacity
+	}
+	if config.PoolInitCapacity < 0 || config.PoolInitCapacity > max {
+		return nil, fmt.Errorf("driver: init capacity %d must be between zero and max capacity %d", config.PoolInitCapacity, max)
+	}
+	p := &Pool{
+		connector:   connector,
+		slots:       make(chan struct{}, max),
+		minIdle:     config.PoolInitCapacity,
+		idleTimeout: config.PoolIdleTimeout,
+		types:       config.TypeDefaults,
+		done:        make(chan struct{}),
+	}
+	for i := 0; i < config.PoolInitCapacity; i++ {
+		conn, err := connector.Connect(ctx)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.idle = append(p.idle, &poolConn{conn: conn, idleSince: time.Now()})
+	}
+	if p.idleTimeout > 0 {
+		go p.closeIdle()
+	}
+	return p, nil
+}
+
+// acquire a connection, waiting for a free slot if the pool is at capacity.
+func (p *Pool) acquire(ctx context.Context) (*poolConn, error) {
+	select {
+	case <-p.done:
+		return nil, errPoolClosed
+	default:
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.done:
+		return nil, errPoolClosed
+	}
+
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		pc := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		pc.reused = true
+		return pc, nil
+	}
+	p.mu.Unlock()
+
+	conn, err := p.connector.Connect(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return &poolConn{conn: conn}, nil
+}
+
+// release returns the connection to the pool. Bad connections are closed.
+func (p *Pool) release(pc *poolConn, bad bool) {
+	p.mu.Lock()
+	if bad || p.closed {
+		p.mu.Unlock()
+		pc.conn.Close()
+	} else {
+		pc.idleSince = time.Now()
+		p.idle = append(p.idle, pc)
+		p.mu.Unlock()
+	}
+	<-p.slots
+}
+
+// closeIdle periodically closes connections idle longer then the idle timeout.
+func (p *Pool) closeIdle() {
+	interval := p.idleTimeout / 2
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case now := <-tick.C:
+			var expired []*poolConn
+			p.mu.Lock()
+			for len(p.idle) > p.minIdle && now.Sub(p.idle[0].idleSince) > p.idleTimeout {
+				expired = append(expired, p.idle[0])
+				p.idle = p.idle[1:]
+			}
+			p.mu.Unlock()
+			for _, pc := range expired {
+				pc.conn.Close()
+			}
+		}
+	}
+}
+
+// Query runs the command on a connection from the pool. The connection is
+// returned after the last result is read, Next is closed, or ctx is cancelled.
+func (p *Pool) Query(ctx context.Context, cmd *rdb.Command, params ...rdb.Param) rdb.Next {
+	if err := ctx.Err(); err != nil {
+		return errNext(err)
+	}
+	params, err := rdb.ResolveParams(params, p.types)
+	if err != nil {
+		return errNext(err)
+	}
+	if cmd.Validate {
+		if err = rdb.ValidateParams(params, cmd.TruncLongText); err != nil {
+			return errNext(err)
+		}
+	}
+	for try := 0; ; try++ {
+		pc, err := p.acquire(ctx)
+		if err != nil {
+			return errNext(err)
+		}
+		rows, err := pc.conn.Query(ctx, cmd, params)
+		if err != nil {
+			p.release(pc, err == ErrBadConn)
+			if err == ErrBadConn && pc.reused && try < maxBadConnRetry {
+				continue
+			}
+			return errNext(err)
+		}
+		return newNext(ctx, rows, func(bad bool) {
+			p.release(pc, bad)
+		})
+	}
+}
+
+// Prepare returns a statement that runs the command on each Exec.
+// The statement is closed when ctx is cancelled.
+func (p *Pool) Prepare(ctx context.Context, cmd *rdb.Command) (rdb.Statement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &stmt{pool: p, ctx: ctx, cmd: cmd}, nil
+}
+
+// Begin a transaction on a dedicated connection. If ctx is cancelled before
+// the transaction is committed, the transaction is rolled back and the
+// connection returned to the pool.
+func (p *Pool) Begin(ctx context.Context, iso rdb.Isolation) (rdb.Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if iso > rdb.IsoLinearizable {
+		return nil, fmt.Errorf("driver: unknown isolation level %d", iso)
+	}
+	pc, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = pc.conn.Begin(ctx, iso); err != nil {
+		p.release(pc, err == ErrBadConn)
+		return nil, err
+	}
+	tx := &transaction{newSession(p, pc, errTxDone)}
+	go func() {
+		select {
+		case <-ctx.Done():
+			tx.end(func(conn Conn) error {
+				return conn.Rollback(context.Background())
+			})
+		case <-tx.done:
+		}
+	}()
+	return tx, nil
+}
+
+// Close the pool. Idle connections are closed immediately, connections in
+// use are closed when they are returned.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.done)
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	for _, pc := range idle {
+		pc.conn.Close()
+	}
+}
+
+// Connection returns a dedicated connection. The connection is returned to
+// the pool when Close is called or ctx is cancelled.
+func (p *Pool) Connection(ctx context.Context) (rdb.Connection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pc, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := &connection{newSession(p, pc, errConnClosed)}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.end(nil)
+		case <-c.done:
+		}
+	}()
+	return c, nil
+}
+
+// Ping checks a connection from the pool.
+func (p *Pool) Ping(ctx context.Context) error {
+	pc, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = pc.conn.Ping(ctx)
+	p.release(pc, err != nil)
+	return err
+}
+
+// Status returns the pool status.
+func (p *Pool) Status() rdb.PoolStatus {
+	return p
+}
+
+// Capacity returns the max number of connections.
+func (p *Pool) Capacity() int {
+	return cap(p.slots)
+}
+
+// Available returns the number of connections that may be used before
+// the pool is at capacity.
+func (p *Pool) Available() int {
+	return cap(p.slots) - len(p.slots)
+}
+
+type stmt struct {
+	pool *Pool
+	ctx  context.Context
+	cmd  *rdb.Command
+}
+
+func (s *stmt) Exec(ctx context.Context, params ...rdb.Param) rdb.Next {
+	if s.ctx.Err() != nil {
+		return errNext(errStmtClosed)
+	}
+	return s.pool.Query(ctx, s.cmd, params...)
+}
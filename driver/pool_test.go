@@ -0,0 +1,421 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package driver_test
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+	"github.com/kardianos/rdb/driver"
+	"github.com/kardianos/rdb/rdbconform"
+	_ "github.com/kardianos/rdb/rdbmem"
+	"golang.org/x/net/context"
+)
+
+// memDriverName is the config driver name of the memConnector opener.
+const memDriverName = "drivermem"
+
+func init() {
+	rdb.RegisterOpener(memOpener{})
+}
+
+type memOpener struct{}
+
+func (memOpener) CanOpen(config *rdb.Config) bool {
+	return config.DriverName == memDriverName
+}
+func (memOpener) Open(ctx context.Context, config *rdb.Config) (rdb.Pool, error) {
+	c, err := newMemConnector(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return driver.Open(ctx, config, c)
+}
+
+// memConnector opens connections that run commands on an in-memory
+// rdbmem database. Each connection runs its own transaction.
+type memConnector struct {
+	db rdb.Pool
+
+	mu     sync.Mutex
+	conns  []*memConn
+	opened int
+	closed int
+}
+
+func newMemConnector(ctx context.Context) (*memConnector, error) {
+	config, err := rdb.ParseConfigURL("mem://?max_cap=100")
+	if err != nil {
+		return nil, err
+	}
+	db, err := rdb.Open(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return &memConnector{db: db}, nil
+}
+
+func (c *memConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conn := &memConn{connector: c}
+	c.conns = append(c.conns, conn)
+	c.opened++
+	return conn, nil
+}
+
+// breakAll makes every open connection return driver.ErrBadConn.
+func (c *memConnector) breakAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, conn := range c.conns {
+		conn.bad = true
+	}
+}
+
+func (c *memConnector) counts() (opened, closed int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.opened, c.closed
+}
+
+type memConn struct {
+	connector *memConnector
+	bad       bool // Guarded by connector.mu.
+
+	tx     rdb.Transaction
+	cancel func()
+}
+
+func (c *memConn) isBad() bool {
+	c.connector.mu.Lock()
+	defer c.connector.mu.Unlock()
+	return c.bad
+}
+
+func (c *memConn) Query(ctx context.Context, cmd *rdb.Command, params []rdb.Param) (driver.Rows, error) {
+	if c.isBad() {
+		return nil, driver.ErrBadConn
+	}
+	var q rdb.Queryer = c.connector.db
+	if c.tx != nil {
+		q = c.tx
+	}
+	return &memRows{next: q.Query(ctx, cmd, params...)}, nil
+}
+func (c *memConn) Begin(ctx context.Context, iso rdb.Isolation) error {
+	txctx, cancel := context.WithCancel(context.Background())
+	tx, err := c.connector.db.Begin(txctx, iso)
+	if err != nil {
+		cancel()
+		return err
+	}
+	c.tx, c.cancel = tx, cancel
+	return nil
+}
+func (c *memConn) Commit(ctx context.Context) error {
+	defer c.end()
+	return c.tx.Commit(ctx)
+}
+func (c *memConn) Rollback(ctx context.Context) error {
+	defer c.end()
+	return c.tx.(interface {
+		Rollback(ctx context.Context) error
+	}).Rollback(ctx)
+}
+func (c *memConn) end() {
+	c.cancel()
+	c.tx, c.cancel = nil, nil
+}
+func (c *memConn) SavePoint(ctx context.Context, name string) error {
+	return c.tx.SavePoint(ctx, name)
+}
+func (c *memConn) RollbackTo(ctx context.Context, name string) error {
+	return c.tx.RollbackTo(ctx, name)
+}
+func (c *memConn) Ping(ctx context.Context) error {
+	if c.isBad() {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+func (c *memConn) Close() error {
+	if c.tx != nil {
+		c.end()
+	}
+	c.connector.mu.Lock()
+	c.connector.closed++
+	c.connector.mu.Unlock()
+	return nil
+}
+
+// memRows reads the results of an rdbmem query.
+type memRows struct {
+	next rdb.Next
+	res  rdb.Result
+}
+
+func (r *memRows) NextResult() error {
+	res, err := r.next.Result()
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return io.EOF
+	}
+	r.res = res
+	return nil
+}
+func (r *memRows) Schema() rdb.Schema {
+	return r.res.Schema()
+}
+func (r *memRows) Next(dest []interface{}) error {
+	row, err := r.res.Scan()
+	if err != nil {
+		return err
+	}
+	if row == nil {
+		return io.EOF
+	}
+	for i := range dest {
+		dest[i] = row.Getx(i)
+	}
+	return nil
+}
+func (r *memRows) Close() error {
+	r.next.Close()
+	return nil
+}
+
+var memColumnTypes = map[rdb.Type]string{
+	rdb.TypeText:     "text",
+	rdb.TypeUint8:    "uint8",
+	rdb.TypeUint16:   "uint16",
+	rdb.TypeUint32:   "uint32",
+	rdb.TypeUint64:   "uint64",
+	rdb.TypeInt8:     "tinyint",
+	rdb.TypeSerial16: "smallserial",
+	rdb.TypeSerial32: "serial",
+	rdb.TypeSerial64: "bigserial",
+	rdb.TypeMoney:    "money",
+	rdb.TypeUUID:     "uuid",
+	rdb.TypeEnum:     "enum",
+	rdb.TypeRange:    "range",
+	rdb.TypeArray:    "array",
+	rdb.TypeJSON:     "json",
+}
+
+func TestConformance(t *testing.T) {
+	rdbconform.RunConformance(t, &rdbconform.Config{
+		Open: &rdb.Config{DriverName: memDriverName, PoolMaxCapacity: 4},
+		ColumnType: func(t rdb.Type) string {
+			if name, found := memColumnTypes[t]; found {
+				return name
+			}
+			return rdbconform.StandardColumnType(t)
+		},
+	})
+}
+
+// openPool opens a pool on a new in-memory database with a table t.
+func openPool(t *testing.T, init, max int) (*driver.Pool, *memConnector) {
+	t.Helper()
+	ctx := context.Background()
+	c, err := newMemConnector(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.db.Query(ctx, &rdb.Command{SQL: "create table t (id int); insert into t values (1), (2)"}).BufferSet(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := driver.Open(ctx, &rdb.Config{PoolInitCapacity: init, PoolMaxCapacity: max}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, c
+}
+
+func TestReleaseOnEOF(t *testing.T) {
+	ctx := context.Background()
+	p, _ := openPool(t, 0, 1)
+	defer p.Close()
+
+	next := p.Query(ctx, &rdb.Command{SQL: "select id from t; select id from t where id = 2"})
+	for i := 0; i < 2; i++ {
+		buf, err := next.Buffer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf == nil {
+			t.Fatalf("missing result %d", i)
+		}
+		if n := p.Available(); n != 0 {
+			t.Errorf("result %d: got %d available while reading, want 0", i, n)
+		}
+	}
+	if buf, err := next.Buffer(); buf != nil || err != nil {
+		t.Fatalf("got %v, %v after last result", buf, err)
+	}
+	if n := p.Available(); n != 1 {
+		t.Errorf("got %d available after last result, want 1", n)
+	}
+
+	next = p.Query(ctx, &rdb.Command{SQL: "select id from t"})
+	res, err := next.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		row, err := res.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row == nil {
+			break
+		}
+	}
+	if n := p.Available(); n != 0 {
+		t.Errorf("got %d available before the next result is read, want 0", n)
+	}
+	if res, err = next.Result(); res != nil || err != nil {
+		t.Fatalf("got %v, %v after last result", res, err)
+	}
+	if n := p.Available(); n != 1 {
+		t.Errorf("got %d available after last result, want 1", n)
+	}
+}
+
+func TestReleaseOnClose(t *testing.T) {
+	ctx := context.Background()
+	p, c := openPool(t, 0, 1)
+	defer p.Close()
+
+	next := p.Query(ctx, &rdb.Command{SQL: "select id from t"})
+	if _, err := next.Result(); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.Available(); n != 0 {
+		t.Errorf("got %d available while open, want 0", n)
+	}
+	if err := next.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.Available(); n != 1 {
+		t.Errorf("got %d available after close, want 1", n)
+	}
+	if _, err := next.Result(); err == nil {
+		t.Error("Result after Close returned no error")
+	}
+
+	conn, err := p.Connection(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := p.Available(); n != 0 {
+		t.Errorf("got %d available with a connection, want 0", n)
+	}
+	conn.Close()
+	if n := p.Available(); n != 1 {
+		t.Errorf("got %d available after connection close, want 1", n)
+	}
+	if opened, _ := c.counts(); opened != 1 {
+		t.Errorf("opened %d connections, want 1 reused", opened)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	ctx := context.Background()
+	p, c := openPool(t, 2, 2)
+	defer p.Close()
+
+	if opened, _ := c.counts(); opened != 2 {
+		t.Errorf("opened %d connections, want the 2 init connections", opened)
+	}
+	if p.Capacity() != 2 || p.Available() != 2 {
+		t.Errorf("got capacity %d, available %d, want 2, 2", p.Capacity(), p.Available())
+	}
+	var open []rdb.Next
+	for i := 0; i < 2; i++ {
+		next := p.Query(ctx, &rdb.Command{SQL: "select id from t"})
+		if _, err := next.Result(); err != nil {
+			t.Fatal(err)
+		}
+		open = append(open, next)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Query(waitCtx, &rdb.Command{SQL: "select id from t"}).Result(); err != context.DeadlineExceeded {
+		t.Errorf("query at capacity: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Query(ctx, &rdb.Command{SQL: "select id from t"}).BufferSet()
+		done <- err
+	}()
+	open[0].Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("query did not run after a connection was returned")
+	}
+	open[1].Close()
+	if opened, _ := c.counts(); opened != 2 {
+		t.Errorf("opened %d connections, want 2", opened)
+	}
+
+	for _, item := range []struct{ init, max int }{{3, 2}, {11, 0}, {-1, 2}} {
+		if _, err := driver.Open(ctx, &rdb.Config{PoolInitCapacity: item.init, PoolMaxCapacity: item.max}, c); err == nil {
+			t.Errorf("init %d, max %d: expected error", item.init, item.max)
+		}
+	}
+}
+
+func TestBadConnRetry(t *testing.T) {
+	ctx := context.Background()
+	p, c := openPool(t, 1, 2)
+	defer p.Close()
+
+	c.breakAll()
+	buf, err := p.Query(ctx, &rdb.Command{SQL: "select id from t"}).Buffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf.Row) != 2 {
+		t.Errorf("got %d rows, want 2", len(buf.Row))
+	}
+	if opened, closed := c.counts(); opened != 2 || closed != 1 {
+		t.Errorf("got %d opened, %d closed, want the bad connection replaced", opened, closed)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	ctx := context.Background()
+	p, c := openPool(t, 2, 2)
+
+	next := p.Query(ctx, &rdb.Command{SQL: "select id from t"})
+	if _, err := next.Result(); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	if _, closed := c.counts(); closed != 1 {
+		t.Errorf("closed %d connections, want the idle connection closed", closed)
+	}
+	next.Close()
+	if _, closed := c.counts(); closed != 2 {
+		t.Errorf("closed %d connections, want the returned connection closed", closed)
+	}
+	if err := p.Query(ctx, &rdb.Command{SQL: "select id from t"}).Close(); err == nil {
+		t.Error("query after close returned no error")
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"sync"
+
+	"github.com/kardianos/rdb"
+	"golang.org/x/net/context"
+)
+
+// session holds a dedicated connection for a transaction or connection.
+// Only one query may be open on the connection at a time.
+type session struct {
+	pool   *Pool
+	pc     *poolConn
+	endErr error // Returned after the session has ended.
+	lock   chan struct{}
+	done   chan struct{}
+
+	mu    sync.Mutex
+	cur   *next
+	ended bool
+	bad   bool // Guarded by lock.
+}
+
+func newSession(p *Pool, pc *poolConn, endErr error) *session {
+	return &session{
+		pool:   p,
+		pc:     pc,
+		endErr: endErr,
+		lock:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// take the connection, waiting for any open query to finish.
+func (s *session) take(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case s.lock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return s.endErr
+	}
+	s.mu.Lock()
+	ended := s.ended
+	s.mu.Unlock()
+	if ended {
+		<-s.lock
+		return s.endErr
+	}
+	return nil
+}
+
+// give back the connection.
+func (s *session) give(err error) {
+	if err == ErrBadConn {
+		s.bad = true
+	}
+	<-s.lock
+}
+
+func (s *session) Query(ctx context.Context, cmd *rdb.Command, params ...rdb.Param) rdb.Next {
+	params, err := rdb.ResolveParams(params, s.pool.types)
+	if err != nil {
+		return errNext(err)
+	}
+	if cmd.Validate {
+		if err = rdb.ValidateParams(params, cmd.TruncLongText); err != nil {
+			return errNext(err)
+		}
+	}
+	if err := s.take(ctx); err != nil {
+		return errNext(err)
+	}
+	rows, err := s.pc.conn.Query(ctx, cmd, params)
+	if err != nil {
+		s.give(err)
+		return errNext(err)
+	}
+	n := newNext(ctx, rows, func(bad bool) {
+		if bad {
+			s.bad = true
+		}
+		<-s.lock
+	})
+	s.mu.Lock()
+	s.cur = n
+	s.mu.Unlock()
+	return n
+}
+
+// exec runs f on the connection.
+func (s *session) exec(ctx context.Context, f func(conn Conn) error) error {
+	if err := s.take(ctx); err != nil {
+		return err
+	}
+	err := f(s.pc.conn)
+	s.give(err)
+	return err
+}
+
+// end the session. Any open query is closed, then f is called if not nil
+// and the connection returned to the pool.
+func (s *session) end(f func(conn Conn) error) error {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return s.endErr
+	}
+	s.ended = true
+	cur := s.cur
+	s.mu.Unlock()
+
+	if cur != nil {
+		cur.Close()
+	}
+	s.lock <- struct{}{}
+	var err error
+	if f != nil && !s.bad {
+		err = f(s.pc.conn)
+	}
+	s.pool.release(s.pc, s.bad || err != nil)
+	close(s.done)
+	<-s.lock
+	return err
+}
+
+type transaction struct {
+	*session
+}
+
+// SavePoint creates a save point in the transaction.
+func (tx *transaction) SavePoint(ctx context.Context, name string) error {
+	return tx.exec(ctx, func(conn Conn) error {
+		return conn.SavePoint(ctx, name)
+	})
+}
+
+// RollbackTo rolls back the transaction to the save point.
+func (tx *transaction) RollbackTo(ctx context.Context, name string) error {
+	return tx.exec(ctx, func(conn Conn) error {
+		return conn.RollbackTo(ctx, name)
+	})
+}
+
+// Commit the transaction and return the connection to the pool.
+func (tx *transaction) Commit(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return tx.end(func(conn Conn) error {
+		return conn.Commit(ctx)
+	})
+}
+
+var _ rdb.Rollbacker = &transaction{}
+
+// Rollback the transaction and return the connection to the pool.
+func (tx *transaction) Rollback(ctx context.Context) error {
+	return tx.end(func(conn Conn) error {
+		return conn.Rollback(ctx)
+	})
+}
+
+type connection struct {
+	*session
+}
+
+// Close returns the connection to the pool.
+func (c *connection) Close() {
+	c.end(nil)
+}
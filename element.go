@@ -0,0 +1,382 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Array and Range elements are encoded by their element Type.
+// The text form of arrays and ranges follows the PostgreSQL literal
+// syntax; drivers may send and read it. The binary form is specific to
+// rdb, for storing or copying values. It is not a database wire format
+// and is not read or written by drivers.
+//
+// The text form of numbers, bools, and text is the usual Go form.
+// Binary values are written as "\x" followed by hex digits, and times
+// as RFC 3339. Types that implement encoding.TextMarshaler use it.
+//
+// The binary form of integers and floats is eight bytes big-endian.
+// Bools are one byte. Durations are eight bytes of nanoseconds.
+// Times use time.Time.MarshalBinary. Decimals and text are written as
+// text. Types that implement encoding.BinaryMarshaler use it.
+
+var elemTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+// formatElem returns the text form of an element.
+func formatElem(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return `\x` + hex.EncodeToString(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return v.String(), nil
+	case Numeric:
+		return v.String(), nil
+	case encoding.TextMarshaler:
+		b, err := v.MarshalText()
+		return string(b), err
+	}
+	sv := reflect.ValueOf(v)
+	switch sv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(sv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(sv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(sv.Float(), 'g', -1, sv.Type().Bits()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(sv.Bool()), nil
+	case reflect.String:
+		return sv.String(), nil
+	}
+	return "", fmt.Errorf("Cannot format %T as an element", v)
+}
+
+// parseElem parses the text form of an element of type t.
+func parseElem(t Type, s string) (interface{}, error) {
+	switch t {
+	case TypeUUID:
+		return ParseUUID(s)
+	case TypeJSON:
+		var j JSON
+		if err := j.set([]byte(s)); err != nil {
+			return nil, err
+		}
+		return j, nil
+	case TypeXML:
+		var x XML
+		if err := x.set([]byte(s)); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case TypeEnum:
+		return Enum(s), nil
+	case TypeDuration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot convert %q to a duration", s)
+		}
+		return d, nil
+	case TypeUint64:
+		return parseUint(s)
+	}
+	switch t.GenericOf() {
+	case Integer:
+		return parseInt(s)
+	case Float:
+		return parseFloat(s)
+	case Decimal:
+		return ParseNumeric(s)
+	case Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot convert %q to bool", s)
+		}
+		return b, nil
+	case Time:
+		for _, layout := range elemTimeLayouts {
+			if v, err := time.Parse(layout, s); err == nil {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("Cannot convert %q to time", s)
+	case Binary:
+		if strings.HasPrefix(s, `\x`) {
+			b, err := hex.DecodeString(s[2:])
+			if err != nil {
+				return nil, fmt.Errorf("Cannot convert %q to bytes", s)
+			}
+			return b, nil
+		}
+		return []byte(s), nil
+	}
+	return s, nil
+}
+
+// encodeElem returns the binary form of an element of type t.
+func encodeElem(t Type, v interface{}) ([]byte, error) {
+	b := make([]byte, 8)
+	if t == TypeDuration {
+		var d time.Duration
+		if err := convertAssign(reflect.ValueOf(&d).Elem(), v); err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint64(b, uint64(d))
+		return b, nil
+	}
+	switch t.GenericOf() {
+	case Integer:
+		if t == TypeUint64 {
+			var n uint64
+			if err := convertAssign(reflect.ValueOf(&n).Elem(), v); err != nil {
+				return nil, err
+			}
+			binary.BigEndian.PutUint64(b, n)
+			return b, nil
+		}
+		var n int64
+		if err := convertAssign(reflect.ValueOf(&n).Elem(), v); err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint64(b, uint64(n))
+		return b, nil
+	case Float:
+		var f float64
+		if err := convertAssign(reflect.ValueOf(&f).Elem(), v); err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint64(b, math.Float64bits(f))
+		return b, nil
+	case Bool:
+		var x bool
+		if err := convertAssign(reflect.ValueOf(&x).Elem(), v); err != nil {
+			return nil, err
+		}
+		if x {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case Decimal:
+		var d Numeric
+		if err := d.Scan(v); err != nil {
+			return nil, err
+		}
+		return []byte(d.String()), nil
+	case Time:
+		var x time.Time
+		if err := convertAssign(reflect.ValueOf(&x).Elem(), v); err != nil {
+			return nil, err
+		}
+		return x.MarshalBinary()
+	case Text, Binary:
+		var x []byte
+		if err := convertAssign(reflect.ValueOf(&x).Elem(), v); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+	switch v := v.(type) {
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	case []byte:
+		return v, nil
+	}
+	s, err := formatElem(v)
+	return []byte(s), err
+}
+
+// decodeElem parses the binary form of an element of type t.
+func decodeElem(t Type, b []byte) (interface{}, error) {
+	switch t {
+	case TypeUUID:
+		var u UUID
+		if err := u.UnmarshalBinary(b); err != nil {
+			return nil, err
+		}
+		return u, nil
+	case TypeDuration:
+		if len(b) != 8 {
+			return nil, fmt.Errorf("Invalid binary length %d for a duration", len(b))
+		}
+		return time.Duration(binary.BigEndian.Uint64(b)), nil
+	}
+	switch t.GenericOf() {
+	case Integer, Float:
+		if len(b) != 8 {
+			return nil, fmt.Errorf("Invalid binary length %d for a number", len(b))
+		}
+		n := binary.BigEndian.Uint64(b)
+		switch {
+		case t == TypeUint64:
+			return n, nil
+		case t.GenericOf() == Float:
+			return math.Float64frombits(n), nil
+		}
+		return int64(n), nil
+	case Bool:
+		if len(b) != 1 {
+			return nil, fmt.Errorf("Invalid binary length %d for a bool", len(b))
+		}
+		return b[0] != 0, nil
+	case Time:
+		var x time.Time
+		if err := x.UnmarshalBinary(b); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case Binary:
+		return append([]byte(nil), b...), nil
+	}
+	return parseElem(t, string(b))
+}
+
+// nullItem is the binary length of a null item.
+const nullItem = 0xFFFFFFFF
+
+// appendItem appends the binary form of an item of type t,
+// prefixed with its big-endian int32 length.
+func appendItem(b []byte, t Type, v interface{}) ([]byte, error) {
+	var size [4]byte
+	if v == nil {
+		binary.BigEndian.PutUint32(size[:], nullItem)
+		return append(b, size[:]...), nil
+	}
+	data, err := encodeElem(t, v)
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+	b = append(b, size[:]...)
+	return append(b, data...), nil
+}
+
+// readItem reads a length prefixed item and returns the remaining data.
+func readItem(t Type, data []byte) (interface{}, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, fmt.Errorf("Missing binary item length")
+	}
+	size := binary.BigEndian.Uint32(data)
+	data = data[4:]
+	if size == nullItem {
+		return nil, data, nil
+	}
+	if uint64(size) > uint64(len(data)) {
+		return nil, nil, fmt.Errorf("Invalid binary item length %d", size)
+	}
+	item, err := decodeElem(t, data[:size])
+	if err != nil {
+		return nil, nil, err
+	}
+	return item, data[size:], nil
+}
+
+// quoteElem returns s in double quotes if it is empty or contains
+// white space or any of the special characters. Quotes and
+// backslashes are escaped with a backslash.
+func quoteElem(s, special string) string {
+	need := len(s) == 0 || strings.ContainsAny(s, special) || strings.IndexFunc(s, unicode.IsSpace) >= 0
+	if !need {
+		return s
+	}
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(s[i])
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// elemParser reads elements from the text form of an array or range.
+type elemParser struct {
+	s string
+	i int
+}
+
+func (p *elemParser) space() {
+	for p.i < len(p.s) && unicode.IsSpace(rune(p.s[p.i])) {
+		p.i++
+	}
+}
+
+func (p *elemParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+// quoted reads a double quoted string, starting at the opening quote.
+func (p *elemParser) quoted() (string, error) {
+	var buf strings.Builder
+	for p.i++; p.i < len(p.s); p.i++ {
+		switch c := p.s[p.i]; c {
+		case '\\':
+			p.i++
+			if p.i < len(p.s) {
+				buf.WriteByte(p.s[p.i])
+			}
+		case '"':
+			p.i++
+			return buf.String(), nil
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("Missing closing quote")
+}
+
+// token reads an unquoted element up to one of the stop characters.
+func (p *elemParser) token(stop string) string {
+	start := p.i
+	for p.i < len(p.s) && strings.IndexByte(stop, p.s[p.i]) < 0 {
+		p.i++
+	}
+	return strings.TrimSpace(p.s[start:p.i])
+}
+
+// elem reads a quoted or unquoted element. An unquoted element equal
+// to null, without regard to case, returns nil if null is set.
+// An empty unquoted element returns nil.
+func (p *elemParser) elem(t Type, stop string, null bool) (interface{}, error) {
+	p.space()
+	if p.peek() == '"' {
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return parseElem(t, s)
+	}
+	s := p.token(stop)
+	if len(s) == 0 || (null && strings.EqualFold(s, "NULL")) {
+		return nil, nil
+	}
+	return parseElem(t, s)
+}
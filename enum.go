@@ -0,0 +1,56 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// Enum is the label of an enumerated value for TypeEnum values.
+type Enum string
+
+// MarshalText implements encoding.TextMarshaler.
+func (e Enum) MarshalText() ([]byte, error) {
+	return []byte(e), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *Enum) UnmarshalText(text []byte) error {
+	*e = Enum(text)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+// The binary form is the same as the text form.
+func (e Enum) MarshalBinary() ([]byte, error) {
+	return []byte(e), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (e *Enum) UnmarshalBinary(data []byte) error {
+	*e = Enum(data)
+	return nil
+}
+
+// Scan implements sql.Scanner. Null values cannot be scanned, use a pointer.
+func (e *Enum) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return fmt.Errorf("Cannot assign null to rdb.Enum")
+	case []byte:
+		*e = Enum(v)
+		return nil
+	case string:
+		*e = Enum(v)
+		return nil
+	}
+	return fmt.Errorf("Cannot convert %T to rdb.Enum", value)
+}
+
+// Value implements driver.Valuer.
+func (e Enum) Value() (driver.Value, error) {
+	return string(e), nil
+}
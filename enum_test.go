@@ -0,0 +1,53 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kardianos/rdb"
+)
+
+func TestEnum(t *testing.T) {
+	list := []struct {
+		src  interface{}
+		want rdb.Enum
+		ok   bool
+	}{
+		{"red", "red", true},
+		{[]byte("blue"), "blue", true},
+		{"", "", true},
+		{nil, "", false},
+		{int64(1), "", false},
+	}
+	for _, item := range list {
+		var e rdb.Enum
+		err := e.Scan(item.src)
+		if !item.ok {
+			if err == nil {
+				t.Errorf("%v: expected error", item.src)
+			}
+			continue
+		}
+		if err != nil || e != item.want {
+			t.Errorf("%v: got %q, %v", item.src, e, err)
+		}
+	}
+
+	e := rdb.Enum("red")
+	if v, err := e.Value(); err != nil || v != "red" {
+		t.Errorf("value: got %v, %v", v, err)
+	}
+	data, err := json.Marshal(e)
+	if err != nil || string(data) != `"red"` {
+		t.Errorf("JSON: got %s, %v", data, err)
+	}
+	var back rdb.Enum
+	bin, _ := e.MarshalBinary()
+	if err = back.UnmarshalBinary(bin); err != nil || back != e {
+		t.Errorf("binary: got %q, %v", back, err)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TypeDefaults choose the type inferred for Go values that may be sent
+// as more than one type. A zero field uses the listed default.
+type TypeDefaults struct {
+	Text Type // Type for string values, TypeText by default.
+	Time Type // Type for time.Time values, TypeTimestampz by default.
+}
+
+func (d TypeDefaults) text() Type {
+	if d.Text == TypeUnknown {
+		return TypeText
+	}
+	return d.Text
+}
+
+func (d TypeDefaults) time() Type {
+	if d.Time == TypeUnknown {
+		return TypeTimestampz
+	}
+	return d.Time
+}
+
+// ParamValuer is implemented by Go types that choose their own
+// parameter value and type.
+type ParamValuer interface {
+	// ParamValue returns the value to send and its type. If the type is
+	// TypeUnknown it is inferred from the returned value.
+	ParamValue() (interface{}, Type, error)
+}
+
+// Converter returns the parameter value and type for a value of a Go
+// type that cannot implement ParamValuer. If the type is TypeUnknown
+// it is inferred from the returned value.
+type Converter func(v interface{}) (interface{}, Type, error)
+
+var (
+	converterSync = sync.RWMutex{}
+	converters    = make(map[reflect.Type]Converter)
+)
+
+// RegisterConverter registers a converter for values with the same Go
+// type as example. An error is returned if the Go type already has a
+// converter.
+func RegisterConverter(example interface{}, c Converter) error {
+	if example == nil || c == nil {
+		return fmt.Errorf("Converter example and func must not be nil")
+	}
+	rt := reflect.TypeOf(example)
+
+	converterSync.Lock()
+	defer converterSync.Unlock()
+
+	if _, found := converters[rt]; found {
+		return fmt.Errorf("Converter for %v already registered", rt)
+	}
+	converters[rt] = c
+	return nil
+}
+
+func lookupConverter(v interface{}) (Converter, bool) {
+	converterSync.RLock()
+	c, found := converters[reflect.TypeOf(v)]
+	converterSync.RUnlock()
+	return c, found
+}
+
+var (
+	reflectString   = reflect.TypeOf("")
+	reflectTime     = reflect.TypeOf(time.Time{})
+	reflectNullText = reflect.TypeOf(NullText{})
+	reflectNullTime = reflect.TypeOf(NullTime{})
+)
+
+// inferTypes are the types of Go values that always infer the same type.
+var inferTypes = map[reflect.Type]Type{
+	reflect.TypeOf([]byte(nil)):      TypeBinary,
+	reflect.TypeOf(false):            TypeBool,
+	reflect.TypeOf(int(0)):           TypeInt64,
+	reflect.TypeOf(int8(0)):          TypeInt8,
+	reflect.TypeOf(int16(0)):         TypeInt16,
+	reflect.TypeOf(int32(0)):         TypeInt32,
+	reflect.TypeOf(int64(0)):         TypeInt64,
+	reflect.TypeOf(uint(0)):          TypeUint64,
+	reflect.TypeOf(uint8(0)):         TypeUint8,
+	reflect.TypeOf(uint16(0)):        TypeUint16,
+	reflect.TypeOf(uint32(0)):        TypeUint32,
+	reflect.TypeOf(uint64(0)):        TypeUint64,
+	reflect.TypeOf(float32(0)):       TypeFloat32,
+	reflect.TypeOf(float64(0)):       TypeFloat64,
+	reflect.TypeOf(time.Duration(0)): TypeDuration,
+
+	reflect.TypeOf(Numeric{}): TypeDecimal,
+	reflect.TypeOf(UUID{}):    TypeUUID,
+	reflect.TypeOf(JSON{}):    TypeJSON,
+	reflect.TypeOf(XML{}):     TypeXML,
+	reflect.TypeOf(Enum("")):  TypeEnum,
+	reflect.TypeOf(Array{}):   TypeArray,
+	reflect.TypeOf(Range{}):   TypeRange,
+
+	reflect.TypeOf(NullBytes{}):   TypeBinary,
+	reflect.TypeOf(NullBool{}):    TypeBool,
+	reflect.TypeOf(NullInteger{}): TypeInt64,
+	reflect.TypeOf(NullFloat{}):   TypeFloat64,
+	reflect.TypeOf(NullDecimal{}): TypeDecimal,
+}
+
+// InferType returns the type sent for a Go value. Strings and times use
+// the type chosen by d. Pointers infer the type they point to, even if
+// nil. Other Go types with a basic underlying type infer the type of the
+// underlying type. Values of any other type, nil, and io.Reader values
+// return TypeUnknown.
+func InferType(v interface{}, d TypeDefaults) Type {
+	if v == nil {
+		return TypeUnknown
+	}
+	rt := reflect.TypeOf(v)
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	switch rt {
+	case reflectString, reflectNullText:
+		return d.text()
+	case reflectTime, reflectNullTime:
+		return d.time()
+	}
+	if t, found := inferTypes[rt]; found {
+		return t
+	}
+	switch rt.Kind() {
+	case reflect.String:
+		return d.text()
+	case reflect.Slice:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			return TypeBinary
+		}
+	case reflect.Bool:
+		return TypeBool
+	case reflect.Int, reflect.Int64:
+		return TypeInt64
+	case reflect.Int8:
+		return TypeInt8
+	case reflect.Int16:
+		return TypeInt16
+	case reflect.Int32:
+		return TypeInt32
+	case reflect.Uint, reflect.Uint64:
+		return TypeUint64
+	case reflect.Uint8:
+		return TypeUint8
+	case reflect.Uint16:
+		return TypeUint16
+	case reflect.Uint32:
+		return TypeUint32
+	case reflect.Float32:
+		return TypeFloat32
+	case reflect.Float64:
+		return TypeFloat64
+	}
+	return TypeUnknown
+}
+
+// ResolveParams returns a copy of params with input values converted and
+// each TypeUnknown type inferred. Drivers call it so parameters resolve
+// the same way in every driver.
+//
+// An input value that implements ParamValuer, or has a registered
+// Converter, is replaced by the value it returns. Its type is used if
+// the parameter type is TypeUnknown. Otherwise the type is inferred with
+// InferType. Input values of other types that implement driver.Valuer
+// are replaced by their driver value to infer the type. Output parameter
+// values are never converted; the type is inferred from the pointer.
+func ResolveParams(params []Param, d TypeDefaults) ([]Param, error) {
+	if len(params) == 0 {
+		return params, nil
+	}
+	out := make([]Param, len(params))
+	for i, p := range params {
+		if err := resolveParam(&p, d); err != nil {
+			return nil, &ParamError{Index: i, Name: p.Name, Type: p.Type, Err: err}
+		}
+		out[i] = p
+	}
+	return out, nil
+}
+
+func resolveParam(p *Param, d TypeDefaults) error {
+	if !p.Out {
+		var v interface{}
+		t := TypeUnknown
+		var err error
+		converted := true
+		switch pv := p.Value.(type) {
+		case ParamValuer:
+			v, t, err = pv.ParamValue()
+		default:
+			if c, found := lookupConverter(p.Value); found {
+				v, t, err = c(p.Value)
+			} else {
+				converted = false
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if converted {
+			p.Value = v
+			if p.Type == TypeUnknown {
+				p.Type = t
+			}
+		}
+	}
+	if p.Type != TypeUnknown {
+		return nil
+	}
+	p.Type = InferType(p.Value, d)
+	if p.Type != TypeUnknown || p.Out {
+		return nil
+	}
+	if valuer, is := p.Value.(driver.Valuer); is {
+		v, err := valuer.Value()
+		if err != nil {
+			return err
+		}
+		p.Value = v
+		p.Type = InferType(v, d)
+	}
+	return nil
+}
+
+// paramName names the parameter in error messages, by name if set,
+// otherwise by index.
+func paramName(index int, name string) string {
+	if len(name) != 0 {
+		return strconv.Quote(name)
+	}
+	return strconv.Itoa(index)
+}
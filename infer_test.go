@@ -0,0 +1,186 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+)
+
+type testLabel string
+
+type testCelsius float64
+
+// testPointValue is sent as an array by a registered converter.
+type testPointValue struct{ X, Y int }
+
+// testMoney is sent as a decimal number of cents.
+type testMoney int64
+
+func (m testMoney) ParamValue() (interface{}, rdb.Type, error) {
+	if m < 0 {
+		return nil, rdb.TypeUnknown, errors.New("negative amount")
+	}
+	return rdb.NewNumeric(int64(m), 2), rdb.TypeMoney, nil
+}
+
+// testValuer is sent as its driver value.
+type testValuer struct{ v driver.Value }
+
+func (v testValuer) Value() (driver.Value, error) {
+	if err, is := v.v.(error); is {
+		return nil, err
+	}
+	return v.v, nil
+}
+
+func init() {
+	err := rdb.RegisterConverter(testPointValue{}, func(v interface{}) (interface{}, rdb.Type, error) {
+		p := v.(testPointValue)
+		if p.X < 0 {
+			return nil, rdb.TypeUnknown, errors.New("negative point")
+		}
+		return rdb.NewArray(rdb.TypeInt64, int64(p.X), int64(p.Y)), rdb.TypeUnknown, nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestInferType(t *testing.T) {
+	var nilInt *int64
+	ansi := rdb.TypeDefaults{Text: rdb.TypeAnsiVarChar, Time: rdb.TypeTimestamp}
+	list := []struct {
+		v    interface{}
+		d    rdb.TypeDefaults
+		want rdb.Type
+	}{
+		{nil, rdb.TypeDefaults{}, rdb.TypeUnknown},
+		{"a", rdb.TypeDefaults{}, rdb.TypeText},
+		{"a", ansi, rdb.TypeAnsiVarChar},
+		{testLabel("a"), ansi, rdb.TypeAnsiVarChar},
+		{rdb.NullText{}, ansi, rdb.TypeAnsiVarChar},
+		{time.Time{}, rdb.TypeDefaults{}, rdb.TypeTimestampz},
+		{time.Time{}, ansi, rdb.TypeTimestamp},
+		{&rdb.NullTime{}, ansi, rdb.TypeTimestamp},
+		{[]byte("a"), rdb.TypeDefaults{}, rdb.TypeBinary},
+		{rdb.JSON(`1`), rdb.TypeDefaults{}, rdb.TypeJSON},
+		{true, rdb.TypeDefaults{}, rdb.TypeBool},
+		{int(1), rdb.TypeDefaults{}, rdb.TypeInt64},
+		{int8(1), rdb.TypeDefaults{}, rdb.TypeInt8},
+		{uint(1), rdb.TypeDefaults{}, rdb.TypeUint64},
+		{uint16(1), rdb.TypeDefaults{}, rdb.TypeUint16},
+		{float32(1), rdb.TypeDefaults{}, rdb.TypeFloat32},
+		{testCelsius(1), rdb.TypeDefaults{}, rdb.TypeFloat64},
+		{time.Second, rdb.TypeDefaults{}, rdb.TypeDuration},
+		{rdb.NewNumeric(1, 0), rdb.TypeDefaults{}, rdb.TypeDecimal},
+		{rdb.UUID{}, rdb.TypeDefaults{}, rdb.TypeUUID},
+		{rdb.Enum("a"), rdb.TypeDefaults{}, rdb.TypeEnum},
+		{rdb.Array{}, rdb.TypeDefaults{}, rdb.TypeArray},
+		{rdb.Range{}, rdb.TypeDefaults{}, rdb.TypeRange},
+		{rdb.NullInteger{}, rdb.TypeDefaults{}, rdb.TypeInt64},
+		{rdb.NullDecimal{}, rdb.TypeDefaults{}, rdb.TypeDecimal},
+		{nilInt, rdb.TypeDefaults{}, rdb.TypeInt64},
+		{&nilInt, rdb.TypeDefaults{}, rdb.TypeInt64},
+		{bytes.NewReader(nil), rdb.TypeDefaults{}, rdb.TypeUnknown},
+		{testPointValue{}, rdb.TypeDefaults{}, rdb.TypeUnknown},
+		{[]int{1}, rdb.TypeDefaults{}, rdb.TypeUnknown},
+	}
+	for _, item := range list {
+		if got := rdb.InferType(item.v, item.d); got != item.want {
+			t.Errorf("%T: got %v, want %v", item.v, got, item.want)
+		}
+	}
+}
+
+func TestResolveParams(t *testing.T) {
+	var out string
+	list := []struct {
+		name string
+		in   rdb.Param
+		want rdb.Param
+	}{
+		{"infer", rdb.Param{Value: int32(1)}, rdb.Param{Type: rdb.TypeInt32, Value: int32(1)}},
+		{"keep type", rdb.Param{Type: rdb.TypeInt64, Value: int32(1)}, rdb.Param{Type: rdb.TypeInt64, Value: int32(1)}},
+		{"text default", rdb.Param{Name: "a", Value: "x"}, rdb.Param{Name: "a", Type: rdb.TypeAnsiText, Value: "x"}},
+		{"param valuer", rdb.Param{Value: testMoney(150)}, rdb.Param{Type: rdb.TypeMoney, Value: rdb.NewNumeric(150, 2)}},
+		{"param valuer typed", rdb.Param{Type: rdb.TypeDecimal, Value: testMoney(1)}, rdb.Param{Type: rdb.TypeDecimal, Value: rdb.NewNumeric(1, 2)}},
+		{"converter", rdb.Param{Value: testPointValue{1, 2}}, rdb.Param{Type: rdb.TypeArray, Value: rdb.NewArray(rdb.TypeInt64, int64(1), int64(2))}},
+		{"driver valuer", rdb.Param{Value: testValuer{int64(3)}}, rdb.Param{Type: rdb.TypeInt64, Value: int64(3)}},
+		{"driver valuer typed", rdb.Param{Type: rdb.TypeText, Value: testValuer{"a"}}, rdb.Param{Type: rdb.TypeText, Value: testValuer{"a"}}},
+		{"null", rdb.Param{Value: nil}, rdb.Param{}},
+		{"output", rdb.Param{Out: true, Value: &out}, rdb.Param{Out: true, Type: rdb.TypeAnsiText, Value: &out}},
+	}
+	params := make([]rdb.Param, len(list))
+	for i, item := range list {
+		params[i] = item.in
+	}
+	got, err := rdb.ResolveParams(params, rdb.TypeDefaults{Text: rdb.TypeAnsiText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, item := range list {
+		if !reflect.DeepEqual(got[i], item.want) {
+			t.Errorf("%s: got %+v, want %+v", item.name, got[i], item.want)
+		}
+		if !reflect.DeepEqual(params[i], item.in) {
+			t.Errorf("%s: input changed to %+v", item.name, params[i])
+		}
+	}
+	if got, err = rdb.ResolveParams(nil, rdb.TypeDefaults{}); err != nil || got != nil {
+		t.Errorf("no params: got %v, %v", got, err)
+	}
+}
+
+func TestResolveParamsError(t *testing.T) {
+	valueErr := errors.New("no value")
+	list := []struct {
+		name  string
+		param rdb.Param
+		want  string
+	}{
+		{"param valuer", rdb.Param{Name: "amount", Value: testMoney(-1)}, `Parameter "amount": negative amount`},
+		{"converter", rdb.Param{Value: testPointValue{X: -1}}, `Parameter 1: negative point`},
+		{"driver valuer", rdb.Param{Value: testValuer{valueErr}}, `Parameter 1: no value`},
+	}
+	for _, item := range list {
+		_, err := rdb.ResolveParams([]rdb.Param{{Value: 1}, item.param}, rdb.TypeDefaults{})
+		pe, ok := err.(*rdb.ParamError)
+		if !ok {
+			t.Errorf("%s: got %T %v, want *rdb.ParamError", item.name, err, err)
+			continue
+		}
+		if pe.Index != 1 || pe.Name != item.param.Name {
+			t.Errorf("%s: got index %d name %q", item.name, pe.Index, pe.Name)
+		}
+		if err.Error() != item.want {
+			t.Errorf("%s: got %q, want %q", item.name, err, item.want)
+		}
+	}
+}
+
+func TestRegisterConverter(t *testing.T) {
+	conv := func(v interface{}) (interface{}, rdb.Type, error) { return v, rdb.TypeUnknown, nil }
+	list := []struct {
+		name    string
+		example interface{}
+		c       rdb.Converter
+	}{
+		{"registered", testPointValue{}, conv},
+		{"nil example", nil, conv},
+		{"nil func", testCelsius(0), nil},
+	}
+	for _, item := range list {
+		if err := rdb.RegisterConverter(item.example, item.c); err == nil {
+			t.Errorf("%s: expected error", item.name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+// +build go1.23
+
+package rdb
+
+import "iter"
+
+// Rows returns an iterator over the rows of the result.
+// If Scan returns an error it is yielded with a nil row and iteration stops.
+// Rows does not close the result; close the Next it came from when done.
+// After breaking out of the loop, the next call to Next.Result skips the
+// rows that were not read.
+//
+//	next := pool.Query(ctx, cmd)
+//	defer next.Close()
+//
+//	res, err := next.Result()
+//	...
+//	for row, err := range rdb.Rows(res) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Rows(res Result) iter.Seq2[Row, error] {
+	return func(yield func(Row, error) bool) {
+		for {
+			row, err := res.Scan()
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if row == nil {
+				return
+			}
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Results returns an iterator over the results of next.
+// If Result returns an error it is yielded with a nil result and iteration
+// stops. The connection is returned to the pool after the last result,
+// and next is closed when breaking out of the loop or on an error.
+// Breaking out of a Rows loop within a Results loop continues with the
+// next result.
+//
+//	for res, err := range rdb.Results(next) {
+//		if err != nil {
+//			return err
+//		}
+//		for row, err := range rdb.Rows(res) {
+//			...
+//		}
+//	}
+func Results(next Next) iter.Seq2[Result, error] {
+	return func(yield func(Result, error) bool) {
+		for {
+			res, err := next.Result()
+			if err != nil {
+				next.Close()
+				yield(nil, err)
+				return
+			}
+			if res == nil {
+				return
+			}
+			if !yield(res, nil) {
+				next.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+// +build go1.23
+
+package rdb_test
+
+import (
+	"testing"
+
+	"github.com/kardianos/rdb"
+	"github.com/kardianos/rdb/rdbtest"
+	"golang.org/x/net/context"
+)
+
+// newIterMock returns a mock with a query that returns two results.
+func newIterMock() *rdbtest.Mock {
+	m := rdbtest.New()
+	m.ExpectName("q").WillReturn(
+		rdbtest.NewBuffer(rdbtest.Columns("a"), []interface{}{int64(1)}, []interface{}{int64(2)}),
+		rdbtest.NewBuffer(rdbtest.Columns("b"), []interface{}{int64(3)}, []interface{}{int64(4)}),
+	)
+	return m
+}
+
+// checkReturned checks all expectations were met and the connection is
+// back in the pool.
+func checkReturned(t *testing.T, name string, m *rdbtest.Mock) {
+	t.Helper()
+	if status := m.Status(); status.Available() != status.Capacity() {
+		t.Errorf("%s: connection not returned to the pool", name)
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Errorf("%s: %v", name, err)
+	}
+}
+
+func TestResultsRows(t *testing.T) {
+	ctx := context.Background()
+	cmd := &rdb.Command{Name: "q"}
+
+	// Read everything.
+	m := newIterMock()
+	var got []interface{}
+	for res, err := range rdb.Results(m.Query(ctx, cmd)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		for row, err := range rdb.Rows(res) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, row.Getx(0))
+		}
+	}
+	if len(got) != 4 || got[0] != int64(1) || got[3] != int64(4) {
+		t.Errorf("all: got %v", got)
+	}
+	checkReturned(t, "all", m)
+
+	// Breaking out of Rows continues with the next result.
+	m = newIterMock()
+	got = nil
+	for res, err := range rdb.Results(m.Query(ctx, cmd)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		for row, err := range rdb.Rows(res) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, row.Getx(0))
+			break
+		}
+	}
+	if len(got) != 2 || got[0] != int64(1) || got[1] != int64(3) {
+		t.Errorf("break rows: got %v", got)
+	}
+	checkReturned(t, "break rows", m)
+
+	// Breaking out of both loops closes the query.
+	m = newIterMock()
+	got = nil
+outer:
+	for res, err := range rdb.Results(m.Query(ctx, cmd)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		for row, err := range rdb.Rows(res) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, row.Getx(0))
+			break outer
+		}
+	}
+	if len(got) != 1 {
+		t.Errorf("break both: got %v", got)
+	}
+	checkReturned(t, "break both", m)
+
+	// Breaking out of Results closes the query.
+	m = newIterMock()
+	n := 0
+	for _, err := range rdb.Results(m.Query(ctx, cmd)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("break results: got %d results", n)
+	}
+	checkReturned(t, "break results", m)
+
+	// Breaking out of Rows leaves the query open for the caller.
+	m = newIterMock()
+	next := m.Query(ctx, cmd)
+	res, err := next.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range rdb.Rows(res) {
+		break
+	}
+	if buf, err := next.Buffer(); err != nil || buf == nil || len(buf.Row) != 2 {
+		t.Errorf("after break: got %v, %v", buf, err)
+	}
+	next.Close()
+	checkReturned(t, "rows only", m)
+}
+
+func TestResultsRowsError(t *testing.T) {
+	ctx := context.Background()
+	cmd := &rdb.Command{Name: "q"}
+
+	m := rdbtest.New()
+	m.ExpectName("q").WillReturnError(&rdbtest.Error{Code: 1205, Message: "deadlock"})
+	var errs int
+	for res, err := range rdb.Results(m.Query(ctx, cmd)) {
+		if err == nil || res != nil {
+			t.Errorf("got %v, %v, want error", res, err)
+		}
+		errs++
+	}
+	if errs != 1 {
+		t.Errorf("got %d errors, want 1", errs)
+	}
+	checkReturned(t, "query error", m)
+
+	// A Scan error stops Rows. Returning it from the enclosing Results
+	// loop closes the query.
+	m = newIterMock()
+	read := func() error {
+		for res, err := range rdb.Results(m.Query(ctx, cmd)) {
+			if err != nil {
+				return err
+			}
+			res.Prepx(5, new(int64))
+			for _, err := range rdb.Rows(res) {
+				if err != nil {
+					return err
+				}
+				t.Error("scan error: got row")
+			}
+		}
+		return nil
+	}
+	if err := read(); err == nil {
+		t.Error("scan error: expected error")
+	}
+	checkReturned(t, "scan error", m)
+}
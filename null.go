@@ -0,0 +1,297 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Null types hold a value that may be null. A column that is Nullable
+// may be read into them with Row.Into or Result.Prep, and they may be
+// used as a Param.Value. The value is null if Valid is false.
+//
+// Null types marshal to and from JSON null when not valid.
+
+var jsonNull = []byte("null")
+
+// NullText is a text value that may be null.
+type NullText struct {
+	String string
+	Valid  bool
+}
+
+// NullInteger is an integer value that may be null.
+type NullInteger struct {
+	Int64 int64
+	Valid bool
+}
+
+// NullFloat is a floating point value that may be null.
+type NullFloat struct {
+	Float64 float64
+	Valid   bool
+}
+
+// NullBool is a boolean value that may be null.
+type NullBool struct {
+	Bool  bool
+	Valid bool
+}
+
+// NullTime is a time value that may be null.
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+// NullBytes is a binary value that may be null.
+type NullBytes struct {
+	Bytes []byte
+	Valid bool
+}
+
+// NullDecimal is a decimal value that may be null.
+type NullDecimal struct {
+	Numeric Numeric
+	Valid   bool
+}
+
+// scanNull sets the value pointed to by dest to src and reports if
+// src is not null.
+func scanNull(dest interface{}, src interface{}) (bool, error) {
+	if src == nil {
+		reflect.ValueOf(dest).Elem().Set(reflect.Zero(reflect.TypeOf(dest).Elem()))
+		return false, nil
+	}
+	if err := convertAssign(reflect.ValueOf(dest).Elem(), src); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// marshalNull returns the JSON encoding of v, or null if not valid.
+func marshalNull(valid bool, v interface{}) ([]byte, error) {
+	if !valid {
+		return jsonNull, nil
+	}
+	return json.Marshal(v)
+}
+
+// unmarshalNull decodes data into the value pointed to by v and reports
+// if data is not null.
+func unmarshalNull(data []byte, v interface{}) (bool, error) {
+	if bytes.Equal(bytes.TrimSpace(data), jsonNull) {
+		reflect.ValueOf(v).Elem().Set(reflect.Zero(reflect.TypeOf(v).Elem()))
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Scan implements sql.Scanner.
+func (n *NullText) Scan(value interface{}) (err error) {
+	n.Valid, err = scanNull(&n.String, value)
+	return err
+}
+
+// Value implements driver.Valuer.
+func (n NullText) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.String, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n NullText) MarshalJSON() ([]byte, error) {
+	return marshalNull(n.Valid, n.String)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *NullText) UnmarshalJSON(data []byte) (err error) {
+	n.Valid, err = unmarshalNull(data, &n.String)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullInteger) Scan(value interface{}) (err error) {
+	n.Valid, err = scanNull(&n.Int64, value)
+	return err
+}
+
+// Value implements driver.Valuer.
+func (n NullInteger) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Int64, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n NullInteger) MarshalJSON() ([]byte, error) {
+	return marshalNull(n.Valid, n.Int64)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *NullInteger) UnmarshalJSON(data []byte) (err error) {
+	n.Valid, err = unmarshalNull(data, &n.Int64)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullFloat) Scan(value interface{}) (err error) {
+	n.Valid, err = scanNull(&n.Float64, value)
+	return err
+}
+
+// Value implements driver.Valuer.
+func (n NullFloat) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Float64, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n NullFloat) MarshalJSON() ([]byte, error) {
+	return marshalNull(n.Valid, n.Float64)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *NullFloat) UnmarshalJSON(data []byte) (err error) {
+	n.Valid, err = unmarshalNull(data, &n.Float64)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullBool) Scan(value interface{}) (err error) {
+	n.Valid, err = scanNull(&n.Bool, value)
+	return err
+}
+
+// Value implements driver.Valuer.
+func (n NullBool) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Bool, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n NullBool) MarshalJSON() ([]byte, error) {
+	return marshalNull(n.Valid, n.Bool)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *NullBool) UnmarshalJSON(data []byte) (err error) {
+	n.Valid, err = unmarshalNull(data, &n.Bool)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullTime) Scan(value interface{}) (err error) {
+	n.Valid, err = scanNull(&n.Time, value)
+	return err
+}
+
+// Value implements driver.Valuer.
+func (n NullTime) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Time, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n NullTime) MarshalJSON() ([]byte, error) {
+	return marshalNull(n.Valid, n.Time)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *NullTime) UnmarshalJSON(data []byte) (err error) {
+	n.Valid, err = unmarshalNull(data, &n.Time)
+	return err
+}
+
+// Scan implements sql.Scanner. The bytes are copied.
+func (n *NullBytes) Scan(value interface{}) (err error) {
+	n.Valid, err = scanNull(&n.Bytes, value)
+	return err
+}
+
+// Value implements driver.Valuer.
+func (n NullBytes) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	if n.Bytes == nil {
+		return []byte{}, nil
+	}
+	return n.Bytes, nil
+}
+
+// MarshalJSON implements json.Marshaler. Bytes are encoded as base64.
+func (n NullBytes) MarshalJSON() ([]byte, error) {
+	if n.Valid && n.Bytes == nil {
+		return []byte(`""`), nil
+	}
+	return marshalNull(n.Valid, n.Bytes)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *NullBytes) UnmarshalJSON(data []byte) (err error) {
+	n.Valid, err = unmarshalNull(data, &n.Bytes)
+	return err
+}
+
+// Scan implements sql.Scanner.
+func (n *NullDecimal) Scan(value interface{}) error {
+	if value == nil {
+		n.Numeric, n.Valid = Numeric{}, false
+		return nil
+	}
+	if err := n.Numeric.Scan(value); err != nil {
+		n.Valid = false
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (n NullDecimal) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Numeric.Value()
+}
+
+// MarshalJSON implements json.Marshaler. The decimal is encoded as a number.
+func (n NullDecimal) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return jsonNull, nil
+	}
+	return n.Numeric.MarshalJSON()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The decimal may be
+// a number or a string.
+func (n *NullDecimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), jsonNull) {
+		n.Numeric, n.Valid = Numeric{}, false
+		return nil
+	}
+	if err := n.Numeric.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+)
+
+// nullValue is implemented by pointers to the null types.
+type nullValue interface {
+	sql.Scanner
+	json.Unmarshaler
+}
+
+func TestNullTypes(t *testing.T) {
+	at := time.Date(2016, 4, 5, 6, 7, 8, 0, time.UTC)
+	list := []struct {
+		name  string
+		new   func() nullValue
+		src   interface{}
+		value driver.Value
+		json  string
+	}{
+		{"text", func() nullValue { return &rdb.NullText{} }, "a", "a", `"a"`},
+		{"text bytes", func() nullValue { return &rdb.NullText{} }, []byte("b"), "b", `"b"`},
+		{"text null", func() nullValue { return &rdb.NullText{} }, nil, nil, `null`},
+		{"integer", func() nullValue { return &rdb.NullInteger{} }, int64(5), int64(5), `5`},
+		{"integer text", func() nullValue { return &rdb.NullInteger{} }, "12", int64(12), `12`},
+		{"integer null", func() nullValue { return &rdb.NullInteger{} }, nil, nil, `null`},
+		{"float", func() nullValue { return &rdb.NullFloat{} }, 1.5, 1.5, `1.5`},
+		{"float integer", func() nullValue { return &rdb.NullFloat{} }, int64(2), 2.0, `2`},
+		{"float null", func() nullValue { return &rdb.NullFloat{} }, nil, nil, `null`},
+		{"bool", func() nullValue { return &rdb.NullBool{} }, true, true, `true`},
+		{"bool integer", func() nullValue { return &rdb.NullBool{} }, int64(0), false, `false`},
+		{"bool null", func() nullValue { return &rdb.NullBool{} }, nil, nil, `null`},
+		{"time", func() nullValue { return &rdb.NullTime{} }, at, at, `"2016-04-05T06:07:08Z"`},
+		{"time null", func() nullValue { return &rdb.NullTime{} }, nil, nil, `null`},
+		{"bytes", func() nullValue { return &rdb.NullBytes{} }, []byte("x"), []byte("x"), `"eA=="`},
+		{"bytes empty", func() nullValue { return &rdb.NullBytes{} }, []byte{}, []byte{}, `""`},
+		{"bytes null", func() nullValue { return &rdb.NullBytes{} }, nil, nil, `null`},
+		{"decimal", func() nullValue { return &rdb.NullDecimal{} }, "1.50", "1.50", `1.50`},
+		{"decimal numeric", func() nullValue { return &rdb.NullDecimal{} }, rdb.NewNumeric(-25, 1), "-2.5", `-2.5`},
+		{"decimal null", func() nullValue { return &rdb.NullDecimal{} }, nil, nil, `null`},
+	}
+	for _, item := range list {
+		n := item.new()
+		if err := n.Scan(item.src); err != nil {
+			t.Errorf("%s: scan: %v", item.name, err)
+			continue
+		}
+		value, err := n.(driver.Valuer).Value()
+		if err != nil {
+			t.Errorf("%s: value: %v", item.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, item.value) {
+			t.Errorf("%s: got value %#v, want %#v", item.name, value, item.value)
+		}
+		data, err := json.Marshal(n)
+		if err != nil {
+			t.Errorf("%s: marshal: %v", item.name, err)
+			continue
+		}
+		if string(data) != item.json {
+			t.Errorf("%s: got JSON %s, want %s", item.name, data, item.json)
+		}
+
+		back := item.new()
+		if err = json.Unmarshal([]byte(item.json), back); err != nil {
+			t.Errorf("%s: unmarshal: %v", item.name, err)
+			continue
+		}
+		if value, _ = back.(driver.Valuer).Value(); !reflect.DeepEqual(value, item.value) {
+			t.Errorf("%s: got value %#v after JSON round trip, want %#v", item.name, value, item.value)
+		}
+	}
+}
+
+func TestNullReset(t *testing.T) {
+	valid := func() []nullValue {
+		return []nullValue{
+			&rdb.NullText{String: "a", Valid: true},
+			&rdb.NullInteger{Int64: 1, Valid: true},
+			&rdb.NullFloat{Float64: 1, Valid: true},
+			&rdb.NullBool{Bool: true, Valid: true},
+			&rdb.NullTime{Time: time.Now(), Valid: true},
+			&rdb.NullBytes{Bytes: []byte("a"), Valid: true},
+			&rdb.NullDecimal{Numeric: rdb.NewNumeric(1, 0), Valid: true},
+		}
+	}
+	isZero := func(n nullValue) bool {
+		return reflect.DeepEqual(n, reflect.New(reflect.TypeOf(n).Elem()).Interface())
+	}
+	for _, n := range valid() {
+		if err := n.Scan(nil); err != nil || !isZero(n) {
+			t.Errorf("%T: got %+v, %v after scanning null", n, n, err)
+		}
+	}
+	for _, n := range valid() {
+		if err := n.UnmarshalJSON([]byte(" null ")); err != nil || !isZero(n) {
+			t.Errorf("%T: got %+v, %v after unmarshaling null", n, n, err)
+		}
+	}
+}
+
+func TestNullErrors(t *testing.T) {
+	list := []struct {
+		name string
+		n    nullValue
+		src  interface{}
+	}{
+		{"integer", &rdb.NullInteger{Valid: true}, "x"},
+		{"float", &rdb.NullFloat{Valid: true}, "x"},
+		{"bool", &rdb.NullBool{Valid: true}, "maybe"},
+		{"time", &rdb.NullTime{Valid: true}, int64(1)},
+		{"decimal", &rdb.NullDecimal{Valid: true}, "1.2.3"},
+	}
+	for _, item := range list {
+		if err := item.n.Scan(item.src); err == nil {
+			t.Errorf("%s: scan: expected error", item.name)
+		}
+		if valid := reflect.ValueOf(item.n).Elem().FieldByName("Valid").Bool(); valid {
+			t.Errorf("%s: valid after a failed scan", item.name)
+		}
+		if err := item.n.UnmarshalJSON([]byte(`{}`)); err == nil {
+			t.Errorf("%s: unmarshal: expected error", item.name)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Numeric is an exact decimal number for TypeDecimal and TypeMoney values.
+// The value is the coefficient times ten to the power of negative scale,
+// so a coefficient of 1234 with a scale of 2 is 12.34.
+//
+// The zero value is 0. Numeric values are immutable; methods return
+// a new value. Rounding is half away from zero.
+type Numeric struct {
+	coef  *big.Int // Nil is zero.
+	scale int
+}
+
+// maxNumericScale limits the exponent and resulting scale of a parsed
+// numeric, so untrusted text cannot force very large values.
+const maxNumericScale = 4000
+
+var (
+	bigOne = big.NewInt(1)
+	bigTen = big.NewInt(10)
+)
+
+// pow10 returns ten to the power of n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// NewNumeric returns coef * 10^-scale.
+func NewNumeric(coef int64, scale int) Numeric {
+	return NewNumericBig(big.NewInt(coef), scale)
+}
+
+// NewNumericBig returns coef * 10^-scale. The coefficient is copied.
+func NewNumericBig(coef *big.Int, scale int) Numeric {
+	c := new(big.Int).Set(coef)
+	if scale < 0 {
+		c.Mul(c, pow10(-scale))
+		scale = 0
+	}
+	return Numeric{coef: c, scale: scale}
+}
+
+// ParseNumeric parses a decimal such as "-12.34" or "1.5e3".
+// The scale is the number of digits after the decimal point.
+// An exponent or resulting scale past 4000 digits is an error.
+func ParseNumeric(s string) (Numeric, error) {
+	text := strings.TrimSpace(s)
+	exp := 0
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		e, err := strconv.Atoi(text[i+1:])
+		if err != nil {
+			return Numeric{}, fmt.Errorf("Invalid numeric %q", s)
+		}
+		if e > maxNumericScale || e < -maxNumericScale {
+			return Numeric{}, fmt.Errorf("Numeric %q exponent out of range", s)
+		}
+		exp = e
+		text = text[:i]
+	}
+	neg := false
+	if len(text) > 0 && (text[0] == '-' || text[0] == '+') {
+		neg = text[0] == '-'
+		text = text[1:]
+	}
+	scale := 0
+	if i := strings.IndexByte(text, '.'); i >= 0 {
+		scale = len(text) - i - 1
+		text = text[:i] + text[i+1:]
+	}
+	if len(text) == 0 {
+		return Numeric{}, fmt.Errorf("Invalid numeric %q", s)
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] < '0' || text[i] > '9' {
+			return Numeric{}, fmt.Errorf("Invalid numeric %q", s)
+		}
+	}
+	if scale-exp > maxNumericScale || scale-exp < -maxNumericScale {
+		return Numeric{}, fmt.Errorf("Numeric %q scale out of range", s)
+	}
+	coef, _ := new(big.Int).SetString(text, 10)
+	if neg {
+		coef.Neg(coef)
+	}
+	return NewNumericBig(coef, scale-exp), nil
+}
+
+func (d Numeric) coefficient() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// Coefficient returns a copy of the unscaled value.
+func (d Numeric) Coefficient() *big.Int {
+	return new(big.Int).Set(d.coefficient())
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Numeric) Scale() int {
+	return d.scale
+}
+
+// Precision returns the number of digits in the coefficient.
+func (d Numeric) Precision() int {
+	c := d.coefficient()
+	if c.Sign() == 0 {
+		return 1
+	}
+	return len(new(big.Int).Abs(c).String())
+}
+
+// Sign returns -1, 0, or 1 if d is negative, zero, or positive.
+func (d Numeric) Sign() int {
+	return d.coefficient().Sign()
+}
+
+// rescale returns the coefficient of d at a scale not less than d's scale.
+func (d Numeric) rescale(scale int) *big.Int {
+	c := d.coefficient()
+	if scale == d.scale {
+		return c
+	}
+	return new(big.Int).Mul(c, pow10(scale-d.scale))
+}
+
+// align returns the coefficients of d and x at the larger of their scales.
+func (d Numeric) align(x Numeric) (a, b *big.Int, scale int) {
+	scale = d.scale
+	if x.scale > scale {
+		scale = x.scale
+	}
+	return d.rescale(scale), x.rescale(scale), scale
+}
+
+// Cmp returns -1, 0, or 1 if d is less than, equal to, or greater than x.
+func (d Numeric) Cmp(x Numeric) int {
+	a, b, _ := d.align(x)
+	return a.Cmp(b)
+}
+
+// Neg returns -d.
+func (d Numeric) Neg() Numeric {
+	return Numeric{coef: new(big.Int).Neg(d.coefficient()), scale: d.scale}
+}
+
+// Abs returns the absolute value of d.
+func (d Numeric) Abs() Numeric {
+	return Numeric{coef: new(big.Int).Abs(d.coefficient()), scale: d.scale}
+}
+
+// Add returns d + x in the larger of their scales.
+func (d Numeric) Add(x Numeric) Numeric {
+	a, b, scale := d.align(x)
+	return Numeric{coef: new(big.Int).Add(a, b), scale: scale}
+}
+
+// Sub returns d - x in the larger of their scales.
+func (d Numeric) Sub(x Numeric) Numeric {
+	a, b, scale := d.align(x)
+	return Numeric{coef: new(big.Int).Sub(a, b), scale: scale}
+}
+
+// Mul returns d * x in the sum of their scales.
+func (d Numeric) Mul(x Numeric) Numeric {
+	return Numeric{coef: new(big.Int).Mul(d.coefficient(), x.coefficient()), scale: d.scale + x.scale}
+}
+
+// Quo returns d / x rounded to scale. A negative scale is treated as 0.
+func (d Numeric) Quo(x Numeric, scale int) (Numeric, error) {
+	if x.Sign() == 0 {
+		return Numeric{}, fmt.Errorf("Numeric division by zero")
+	}
+	if scale < 0 {
+		scale = 0
+	}
+	return roundRat(new(big.Rat).Quo(d.Rat(), x.Rat()), scale), nil
+}
+
+// Round returns d rounded to scale. If scale is larger than d's scale,
+// the value is unchanged and trailing zeros are added.
+func (d Numeric) Round(scale int) Numeric {
+	if scale < 0 {
+		scale = 0
+	}
+	if scale >= d.scale {
+		return Numeric{coef: d.rescale(scale), scale: scale}
+	}
+	return roundRat(d.Rat(), scale)
+}
+
+// roundRat returns r rounded to scale.
+func roundRat(r *big.Rat, scale int) Numeric {
+	n := new(big.Int).Mul(r.Num(), pow10(scale))
+	q, rem := new(big.Int).QuoRem(n, r.Denom(), new(big.Int))
+	if rem.Sign() != 0 && new(big.Int).Abs(rem.Lsh(rem, 1)).Cmp(r.Denom()) >= 0 {
+		if n.Sign() < 0 {
+			q.Sub(q, bigOne)
+		} else {
+			q.Add(q, bigOne)
+		}
+	}
+	return Numeric{coef: q, scale: scale}
+}
+
+// Fit returns d rounded to scale, or an error if the result has more
+// than precision digits. Use the Column Precision and Scale to check
+// a value against a column. A precision of zero is not checked.
+func (d Numeric) Fit(precision, scale int) (Numeric, error) {
+	if scale < 0 || (precision > 0 && scale > precision) {
+		return Numeric{}, fmt.Errorf("Invalid decimal precision %d and scale %d", precision, scale)
+	}
+	r := d.Round(scale)
+	if precision > 0 && r.Sign() != 0 && r.Precision() > precision {
+		return Numeric{}, fmt.Errorf("Numeric %s overflows precision %d scale %d", d, precision, scale)
+	}
+	return r, nil
+}
+
+// Rat returns d as an exact rational number.
+func (d Numeric) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.coefficient(), pow10(d.scale))
+}
+
+// Float64 returns the nearest float64 value to d.
+func (d Numeric) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// Int64 returns d as an integer. The result is false if d has
+// a fractional part or does not fit in an int64.
+func (d Numeric) Int64() (int64, bool) {
+	q, rem := new(big.Int).QuoRem(d.coefficient(), pow10(d.scale), new(big.Int))
+	if rem.Sign() != 0 || !q.IsInt64() {
+		return 0, false
+	}
+	return q.Int64(), true
+}
+
+// String returns d in plain notation with scale digits after the decimal point.
+func (d Numeric) String() string {
+	c := d.coefficient()
+	digits := new(big.Int).Abs(c).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if c.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Scan implements sql.Scanner. Null values cannot be scanned, use NullDecimal.
+func (d *Numeric) Scan(value interface{}) error {
+	var err error
+	switch v := value.(type) {
+	case nil:
+		return fmt.Errorf("Cannot assign null to rdb.Numeric")
+	case Numeric:
+		*d = v
+		return nil
+	case string:
+		*d, err = ParseNumeric(v)
+		return err
+	case []byte:
+		*d, err = ParseNumeric(string(v))
+		return err
+	}
+	switch rv := reflect.ValueOf(value); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		*d = NewNumeric(rv.Int(), 0)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		*d = NewNumericBig(new(big.Int).SetUint64(rv.Uint()), 0)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		*d, err = ParseNumeric(strconv.FormatFloat(rv.Float(), 'f', -1, rv.Type().Bits()))
+		return err
+	case reflect.String:
+		*d, err = ParseNumeric(rv.String())
+		return err
+	}
+	return fmt.Errorf("Cannot convert %T to rdb.Numeric", value)
+}
+
+// Value implements driver.Valuer. The decimal is sent as text.
+func (d Numeric) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// MarshalJSON implements json.Marshaler. The decimal is encoded as a number.
+func (d Numeric) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The decimal may be
+// a number or a string. A JSON null leaves d unchanged.
+func (d *Numeric) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, jsonNull) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+	v, err := ParseNumeric(string(data))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+)
+
+func mustNumeric(t *testing.T, s string) rdb.Numeric {
+	t.Helper()
+	d, err := rdb.ParseNumeric(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestParseNumeric(t *testing.T) {
+	list := []struct {
+		in    string
+		want  string
+		scale int
+	}{
+		{"12.340", "12.340", 3},
+		{"-0.05", "-0.05", 2},
+		{"+7", "7", 0},
+		{".5", "0.5", 1},
+		{" 42 ", "42", 0},
+		{"1.5e3", "1500", 0},
+		{"1.25e-1", "0.125", 3},
+		{"1E2", "100", 0},
+		{"1e4000", "1" + strings.Repeat("0", 4000), 0},
+		{"1e-4000", "0." + strings.Repeat("0", 3999) + "1", 4000},
+	}
+	for _, item := range list {
+		d, err := rdb.ParseNumeric(item.in)
+		if err != nil {
+			t.Errorf("%q: %v", item.in, err)
+			continue
+		}
+		if got := d.String(); got != item.want {
+			t.Errorf("%q: got %s, want %s", item.in, got, item.want)
+		}
+		if d.Scale() != item.scale {
+			t.Errorf("%q: got scale %d, want %d", item.in, d.Scale(), item.scale)
+		}
+	}
+}
+
+func TestParseNumericInvalid(t *testing.T) {
+	list := []string{
+		"", "-", "1.2.3", "abc", "1e", "1e+", "0x10", "1,5",
+		"1e4001", "1e-4001", "1e30000000", "1e-30000000",
+		"0." + strings.Repeat("1", 3999) + "e-2",
+	}
+	for _, in := range list {
+		if d, err := rdb.ParseNumeric(in); err == nil {
+			t.Errorf("%q: expected error, got %s", in, d)
+		}
+	}
+}
+
+func TestNumericLimitFast(t *testing.T) {
+	start := time.Now()
+	var d rdb.Numeric
+	for _, in := range []string{`"1e30000000"`, `"1e-30000000"`, `1e30000000`} {
+		if err := json.Unmarshal([]byte(in), &d); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+	if err := d.Scan("1e-30000000"); err == nil {
+		t.Error("Scan: expected error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("rejecting large exponents took %v", elapsed)
+	}
+}
+
+func TestNumericArithmetic(t *testing.T) {
+	p := func(s string) rdb.Numeric { return mustNumeric(t, s) }
+	list := []struct {
+		name string
+		got  rdb.Numeric
+		want string
+	}{
+		{"add", p("1.25").Add(p("-3.5")), "-2.25"},
+		{"sub", p("1").Sub(p("0.001")), "0.999"},
+		{"mul", p("1.25").Mul(p("2.5")), "3.125"},
+		{"neg", p("1.5").Neg(), "-1.5"},
+		{"abs", p("-1.5").Abs(), "1.5"},
+		{"round up", p("2.345").Round(2), "2.35"},
+		{"round negative", p("-2.345").Round(2), "-2.35"},
+		{"round down", p("-2.344").Round(2), "-2.34"},
+		{"round scale up", p("2.5").Round(3), "2.500"},
+		{"round integer", p("2.5").Round(0), "3"},
+		{"zero add", rdb.Numeric{}.Add(p("1.1")), "1.1"},
+		{"new", rdb.NewNumeric(1234, 2), "12.34"},
+		{"new negative scale", rdb.NewNumeric(12, -2), "1200"},
+	}
+	for _, item := range list {
+		if s := item.got.String(); s != item.want {
+			t.Errorf("%s: got %s, want %s", item.name, s, item.want)
+		}
+	}
+	q, err := p("1").Quo(p("3"), 4)
+	if err != nil || q.String() != "0.3333" {
+		t.Errorf("quo: got %s, %v", q, err)
+	}
+	q, err = p("1250").Quo(p("1"), -2)
+	if err != nil || q.String() != "1250" || q.Scale() != 0 {
+		t.Errorf("quo negative scale: got %s scale %d, %v", q, q.Scale(), err)
+	}
+	if _, err := p("1").Quo(rdb.Numeric{}, 2); err == nil {
+		t.Error("quo: expected division by zero error")
+	}
+	if p("1.50").Cmp(p("1.5")) != 0 || p("2").Cmp(p("10")) >= 0 || p("-1").Sign() != -1 {
+		t.Error("cmp or sign")
+	}
+	if n, ok := p("12.00").Int64(); !ok || n != 12 {
+		t.Errorf("int64: got %d, %t", n, ok)
+	}
+	if _, ok := p("12.5").Int64(); ok {
+		t.Error("int64: expected fraction to fail")
+	}
+	if f := p("1.25").Float64(); f != 1.25 {
+		t.Errorf("float64: got %g", f)
+	}
+	if prec := p("-123.45").Precision(); prec != 5 {
+		t.Errorf("precision: got %d", prec)
+	}
+}
+
+func TestNumericFit(t *testing.T) {
+	list := []struct {
+		in               string
+		precision, scale int
+		want             string // Empty for an error.
+	}{
+		{"123.456", 5, 2, "123.46"},
+		{"999.994", 5, 2, "999.99"},
+		{"999.995", 5, 2, ""},
+		{"1234.5", 5, 2, ""},
+		{"0", 1, 0, "0"},
+		{"123456789", 0, 0, "123456789"},
+		{"1", 2, 3, ""},
+	}
+	for _, item := range list {
+		got, err := mustNumeric(t, item.in).Fit(item.precision, item.scale)
+		switch {
+		case len(item.want) == 0 && err == nil:
+			t.Errorf("%s (%d,%d): expected error, got %s", item.in, item.precision, item.scale, got)
+		case len(item.want) != 0 && err != nil:
+			t.Errorf("%s (%d,%d): %v", item.in, item.precision, item.scale, err)
+		case len(item.want) != 0 && got.String() != item.want:
+			t.Errorf("%s (%d,%d): got %s, want %s", item.in, item.precision, item.scale, got, item.want)
+		}
+	}
+}
+
+func TestNumericScanValueJSON(t *testing.T) {
+	list := []struct {
+		in   interface{}
+		want string
+	}{
+		{"1.50", "1.50"},
+		{[]byte("-2"), "-2"},
+		{int64(7), "7"},
+		{uint64(1) << 63, "9223372036854775808"},
+		{1.25, "1.25"},
+		{mustNumeric(t, "3.3"), "3.3"},
+	}
+	for _, item := range list {
+		var d rdb.Numeric
+		if err := d.Scan(item.in); err != nil {
+			t.Errorf("scan %T: %v", item.in, err)
+			continue
+		}
+		if d.String() != item.want {
+			t.Errorf("scan %T: got %s, want %s", item.in, d, item.want)
+		}
+	}
+	var d rdb.Numeric
+	if err := d.Scan(nil); err == nil {
+		t.Error("scan nil: expected error")
+	}
+	if v, err := mustNumeric(t, "1.50").Value(); err != nil || v != "1.50" {
+		t.Errorf("value: got %v, %v", v, err)
+	}
+
+	var out struct {
+		A rdb.Numeric
+		B rdb.Numeric
+		C rdb.NullDecimal
+		D rdb.NullDecimal
+	}
+	if err := json.Unmarshal([]byte(`{"A":12.50,"B":"-0.1","C":null,"D":3}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.A.String() != "12.50" || out.B.String() != "-0.1" || out.C.Valid || !out.D.Valid || out.D.Numeric.String() != "3" {
+		t.Errorf("unmarshal: got %+v", out)
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"A":12.50,"B":-0.1,"C":null,"D":3}`; string(b) != want {
+		t.Errorf("marshal: got %s, want %s", b, want)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package rdb
+
+import (
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+// QueryOne runs the command on the pool from the context and returns the
+// single row of the first result as a T. T must be a struct.
+// ErrNoRows or ErrTooManyRows is returned if there is not exactly one row.
+func QueryOne[T any](ctx context.Context, cmd *Command, params ...Param) (T, error) {
+	pool, has := FromContext(ctx)
+	if !has {
+		var zero T
+		return zero, errNoPoolContext
+	}
+	return QueryOneWith[T](ctx, pool, cmd, params...)
+}
+
+// QueryAll runs the command on the pool from the context and returns the
+// rows of the first result as a list of T. T must be a struct or a pointer
+// to a struct.
+func QueryAll[T any](ctx context.Context, cmd *Command, params ...Param) ([]T, error) {
+	pool, has := FromContext(ctx)
+	if !has {
+		return nil, errNoPoolContext
+	}
+	return QueryAllWith[T](ctx, pool, cmd, params...)
+}
+
+// QueryScalar runs the command on the pool from the context and returns the
+// first column of the single row of the first result, converted to a T.
+// ErrNoRows or ErrTooManyRows is returned if there is not exactly one row.
+func QueryScalar[T any](ctx context.Context, cmd *Command, params ...Param) (T, error) {
+	pool, has := FromContext(ctx)
+	if !has {
+		var zero T
+		return zero, errNoPoolContext
+	}
+	return QueryScalarWith[T](ctx, pool, cmd, params...)
+}
+
+// QueryOneWith is QueryOne using the Queryer q.
+func QueryOneWith[T any](ctx context.Context, q Queryer, cmd *Command, params ...Param) (T, error) {
+	var item T
+	err := queryOne(ctx, q, cmd, params, func(schema Schema, row Row) error {
+		v := reflect.ValueOf(&item).Elem()
+		m, err := newStructMap(v.Type(), schema)
+		if err != nil {
+			return err
+		}
+		return m.fill(v, row)
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return item, nil
+}
+
+// QueryAllWith is QueryAll using the Queryer q.
+func QueryAllWith[T any](ctx context.Context, q Queryer, cmd *Command, params ...Param) ([]T, error) {
+	next := q.Query(ctx, cmd, params...)
+	defer next.Close()
+
+	buf, err := next.Buffer()
+	if err != nil {
+		return nil, err
+	}
+	if buf == nil {
+		return nil, nil
+	}
+	return BufferAs[T](buf)
+}
+
+// QueryScalarWith is QueryScalar using the Queryer q.
+func QueryScalarWith[T any](ctx context.Context, q Queryer, cmd *Command, params ...Param) (T, error) {
+	var value T
+	err := queryOne(ctx, q, cmd, params, func(schema Schema, row Row) error {
+		if len(schema) == 0 {
+			return errNoColumns
+		}
+		return convertAssign(reflect.ValueOf(&value).Elem(), row.Getx(0))
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return value, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package rdb_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kardianos/rdb"
+	"github.com/kardianos/rdb/rdbtest"
+	"golang.org/x/net/context"
+)
+
+func TestQueryGeneric(t *testing.T) {
+	cmd := &rdb.Command{Name: "q"}
+	one := func(ctx context.Context, q rdb.Queryer) (interface{}, error) {
+		return rdb.QueryOne[logRow](ctx, cmd)
+	}
+	oneWith := func(ctx context.Context, q rdb.Queryer) (interface{}, error) {
+		return rdb.QueryOneWith[logRow](ctx, q, cmd)
+	}
+	all := func(ctx context.Context, q rdb.Queryer) (interface{}, error) {
+		return rdb.QueryAll[logRow](ctx, cmd)
+	}
+	allWith := func(ctx context.Context, q rdb.Queryer) (interface{}, error) {
+		return rdb.QueryAllWith[logRow](ctx, q, cmd)
+	}
+	scalar := func(ctx context.Context, q rdb.Queryer) (interface{}, error) {
+		return rdb.QueryScalar[int64](ctx, cmd)
+	}
+	scalarWith := func(ctx context.Context, q rdb.Queryer) (interface{}, error) {
+		return rdb.QueryScalarWith[int64](ctx, q, cmd)
+	}
+
+	first := []interface{}{int64(1), "a", nil, int64(5)}
+	second := []interface{}{int64(2), "b", nil, int64(6)}
+	mismatch := []interface{}{"x", "a", nil, int64(5)}
+	firstRow := logRow{logBase: logBase{ID: 1}, Message: "a", Count: 5}
+	queryErr := &rdbtest.Error{Code: 1205, Message: "deadlock"}
+
+	noRows := rdb.ErrNoRows.Error()
+	tooMany := rdb.ErrTooManyRows.Error()
+	list := []struct {
+		name   string
+		run    func(ctx context.Context, q rdb.Queryer) (interface{}, error)
+		result []*rdb.Buffer
+		err    error // Returned by the query.
+		want   interface{}
+		errStr string // Empty if no error is expected.
+	}{
+		{"one", one, []*rdb.Buffer{newBuffer(logSchema, first)}, nil, firstRow, ""},
+		{"one with", oneWith, []*rdb.Buffer{newBuffer(logSchema, first)}, nil, firstRow, ""},
+		{"one no rows", one, []*rdb.Buffer{newBuffer(logSchema)}, nil, logRow{}, noRows},
+		{"one no result", one, nil, nil, logRow{}, noRows},
+		{"one two rows", one, []*rdb.Buffer{newBuffer(logSchema, first, second)}, nil, logRow{}, tooMany},
+		{"one no columns", one, []*rdb.Buffer{newBuffer(rdb.Schema{}, []interface{}{})}, nil, logRow{}, `Column "id"`},
+		{"one mismatch", one, []*rdb.Buffer{newBuffer(logSchema, mismatch)}, nil, logRow{}, `Column "id"`},
+		{"one query error", one, nil, queryErr, logRow{}, "deadlock"},
+
+		{"all", all, []*rdb.Buffer{newBuffer(logSchema, first)}, nil, []logRow{firstRow}, ""},
+		{"all with", allWith, []*rdb.Buffer{newBuffer(logSchema, first)}, nil, []logRow{firstRow}, ""},
+		{"all no result", all, nil, nil, []logRow(nil), ""},
+		{"all mismatch", all, []*rdb.Buffer{newBuffer(logSchema, first, mismatch)}, nil, []logRow(nil), `Column "id"`},
+		{"all query error", all, nil, queryErr, []logRow(nil), "deadlock"},
+
+		{"scalar", scalar, []*rdb.Buffer{newBuffer(logSchema, first)}, nil, int64(1), ""},
+		{"scalar with", scalarWith, []*rdb.Buffer{newBuffer(logSchema, first)}, nil, int64(1), ""},
+		{"scalar no rows", scalar, []*rdb.Buffer{newBuffer(logSchema)}, nil, int64(0), noRows},
+		{"scalar no result", scalar, nil, nil, int64(0), noRows},
+		{"scalar two rows", scalar, []*rdb.Buffer{newBuffer(logSchema, first, second)}, nil, int64(0), tooMany},
+		{"scalar no columns", scalar, []*rdb.Buffer{newBuffer(rdb.Schema{}, []interface{}{})}, nil, int64(0), "No columns in result"},
+		{"scalar mismatch", scalar, []*rdb.Buffer{newBuffer(logSchema, mismatch)}, nil, int64(0), "x"},
+		{"scalar query error", scalar, nil, queryErr, int64(0), "deadlock"},
+	}
+	for _, item := range list {
+		m := rdbtest.New()
+		m.ExpectName("q").WillReturn(item.result...).WillReturnError(item.err)
+		ctx := rdb.NewContext(context.Background(), m)
+
+		got, err := item.run(ctx, m)
+		switch {
+		case item.errStr == "" && err != nil:
+			t.Errorf("%s: %v", item.name, err)
+		case item.errStr != "" && err == nil:
+			t.Errorf("%s: expected error %q", item.name, item.errStr)
+		case err != nil && !strings.Contains(err.Error(), item.errStr):
+			t.Errorf("%s: got error %q, want %q", item.name, err, item.errStr)
+		}
+		if !reflect.DeepEqual(got, item.want) {
+			t.Errorf("%s: got %#v, want %#v", item.name, got, item.want)
+		}
+		// The query is closed on every path.
+		if status := m.Status(); status.Available() != status.Capacity() {
+			t.Errorf("%s: query not closed", item.name)
+		}
+		if err = m.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %v", item.name, err)
+		}
+	}
+
+	// Without a pool in the context.
+	ctx := context.Background()
+	if _, err := rdb.QueryOne[logRow](ctx, cmd); err == nil {
+		t.Error("QueryOne without pool: expected error")
+	}
+	if _, err := rdb.QueryAll[logRow](ctx, cmd); err == nil {
+		t.Error("QueryAll without pool: expected error")
+	}
+	if _, err := rdb.QueryScalar[int64](ctx, cmd); err == nil {
+		t.Error("QueryScalar without pool: expected error")
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Range is a range of values for TypeRange values. Elem is the type of
+// the bounds, such as TypeInt32 or TypeDate. Set Elem before scanning
+// into a Range. A nil bound is unbounded.
+//
+// The text form is "[1,10)", where a square bracket is an inclusive
+// bound and a parenthesis is an exclusive bound, or "empty".
+// Bounds that are empty or contain white space or any of `()[],"\`
+// are quoted.
+//
+// The binary form is specific to rdb and is not a database wire format.
+// It is the element type as a big-endian uint32, a flag byte, then
+// each bound that is set as a big-endian int32 length and
+// the bound bytes. The flags are:
+// 0x01 empty, 0x02 lower inclusive, 0x04 upper inclusive,
+// 0x08 lower unbounded, 0x10 upper unbounded.
+type Range struct {
+	Elem  Type
+	Lower interface{}
+	Upper interface{}
+
+	LowerInclusive bool
+	UpperInclusive bool
+
+	// Empty is set if the range contains no values. The bounds are ignored.
+	Empty bool
+}
+
+const (
+	rangeEmpty        = 0x01
+	rangeLowerInc     = 0x02
+	rangeUpperInc     = 0x04
+	rangeLowerUnbound = 0x08
+	rangeUpperUnbound = 0x10
+)
+
+// writeBound writes the text form of a range bound.
+func writeBound(buf *bytes.Buffer, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	s, err := formatElem(v)
+	if err != nil {
+		return err
+	}
+	buf.WriteString(quoteElem(s, `()[],"\`))
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+// An unbounded side is always written as exclusive.
+func (r Range) MarshalText() ([]byte, error) {
+	if r.Empty {
+		return []byte("empty"), nil
+	}
+	buf := &bytes.Buffer{}
+	if r.LowerInclusive && r.Lower != nil {
+		buf.WriteByte('[')
+	} else {
+		buf.WriteByte('(')
+	}
+	if err := writeBound(buf, r.Lower); err != nil {
+		return nil, err
+	}
+	buf.WriteByte(',')
+	if err := writeBound(buf, r.Upper); err != nil {
+		return nil, err
+	}
+	if r.UpperInclusive && r.Upper != nil {
+		buf.WriteByte(']')
+	} else {
+		buf.WriteByte(')')
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Bounds are parsed as the Elem type.
+func (r *Range) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if strings.EqualFold(s, "empty") {
+		*r = Range{Elem: r.Elem, Empty: true}
+		return nil
+	}
+	if len(s) < 3 || (s[0] != '[' && s[0] != '(') || (s[len(s)-1] != ']' && s[len(s)-1] != ')') {
+		return fmt.Errorf("Invalid range %q", text)
+	}
+	v := Range{
+		Elem:           r.Elem,
+		LowerInclusive: s[0] == '[',
+		UpperInclusive: s[len(s)-1] == ']',
+	}
+	p := &elemParser{s: s[1 : len(s)-1]}
+	var err error
+	if v.Lower, err = p.elem(r.Elem, ",", false); err != nil {
+		return fmt.Errorf("Invalid range %q: %v", text, err)
+	}
+	p.space()
+	if p.peek() != ',' {
+		return fmt.Errorf("Invalid range %q", text)
+	}
+	p.i++
+	if v.Upper, err = p.elem(r.Elem, "", false); err != nil {
+		return fmt.Errorf("Invalid range %q: %v", text, err)
+	}
+	p.space()
+	if p.i < len(p.s) {
+		return fmt.Errorf("Invalid range %q", text)
+	}
+	*r = v
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (r Range) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 5, 5+24)
+	binary.BigEndian.PutUint32(b, uint32(r.Elem))
+	if r.Empty {
+		b[4] = rangeEmpty
+		return b, nil
+	}
+	var err error
+	switch {
+	case r.Lower == nil:
+		b[4] |= rangeLowerUnbound
+	case r.LowerInclusive:
+		b[4] |= rangeLowerInc
+	}
+	switch {
+	case r.Upper == nil:
+		b[4] |= rangeUpperUnbound
+	case r.UpperInclusive:
+		b[4] |= rangeUpperInc
+	}
+	if r.Lower != nil {
+		if b, err = appendItem(b, r.Elem, r.Lower); err != nil {
+			return nil, err
+		}
+	}
+	if r.Upper != nil {
+		if b, err = appendItem(b, r.Elem, r.Upper); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+// The Elem type is read from the data.
+func (r *Range) UnmarshalBinary(data []byte) error {
+	if len(data) < 5 {
+		return fmt.Errorf("Invalid binary range length %d", len(data))
+	}
+	v := Range{Elem: Type(binary.BigEndian.Uint32(data))}
+	flags := data[4]
+	data = data[5:]
+	if flags&rangeEmpty != 0 {
+		v.Empty = true
+		*r = v
+		return nil
+	}
+	v.LowerInclusive = flags&rangeLowerInc != 0
+	v.UpperInclusive = flags&rangeUpperInc != 0
+	var err error
+	if flags&rangeLowerUnbound == 0 {
+		if v.Lower, data, err = readItem(v.Elem, data); err != nil {
+			return err
+		}
+	}
+	if flags&rangeUpperUnbound == 0 {
+		if v.Upper, data, err = readItem(v.Elem, data); err != nil {
+			return err
+		}
+	}
+	if len(data) != 0 {
+		return fmt.Errorf("Invalid binary range, %d bytes after upper bound", len(data))
+	}
+	*r = v
+	return nil
+}
+
+// Scan implements sql.Scanner. Text is parsed as the Elem type.
+// Null values cannot be scanned, use a pointer.
+func (r *Range) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return fmt.Errorf("Cannot assign null to rdb.Range")
+	case Range:
+		*r = v
+		return nil
+	case []byte:
+		return r.UnmarshalText(v)
+	case string:
+		return r.UnmarshalText([]byte(v))
+	}
+	return fmt.Errorf("Cannot convert %T to rdb.Range", value)
+}
+
+// Value implements driver.Valuer. The range is sent as text.
+func (r Range) Value() (driver.Value, error) {
+	b, err := r.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
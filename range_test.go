@@ -0,0 +1,131 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+)
+
+func TestRangeText(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2016, 1, d, 0, 0, 0, 0, time.UTC) }
+	list := []struct {
+		r    rdb.Range
+		text string
+	}{
+		{rdb.Range{Elem: rdb.TypeInt32, Lower: int64(1), Upper: int64(10), LowerInclusive: true}, `[1,10)`},
+		{rdb.Range{Elem: rdb.TypeInt32, Lower: int64(1), Upper: int64(10), UpperInclusive: true}, `(1,10]`},
+		{rdb.Range{Elem: rdb.TypeInt32, Upper: int64(5), UpperInclusive: true}, `(,5]`},
+		{rdb.Range{Elem: rdb.TypeInt32, Lower: int64(5)}, `(5,)`},
+		{rdb.Range{Elem: rdb.TypeInt32}, `(,)`},
+		{rdb.Range{Elem: rdb.TypeInt32, Empty: true}, `empty`},
+		{rdb.Range{Elem: rdb.TypeDate, Lower: day(1), Upper: day(2), LowerInclusive: true}, `[2016-01-01T00:00:00Z,2016-01-02T00:00:00Z)`},
+		{rdb.Range{Elem: rdb.TypeText, Lower: "a b", Upper: "c,d", LowerInclusive: true}, `["a b","c,d")`},
+		{rdb.Range{Elem: rdb.TypeDecimal, Lower: rdb.NewNumeric(15, 1), Upper: rdb.NewNumeric(25, 1)}, `(1.5,2.5)`},
+	}
+	for _, item := range list {
+		text, err := item.r.MarshalText()
+		if err != nil {
+			t.Errorf("%s: %v", item.text, err)
+			continue
+		}
+		if string(text) != item.text {
+			t.Errorf("got %s, want %s", text, item.text)
+		}
+		back := rdb.Range{Elem: item.r.Elem}
+		if err = back.Scan(string(text)); err != nil {
+			t.Errorf("%s: %v", item.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(back, item.r) {
+			t.Errorf("%s: got %#v, want %#v", item.text, back, item.r)
+		}
+	}
+}
+
+func TestRangeParse(t *testing.T) {
+	list := []struct {
+		text string
+		want rdb.Range
+	}{
+		{` [ 1 , 3 ) `, rdb.Range{Elem: rdb.TypeInt64, Lower: int64(1), Upper: int64(3), LowerInclusive: true}},
+		{`EMPTY`, rdb.Range{Elem: rdb.TypeInt64, Empty: true}},
+		{`[,]`, rdb.Range{Elem: rdb.TypeInt64, LowerInclusive: true, UpperInclusive: true}},
+		{`["1",2]`, rdb.Range{Elem: rdb.TypeInt64, Lower: int64(1), Upper: int64(2), LowerInclusive: true, UpperInclusive: true}},
+	}
+	for _, item := range list {
+		got := rdb.Range{Elem: rdb.TypeInt64}
+		if err := got.UnmarshalText([]byte(item.text)); err != nil {
+			t.Errorf("%s: %v", item.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, item.want) {
+			t.Errorf("%s: got %#v, want %#v", item.text, got, item.want)
+		}
+	}
+
+	for _, bad := range []string{``, `[1`, `1,2)`, `[1)`, `[1,2,3)`, `[a,2)`, `["1,2)`} {
+		r := rdb.Range{Elem: rdb.TypeInt64}
+		if err := r.UnmarshalText([]byte(bad)); err == nil {
+			t.Errorf("%s: expected error, got %#v", bad, r)
+		}
+	}
+}
+
+func TestRangeBinary(t *testing.T) {
+	list := []rdb.Range{
+		{Elem: rdb.TypeInt64, Lower: int64(1), Upper: int64(10), LowerInclusive: true},
+		{Elem: rdb.TypeInt64, Upper: int64(10), UpperInclusive: true},
+		{Elem: rdb.TypeInt64, Lower: int64(1)},
+		{Elem: rdb.TypeInt64, Empty: true},
+		{Elem: rdb.TypeFloat64, Lower: 1.5, Upper: 2.5},
+		{Elem: rdb.TypeTimestampz, Lower: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Elem: rdb.TypeDecimal, Lower: rdb.NewNumeric(15, 1), Upper: rdb.NewNumeric(25, 1)},
+	}
+	for _, r := range list {
+		data, err := r.MarshalBinary()
+		if err != nil {
+			t.Errorf("%v: %v", r, err)
+			continue
+		}
+		var back rdb.Range
+		if err = back.UnmarshalBinary(data); err != nil {
+			t.Errorf("%v: %v", r, err)
+			continue
+		}
+		if !reflect.DeepEqual(back, r) {
+			t.Errorf("got %#v, want %#v", back, r)
+		}
+		if r.Empty {
+			continue
+		}
+		if err = back.UnmarshalBinary(append(data, 0)); err == nil {
+			t.Errorf("%v: expected error for trailing data", r)
+		}
+	}
+	var r rdb.Range
+	if err := r.UnmarshalBinary([]byte{0, 0}); err == nil {
+		t.Error("short data: expected error")
+	}
+}
+
+func TestRangeValue(t *testing.T) {
+	r := rdb.Range{Elem: rdb.TypeInt32, Lower: int64(1), Upper: int64(2), LowerInclusive: true}
+	if v, err := r.Value(); err != nil || v != `[1,2)` {
+		t.Errorf("value: got %v, %v", v, err)
+	}
+	var back rdb.Range
+	if err := back.Scan(r); err != nil || !reflect.DeepEqual(back, r) {
+		t.Errorf("scan range: got %#v, %v", back, err)
+	}
+	for _, bad := range []interface{}{nil, int64(1)} {
+		if err := back.Scan(bad); err == nil {
+			t.Errorf("scan %v: expected error", bad)
+		}
+	}
+}
@@ -120,6 +120,13 @@ type Transaction interface {
 	Commit(ctx context.Context) error
 }
 
+// Rollbacker is an optional interface for a Transaction. Rollback ends
+// the transaction and returns once it has been rolled back. Transactions
+// without it are rolled back when their context is cancelled.
+type Rollbacker interface {
+	Rollback(ctx context.Context) error
+}
+
 // Statement represents a prepared statement. On most systems this takes out
 // a resource on the server and should be closed by closing the associated context
 // (see Preparer). It is not advised to use a Statement scoped to an application
@@ -160,6 +167,13 @@ type Next interface {
 	Close() error
 }
 
+// RowsAffecter is an optional interface for a Next. RowsAffected returns
+// the number of rows changed by the command and true, or false if the
+// number is not known.
+type RowsAffecter interface {
+	RowsAffected() (int64, bool)
+}
+
 // Result provides a way to iterate over a query result.
 type Result interface {
 	// Prep and Prepx should be called before Scan. If value is a io.Writer
@@ -187,8 +201,8 @@ type Param struct {
 	// Optional parameter name.
 	Name string
 
-	// Parameter Type. Drivers may be able to infer this type.
-	// Check the driver documentation used for more information.
+	// Parameter Type. If TypeUnknown, drivers infer the type from
+	// Value with ResolveParams and the Config TypeDefaults.
 	Type Type
 
 	// Set to true if the parameter is an output parameter.
@@ -201,6 +215,14 @@ type Param struct {
 	// Paremeter Length. Useful for variable length types that may check truncation.
 	Length int
 
+	// Precision and Scale of a decimal parameter. Checked by
+	// ValidateParams if Precision is set.
+	Precision int
+	Scale     int
+
+	// Set to true if the value must not be null. Checked by ValidateParams.
+	NotNull bool
+
 	// Value for input parameter.
 	// If the value is an io.Reader it will read the value directly to the wire.
 	Value interface{}
@@ -226,4 +248,8 @@ type Command struct {
 
 	// Optional name of the command. May be used if logging.
 	Name string
+
+	// If set, parameters are checked with ValidateParams before the
+	// command is sent.
+	Validate bool
 }
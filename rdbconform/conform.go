@@ -0,0 +1,517 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+// Package rdbconform tests a driver against the contracts documented in
+// the rdb package. Driver authors should call RunConformance from a test:
+//
+//	func TestConformance(t *testing.T) {
+//		conf, err := rdb.ParseConfigURL("mydriver://localhost/test")
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		rdbconform.RunConformance(t, &rdbconform.Config{Open: conf})
+//	}
+//
+// The suite creates and drops tables named with the prefix "rdbconform_".
+package rdbconform // import "github.com/kardianos/rdb/rdbconform"
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+	"golang.org/x/net/context"
+)
+
+// Config describes the driver under test.
+type Config struct {
+	// Open is passed to rdb.Open. The driver opener must be registered.
+	Open *rdb.Config
+
+	// Param returns the SQL text for the parameter at the zero based index.
+	// Defaults to "@" + name.
+	Param func(index int, name string) string
+
+	// Positional is true if parameters are passed without names.
+	Positional bool
+
+	// ColumnType returns the column type used to create a column of the
+	// type, or an empty string if the driver does not support the type.
+	// Defaults to StandardColumnType.
+	ColumnType func(t rdb.Type) string
+
+	// Isolation lists the isolation levels the driver supports. Begin must
+	// return an error for levels not listed. If nil, all levels must be
+	// accepted.
+	Isolation []rdb.Isolation
+
+	// SingleResult is true if the driver cannot return more then one
+	// result from a command.
+	SingleResult bool
+
+	// Wait is the maximum time to wait for a cancelled context to return
+	// a connection to the pool. Defaults to one second.
+	Wait time.Duration
+}
+
+func (c *Config) param(index int, name string) string {
+	if c.Param == nil {
+		return "@" + name
+	}
+	return c.Param(index, name)
+}
+
+func (c *Config) columnType(t rdb.Type) string {
+	if c.ColumnType == nil {
+		return StandardColumnType(t)
+	}
+	return c.ColumnType(t)
+}
+
+func (c *Config) wait() time.Duration {
+	if c.Wait <= 0 {
+		return time.Second
+	}
+	return c.Wait
+}
+
+// newParam returns a parameter, named unless the driver is positional.
+func (c *Config) newParam(name string, t rdb.Type, value interface{}) rdb.Param {
+	p := rdb.Param{Name: name, Type: t, Value: value}
+	if c.Positional {
+		p.Name = ""
+	}
+	return p
+}
+
+// RunConformance runs the conformance tests as sub-tests of t.
+func RunConformance(t *testing.T, config *Config) {
+	if config == nil || config.Open == nil {
+		t.Fatal("rdbconform: missing open config")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pool, err := rdb.Open(ctx, config.Open)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer pool.Close()
+
+	if err = pool.Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	s := &suite{config: config, pool: pool}
+	s.createTable(t, "rdbconform_value",
+		"id "+config.columnType(rdb.TypeInt32),
+		"v "+config.columnType(rdb.TypeVarChar),
+	)
+	defer s.dropTable(t, "rdbconform_value")
+	for i, v := range []string{"one", "two", "three"} {
+		s.exec(t, fmt.Sprintf("insert into rdbconform_value (id, v) values (%s, %s)", config.param(0, "id"), config.param(1, "v")),
+			config.newParam("id", rdb.TypeInt32, int32(i+1)),
+			config.newParam("v", rdb.TypeVarChar, v),
+		)
+	}
+
+	t.Run("PoolStatus", s.testPoolStatus)
+	t.Run("ResultReturnsConnection", s.testResultReturn)
+	t.Run("MultipleResults", s.testMultipleResults)
+	t.Run("NextClose", s.testNextClose)
+	t.Run("ContextCancel", s.testContextCancel)
+	t.Run("PrepWriter", s.testPrepWriter)
+	t.Run("Prepare", s.testPrepare)
+	t.Run("SavePoint", s.testSavePoint)
+	t.Run("Isolation", s.testIsolation)
+	t.Run("Types", s.testTypes)
+}
+
+type suite struct {
+	config *Config
+	pool   rdb.Pool
+}
+
+func (s *suite) exec(t *testing.T, sql string, params ...rdb.Param) rdb.BufferSet {
+	t.Helper()
+	set, err := s.pool.Query(context.Background(), &rdb.Command{SQL: sql}, params...).BufferSet()
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	return set
+}
+
+// buffer returns the first result of the query and closes it.
+func (s *suite) buffer(t *testing.T, q rdb.Queryer, cmd *rdb.Command, params ...rdb.Param) *rdb.Buffer {
+	t.Helper()
+	next := q.Query(context.Background(), cmd, params...)
+	defer next.Close()
+
+	buf, err := next.Buffer()
+	if err != nil {
+		t.Fatalf("%s: %v", cmd.SQL, err)
+	}
+	if buf == nil {
+		t.Fatalf("%s: missing result", cmd.SQL)
+	}
+	return buf
+}
+
+func (s *suite) createTable(t *testing.T, name string, columns ...string) {
+	t.Helper()
+	s.pool.Query(context.Background(), &rdb.Command{SQL: "drop table " + name}).Close()
+	s.exec(t, fmt.Sprintf("create table %s (%s)", name, strings.Join(columns, ", ")))
+}
+
+func (s *suite) dropTable(t *testing.T, name string) {
+	t.Helper()
+	s.exec(t, "drop table "+name)
+}
+
+// selectValue returns a command that selects the row with the id.
+func (s *suite) selectValue(id int) string {
+	return fmt.Sprintf("select id, v from rdbconform_value where id = %d", id)
+}
+
+// available returns the number of available connections once it equals
+// want or the wait duration passes.
+func (s *suite) available(want int) int {
+	end := time.Now().Add(s.config.wait())
+	for {
+		got := s.pool.Status().Available()
+		if got == want || time.Now().After(end) {
+			return got
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// baseline returns the available connections when no query is running.
+func (s *suite) baseline(t *testing.T) int {
+	t.Helper()
+	s.exec(t, s.selectValue(1))
+	return s.pool.Status().Available()
+}
+
+func (s *suite) testPoolStatus(t *testing.T) {
+	status := s.pool.Status()
+	if status == nil {
+		t.Fatal("Status returned nil")
+	}
+	capacity := status.Capacity()
+	if capacity < 0 {
+		t.Fatalf("negative capacity %d", capacity)
+	}
+	before := s.baseline(t)
+	if before < 0 || (capacity > 0 && before > capacity) {
+		t.Fatalf("available %d out of range for capacity %d", before, capacity)
+	}
+	next := s.pool.Query(context.Background(), &rdb.Command{SQL: s.selectValue(1)})
+	if _, err := next.Result(); err != nil {
+		t.Fatal(err)
+	}
+	if during := status.Available(); during >= before {
+		t.Errorf("available %d while query is open, want less then %d", during, before)
+	}
+	if err := next.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if after := s.available(before); after != before {
+		t.Errorf("available %d after close, want %d", after, before)
+	}
+}
+
+func (s *suite) testResultReturn(t *testing.T) {
+	before := s.baseline(t)
+	next := s.pool.Query(context.Background(), &rdb.Command{SQL: s.selectValue(2)})
+	res, err := next.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res == nil {
+		t.Fatal("missing result")
+	}
+	var rows int
+	for {
+		row, err := res.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row == nil {
+			break
+		}
+		rows++
+		if got := textOf(row.Get("v")); got != "two" {
+			t.Errorf("got %q, want %q", got, "two")
+		}
+	}
+	if rows != 1 {
+		t.Errorf("got %d rows, want 1", rows)
+	}
+	res, err = next.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != nil {
+		t.Fatal("unexpected result")
+	}
+	if after := s.available(before); after != before {
+		t.Errorf("available %d after last result, want %d", after, before)
+	}
+
+	if _, err = s.pool.Query(context.Background(), &rdb.Command{SQL: s.selectValue(2)}).BufferSet(); err != nil {
+		t.Fatal(err)
+	}
+	if after := s.available(before); after != before {
+		t.Errorf("available %d after BufferSet, want %d", after, before)
+	}
+}
+
+func (s *suite) testMultipleResults(t *testing.T) {
+	if s.config.SingleResult {
+		t.Skip("driver returns a single result")
+	}
+	before := s.baseline(t)
+	next := s.pool.Query(context.Background(), &rdb.Command{SQL: s.selectValue(1) + "; " + s.selectValue(3)})
+	first, err := next.Buffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if during := s.pool.Status().Available(); during >= before {
+		t.Errorf("connection returned before the second result was read")
+	}
+	second, err := next.Buffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == nil || second == nil || len(first.Row) != 1 || len(second.Row) != 1 {
+		t.Fatalf("got buffers %v and %v, want one row each", first, second)
+	}
+	if got := textOf(second.Row[0].Get("v")); got != "three" {
+		t.Errorf("got %q, want %q", got, "three")
+	}
+	if buf, err := next.Buffer(); buf != nil || err != nil {
+		t.Fatalf("got %v, %v after last result, want nil", buf, err)
+	}
+	if after := s.available(before); after != before {
+		t.Errorf("available %d after last result, want %d", after, before)
+	}
+}
+
+func (s *suite) testNextClose(t *testing.T) {
+	before := s.baseline(t)
+	next := s.pool.Query(context.Background(), &rdb.Command{SQL: s.selectValue(1)})
+	if err := next.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := next.Result(); err == nil {
+		t.Error("Result after Close returned no error")
+	}
+	if _, err := next.Buffer(); err == nil {
+		t.Error("Buffer after Close returned no error")
+	}
+	if after := s.available(before); after != before {
+		t.Errorf("available %d after close, want %d", after, before)
+	}
+}
+
+func (s *suite) testContextCancel(t *testing.T) {
+	before := s.baseline(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.pool.Query(ctx, &rdb.Command{SQL: s.selectValue(1)}).BufferSet(); err == nil {
+		t.Error("query with a cancelled context returned no error")
+	}
+	if after := s.available(before); after != before {
+		t.Errorf("available %d after cancelled query, want %d", after, before)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	next := s.pool.Query(ctx, &rdb.Command{SQL: s.selectValue(1) + "; " + s.selectValue(2)})
+	if _, err := next.Result(); err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	cancel()
+	if after := s.available(before); after != before {
+		t.Errorf("available %d after cancel, want %d", after, before)
+	}
+	if _, err := next.Result(); err == nil {
+		t.Error("Result after cancel returned no error")
+	}
+}
+
+func (s *suite) testPrepWriter(t *testing.T) {
+	res, err := s.pool.Query(context.Background(), &rdb.Command{SQL: s.selectValue(3)}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Close()
+
+	buf := &bytes.Buffer{}
+	var id int64
+	res.Prep("v", buf).Prepx(0, &id)
+	row, err := res.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row == nil {
+		t.Fatal("missing row")
+	}
+	if got := buf.String(); got != "three" {
+		t.Errorf("writer got %q, want %q", got, "three")
+	}
+	if id != 3 {
+		t.Errorf("id got %d, want 3", id)
+	}
+	if v := row.Get("v"); v != nil {
+		t.Errorf("prepared value %v written to row", v)
+	}
+	if v := row.Getx(0); v != nil {
+		t.Errorf("prepared value %v written to row", v)
+	}
+}
+
+func (s *suite) testPrepare(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stmt, err := s.pool.Prepare(ctx, &rdb.Command{SQL: fmt.Sprintf("select v from rdbconform_value where id = %s", s.config.param(0, "id"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[int32]string{1: "one", 2: "two"} {
+		next := stmt.Exec(ctx, s.config.newParam("id", rdb.TypeInt32, id))
+		buf, err := next.Buffer()
+		next.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(buf.Row) != 1 {
+			t.Fatalf("got %d rows, want 1", len(buf.Row))
+		}
+		if got := textOf(buf.Row[0].Getx(0)); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+// ids returns the sorted ids in the table.
+func (s *suite) ids(t *testing.T, q rdb.Queryer) []int64 {
+	t.Helper()
+	buf := s.buffer(t, q, &rdb.Command{SQL: "select id from rdbconform_tx order by id"})
+	list := make([]int64, len(buf.Row))
+	for i, row := range buf.Row {
+		n, ok := integerOf(row.Getx(0))
+		if !ok {
+			t.Fatalf("id %v is not an integer", row.Getx(0))
+		}
+		list[i] = n
+	}
+	return list
+}
+
+func (s *suite) testSavePoint(t *testing.T) {
+	s.createTable(t, "rdbconform_tx", "id "+s.config.columnType(rdb.TypeInt32))
+	defer s.dropTable(t, "rdbconform_tx")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	insert := func(q rdb.Queryer, id int) {
+		t.Helper()
+		_, err := q.Query(ctx, &rdb.Command{SQL: fmt.Sprintf("insert into rdbconform_tx (id) values (%d)", id)}).BufferSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx, err := s.pool.Begin(ctx, rdb.IsoDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(tx, 1)
+	if err = tx.SavePoint(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	insert(tx, 2)
+	if err = tx.SavePoint(ctx, "s2"); err != nil {
+		t.Fatal(err)
+	}
+	insert(tx, 3)
+	if err = tx.RollbackTo(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	insert(tx, 4)
+	if got := fmt.Sprint(s.ids(t, tx)); got != "[1 4]" {
+		t.Errorf("after rollback to savepoint got %s, want [1 4]", got)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(s.ids(t, s.pool)); got != "[1 4]" {
+		t.Errorf("after commit got %s, want [1 4]", got)
+	}
+	if err = tx.Commit(ctx); err == nil {
+		t.Error("second commit returned no error")
+	}
+
+	// A cancelled transaction rolls back.
+	txCtx, txCancel := context.WithCancel(ctx)
+	tx, err = s.pool.Begin(txCtx, rdb.IsoDefault)
+	if err != nil {
+		txCancel()
+		t.Fatal(err)
+	}
+	insert(tx, 5)
+	txCancel()
+	end := time.Now().Add(s.config.wait())
+	for {
+		got := fmt.Sprint(s.ids(t, s.pool))
+		if got == "[1 4]" {
+			break
+		}
+		if time.Now().After(end) {
+			t.Errorf("after cancel got %s, want [1 4]", got)
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (s *suite) testIsolation(t *testing.T) {
+	supported := make(map[rdb.Isolation]bool)
+	for _, iso := range s.config.Isolation {
+		supported[iso] = true
+	}
+	for iso := rdb.IsoDefault; iso <= rdb.IsoLinearizable; iso++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		tx, err := s.pool.Begin(ctx, iso)
+		want := s.config.Isolation == nil || supported[iso]
+		switch {
+		case err != nil && want:
+			t.Errorf("isolation %d: %v", iso, err)
+		case err == nil && !want:
+			t.Errorf("isolation %d: unsupported level returned no error", iso)
+		case err == nil:
+			if _, err = tx.Query(ctx, &rdb.Command{SQL: s.selectValue(1)}).BufferSet(); err != nil {
+				t.Errorf("isolation %d: query: %v", iso, err)
+			}
+			if err = tx.Commit(ctx); err != nil {
+				t.Errorf("isolation %d: commit: %v", iso, err)
+			}
+		}
+		cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := s.pool.Begin(ctx, rdb.IsoLinearizable+1); err == nil {
+		t.Error("unknown isolation level returned no error")
+	}
+}
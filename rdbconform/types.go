@@ -0,0 +1,329 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbconform
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+	"golang.org/x/net/context"
+)
+
+var standardColumnTypes = map[rdb.Type]string{
+	rdb.TypeText:        "varchar(100)",
+	rdb.TypeAnsiText:    "varchar(100)",
+	rdb.TypeVarChar:     "nvarchar(100)",
+	rdb.TypeAnsiVarChar: "varchar(100)",
+	rdb.TypeChar:        "nchar(5)",
+	rdb.TypeAnsiChar:    "char(5)",
+	rdb.TypeBinary:      "varbinary(100)",
+	rdb.TypeBool:        "boolean",
+	rdb.TypeInt16:       "smallint",
+	rdb.TypeInt32:       "integer",
+	rdb.TypeInt64:       "bigint",
+	rdb.TypeFloat32:     "real",
+	rdb.TypeFloat64:     "double precision",
+	rdb.TypeDecimal:     "decimal(10,3)",
+	rdb.TypeTimestampz:  "timestamp with time zone",
+	rdb.TypeDuration:    "interval",
+	rdb.TypeTime:        "time",
+	rdb.TypeDate:        "date",
+	rdb.TypeTimestamp:   "timestamp",
+	rdb.TypeXML:         "xml",
+}
+
+// StandardColumnType returns the ANSI SQL column type for the type, or an
+// empty string if there is no standard column type.
+func StandardColumnType(t rdb.Type) string {
+	return standardColumnTypes[t]
+}
+
+// typeTest is a value that is written and read back for a type.
+type typeTest struct {
+	t     rdb.Type
+	value interface{}
+	equal func(want, got interface{}) bool
+}
+
+var typeTests = []typeTest{
+	{rdb.TypeText, "héllo wörld", equalText},
+	{rdb.TypeAnsiText, "hello world", equalText},
+	{rdb.TypeVarChar, "héllo wörld", equalText},
+	{rdb.TypeAnsiVarChar, "hello world", equalText},
+	{rdb.TypeChar, "héllo", equalText},
+	{rdb.TypeAnsiChar, "hello", equalText},
+	{rdb.TypeBinary, []byte{0, 1, 2, 0xfe, 0xff}, equalBinary},
+	{rdb.TypeBool, true, equalBool},
+	{rdb.TypeUint8, uint8(200), equalInteger},
+	{rdb.TypeUint16, uint16(60000), equalInteger},
+	{rdb.TypeUint32, uint32(4000000000), equalInteger},
+	{rdb.TypeUint64, uint64(1<<63 + 5), equalInteger},
+	{rdb.TypeInt8, int8(-100), equalInteger},
+	{rdb.TypeInt16, int16(-30000), equalInteger},
+	{rdb.TypeInt32, int32(-2000000000), equalInteger},
+	{rdb.TypeInt64, int64(-9000000000000000000), equalInteger},
+	{rdb.TypeSerial16, int16(7), equalInteger},
+	{rdb.TypeSerial32, int32(7), equalInteger},
+	{rdb.TypeSerial64, int64(7), equalInteger},
+	{rdb.TypeFloat32, float32(1.5), equalFloat},
+	{rdb.TypeFloat64, float64(-2.25), equalFloat},
+	{rdb.TypeDecimal, "12345.678", equalDecimal},
+	{rdb.TypeMoney, "12.34", equalDecimal},
+	{rdb.TypeTimestampz, time.Date(2016, 3, 4, 5, 6, 7, 0, time.FixedZone("", 2*60*60)), equalInstant},
+	{rdb.TypeDuration, 90 * time.Second, equalDuration},
+	{rdb.TypeTime, time.Date(0, 1, 1, 13, 14, 15, 0, time.UTC), equalClock},
+	{rdb.TypeDate, time.Date(2016, 3, 4, 0, 0, 0, 0, time.UTC), equalDate},
+	{rdb.TypeTimestamp, time.Date(2016, 3, 4, 5, 6, 7, 0, time.UTC), equalWall},
+	{rdb.TypeUUID, "0f8fad5b-d9cb-469f-a165-70867728950e", equalUUID},
+	{rdb.TypeEnum, "red", equalText},
+	{rdb.TypeRange, "[1,5)", equalText},
+	{rdb.TypeArray, "{1,2,3}", equalText},
+	{rdb.TypeJSON, `{"a":[1,2],"b":"c"}`, equalJSON},
+	{rdb.TypeXML, "<a>1</a>", equalText},
+	{rdb.TypeTable, nil, nil},
+}
+
+func (s *suite) testTypes(t *testing.T) {
+	for _, tt := range typeTests {
+		tt := tt
+		t.Run(fmt.Sprint(tt.t), func(t *testing.T) {
+			if tt.equal == nil {
+				t.Skip("not a column type")
+			}
+			columnType := s.config.columnType(tt.t)
+			if len(columnType) == 0 {
+				t.Skip("driver does not support the type")
+			}
+			s.testType(t, tt, columnType)
+		})
+	}
+}
+
+func (s *suite) testType(t *testing.T, tt typeTest, columnType string) {
+	s.createTable(t, "rdbconform_type", "v "+columnType)
+	defer s.dropTable(t, "rdbconform_type")
+
+	ctx := context.Background()
+	_, err := s.pool.Query(ctx, &rdb.Command{SQL: "insert into rdbconform_type (v) values (" + s.config.param(0, "v") + ")"},
+		s.config.newParam("v", tt.t, tt.value),
+	).BufferSet()
+	if err != nil {
+		t.Fatalf("insert %v: %v", tt.value, err)
+	}
+	buf := s.buffer(t, s.pool, &rdb.Command{SQL: "select v from rdbconform_type"})
+	if len(buf.Row) != 1 || len(buf.Schema) != 1 {
+		t.Fatalf("got %d rows and %d columns, want 1 each", len(buf.Row), len(buf.Schema))
+	}
+	col := buf.Schema[0]
+	if !strings.EqualFold(col.Name, "v") {
+		t.Errorf("column name got %q, want %q", col.Name, "v")
+	}
+	if want := tt.t.GenericOf(); col.Generic != 0 && want != rdb.Other && col.Generic != want {
+		t.Errorf("column generic type got %d, want %d", col.Generic, want)
+	}
+	got := buf.Row[0].Get(col.Name)
+	if !tt.equal(tt.value, got) {
+		t.Errorf("got %#v, want %#v", got, tt.value)
+	}
+
+	switch tt.t {
+	case rdb.TypeSerial16, rdb.TypeSerial32, rdb.TypeSerial64:
+		// Serial columns set a value in place of null.
+		return
+	}
+	_, err = s.pool.Query(ctx, &rdb.Command{SQL: "insert into rdbconform_type (v) values (" + s.config.param(0, "v") + ")"},
+		s.config.newParam("v", tt.t, nil),
+	).BufferSet()
+	if err != nil {
+		t.Fatalf("insert null: %v", err)
+	}
+	buf = s.buffer(t, s.pool, &rdb.Command{SQL: "select v from rdbconform_type where v is null"})
+	if len(buf.Row) != 1 || buf.Row[0].Getx(0) != nil {
+		t.Errorf("null value did not round trip")
+	}
+}
+
+// textOf returns text, bytes, or the text form of a value as a string.
+func textOf(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case encoding.TextMarshaler:
+		if b, err := v.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+func integerOf(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+func equalText(want, got interface{}) bool {
+	switch got.(type) {
+	case string, []byte:
+		return textOf(want) == strings.TrimRight(textOf(got), " ")
+	}
+	return false
+}
+
+func equalBinary(want, got interface{}) bool {
+	b, ok := got.([]byte)
+	return ok && bytes.Equal(want.([]byte), b)
+}
+
+func equalBool(want, got interface{}) bool {
+	switch got := got.(type) {
+	case bool:
+		return got == want.(bool)
+	}
+	n, ok := integerOf(got)
+	return ok && (n == 1) == want.(bool)
+}
+
+// equalInteger compares the integer values regardless of the integer type.
+func equalInteger(want, got interface{}) bool {
+	w, ok := bigInt(want)
+	if !ok {
+		return false
+	}
+	g, ok := bigInt(got)
+	return ok && w.Cmp(g) == 0
+}
+
+func bigInt(v interface{}) (*big.Int, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(rv.Uint()), true
+	}
+	return nil, false
+}
+
+func equalFloat(want, got interface{}) bool {
+	w := reflect.ValueOf(want).Float()
+	switch rv := reflect.ValueOf(got); rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == w
+	}
+	return false
+}
+
+// equalDecimal compares decimal values in any scale, as text or floats.
+func equalDecimal(want, got interface{}) bool {
+	w, _ := new(big.Rat).SetString(want.(string))
+	var g *big.Rat
+	switch rv := reflect.ValueOf(got); rv.Kind() {
+	case reflect.String:
+		g, _ = new(big.Rat).SetString(strings.TrimLeft(rv.String(), "$"))
+	case reflect.Slice:
+		if b, ok := got.([]byte); ok {
+			g, _ = new(big.Rat).SetString(strings.TrimLeft(string(b), "$"))
+		}
+	case reflect.Float32, reflect.Float64:
+		g = new(big.Rat).SetFloat64(rv.Float())
+	default:
+		if s, ok := got.(fmt.Stringer); ok {
+			g, _ = new(big.Rat).SetString(s.String())
+		}
+	}
+	return g != nil && w.Cmp(g) == 0
+}
+
+func equalInstant(want, got interface{}) bool {
+	g, ok := got.(time.Time)
+	return ok && g.Equal(want.(time.Time))
+}
+
+func equalDuration(want, got interface{}) bool {
+	switch g := got.(type) {
+	case time.Duration:
+		return g == want.(time.Duration)
+	case string:
+		d, err := time.ParseDuration(g)
+		return err == nil && d == want.(time.Duration)
+	}
+	return false
+}
+
+func equalClock(want, got interface{}) bool {
+	g, ok := got.(time.Time)
+	if !ok {
+		return false
+	}
+	w := want.(time.Time)
+	return g.Hour() == w.Hour() && g.Minute() == w.Minute() && g.Second() == w.Second() && g.Nanosecond() == w.Nanosecond()
+}
+
+func equalDate(want, got interface{}) bool {
+	g, ok := got.(time.Time)
+	if !ok {
+		return false
+	}
+	wy, wm, wd := want.(time.Time).Date()
+	gy, gm, gd := g.Date()
+	return wy == gy && wm == gm && wd == gd
+}
+
+// equalWall compares the date and clock without regard to the location.
+func equalWall(want, got interface{}) bool {
+	return equalDate(want, got) && equalClock(want, got)
+}
+
+func equalUUID(want, got interface{}) bool {
+	var s string
+	switch g := got.(type) {
+	case string:
+		s = g
+	case []byte:
+		if len(g) == 16 {
+			s = fmt.Sprintf("%x-%x-%x-%x-%x", g[0:4], g[4:6], g[6:8], g[8:10], g[10:16])
+		} else {
+			s = string(g)
+		}
+	case [16]byte:
+		s = fmt.Sprintf("%x-%x-%x-%x-%x", g[0:4], g[4:6], g[6:8], g[8:10], g[10:16])
+	default:
+		s = fmt.Sprint(got)
+	}
+	return strings.EqualFold(strings.Trim(s, "{}"), want.(string))
+}
+
+// equalJSON compares decoded JSON documents.
+func equalJSON(want, got interface{}) bool {
+	var w, g interface{}
+	if err := json.Unmarshal([]byte(textOf(want)), &w); err != nil {
+		return false
+	}
+	switch got.(type) {
+	case string, []byte, rdb.JSON:
+	default:
+		return false
+	}
+	if err := json.Unmarshal([]byte(textOf(got)), &g); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(w, g)
+}
@@ -0,0 +1,586 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbmem
+
+import (
+	"math/big"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kardianos/rdb"
+	"github.com/pkg/errors"
+)
+
+// source is a table referenced in a from clause.
+type source struct {
+	name     string
+	table    *table
+	nullable bool // True if the source is on the right side of a left join.
+}
+
+// scope is the set of sources expressions may reference.
+type scope struct {
+	sources []*source
+	agg     bool // True if aggregate functions may be used.
+}
+
+func (sc *scope) aggregate() *scope {
+	return &scope{sources: sc.sources, agg: true}
+}
+
+// rowCtx is the current row of each source. A nil row is a missing
+// left join row. In an aggregate query group holds every row of the group.
+type rowCtx struct {
+	rows  [][]interface{}
+	group [][][]interface{}
+}
+
+type evalFunc func(rc *rowCtx) (interface{}, error)
+
+var aggregates = map[string]bool{
+	"count": true,
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+}
+
+func hasAggregate(x expr) bool {
+	switch x := x.(type) {
+	case *callExpr:
+		if aggregates[x.name] {
+			return true
+		}
+		for _, a := range x.args {
+			if hasAggregate(a) {
+				return true
+			}
+		}
+	case *unaryExpr:
+		return hasAggregate(x.x)
+	case *binaryExpr:
+		return hasAggregate(x.l) || hasAggregate(x.r)
+	case *isNullExpr:
+		return hasAggregate(x.x)
+	case *inExpr:
+		if hasAggregate(x.x) {
+			return true
+		}
+		for _, a := range x.list {
+			if hasAggregate(a) {
+				return true
+			}
+		}
+	case *betweenExpr:
+		return hasAggregate(x.x) || hasAggregate(x.lo) || hasAggregate(x.hi)
+	case *likeExpr:
+		return hasAggregate(x.x) || hasAggregate(x.pattern)
+	}
+	return false
+}
+
+// truth returns the boolean value of v. NULL is false.
+func truth(v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+	return false, errors.Errorf("expected boolean, found %T", v)
+}
+
+func boolColumn() rdb.Column {
+	return rdb.Column{Type: rdb.TypeBool, Generic: rdb.Bool, Nullable: true}
+}
+
+// compile resolves names in the expression and returns a function
+// to evaluate it along with the column information of the result.
+// inAgg is true when compiling the argument of an aggregate function.
+func (e *exec) compile(x expr, sc *scope, inAgg bool) (evalFunc, rdb.Column, error) {
+	switch x := x.(type) {
+	case *literal:
+		v := x.value
+		return func(rc *rowCtx) (interface{}, error) { return v, nil }, inferColumn(v), nil
+	case *paramRef:
+		v, col, err := e.param(x)
+		if err != nil {
+			return nil, col, err
+		}
+		return func(rc *rowCtx) (interface{}, error) { return v, nil }, col, nil
+	case *columnRef:
+		si, ci := -1, -1
+		for i, src := range sc.sources {
+			if len(x.table) != 0 && !strings.EqualFold(x.table, src.name) {
+				continue
+			}
+			if c := src.table.column(x.name); c >= 0 {
+				if si >= 0 {
+					return nil, rdb.Column{}, newError(x.line, CodeUndefined, "column %q is ambiguous", x.name)
+				}
+				si, ci = i, c
+			}
+		}
+		if si < 0 {
+			name := x.name
+			if len(x.table) != 0 {
+				name = x.table + "." + x.name
+			}
+			return nil, rdb.Column{}, newError(x.line, CodeUndefined, "column %q does not exist", name)
+		}
+		col := sc.sources[si].table.columns[ci]
+		col.Nullable = col.Nullable || sc.sources[si].nullable
+		return func(rc *rowCtx) (interface{}, error) {
+			if rc.rows[si] == nil {
+				return nil, nil
+			}
+			return rc.rows[si][ci], nil
+		}, col, nil
+	case *unaryExpr:
+		f, col, err := e.compile(x.x, sc, inAgg)
+		if err != nil {
+			return nil, col, err
+		}
+		if x.op == "not" {
+			return func(rc *rowCtx) (interface{}, error) {
+				v, err := f(rc)
+				if err != nil || v == nil {
+					return nil, err
+				}
+				b, err := truth(v)
+				return !b, err
+			}, boolColumn(), nil
+		}
+		return func(rc *rowCtx) (interface{}, error) {
+			v, err := f(rc)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			if isInteger(v) {
+				return arith("-", int64(0), v)
+			}
+			switch v := v.(type) {
+			case float64:
+				return -v, nil
+			case float32:
+				return -v, nil
+			}
+			return arith("-", rdb.Numeric{}, v)
+		}, col, nil
+	case *binaryExpr:
+		lf, lc, err := e.compile(x.l, sc, inAgg)
+		if err != nil {
+			return nil, lc, err
+		}
+		rf, rcol, err := e.compile(x.r, sc, inAgg)
+		if err != nil {
+			return nil, rcol, err
+		}
+		line := x.line
+		switch x.op {
+		case "and", "or":
+			isAnd := x.op == "and"
+			return func(rc *rowCtx) (interface{}, error) {
+				l, err := lf(rc)
+				if err != nil {
+					return nil, err
+				}
+				if l != nil {
+					b, err := truth(l)
+					if err != nil {
+						return nil, asError(line, err)
+					}
+					if b != isAnd {
+						return b, nil
+					}
+				}
+				r, err := rf(rc)
+				if err != nil {
+					return nil, err
+				}
+				if r != nil {
+					b, err := truth(r)
+					if err != nil {
+						return nil, asError(line, err)
+					}
+					if b != isAnd || l != nil {
+						return b, nil
+					}
+				}
+				return nil, nil
+			}, boolColumn(), nil
+		case "=", "<>", "<", "<=", ">", ">=":
+			op := x.op
+			return func(rc *rowCtx) (interface{}, error) {
+				l, err := lf(rc)
+				if err != nil {
+					return nil, err
+				}
+				r, err := rf(rc)
+				if err != nil || l == nil || r == nil {
+					return nil, err
+				}
+				c, err := compare(l, r)
+				if err != nil {
+					return nil, newError(line, CodeType, "%v", err)
+				}
+				switch op {
+				case "=":
+					return c == 0, nil
+				case "<>":
+					return c != 0, nil
+				case "<":
+					return c < 0, nil
+				case "<=":
+					return c <= 0, nil
+				case ">":
+					return c > 0, nil
+				}
+				return c >= 0, nil
+			}, boolColumn(), nil
+		}
+		op := x.op
+		col := rdb.Column{Nullable: true}
+		switch {
+		case op == "||":
+			col.Type, col.Length = rdb.TypeText, -1
+		case lc.Generic == rdb.Float || rcol.Generic == rdb.Float:
+			col.Type = rdb.TypeFloat64
+		case lc.Generic == rdb.Decimal || rcol.Generic == rdb.Decimal:
+			col.Type = rdb.TypeDecimal
+		case lc.Generic == rdb.Integer && rcol.Generic == rdb.Integer:
+			col.Type = rdb.TypeInt64
+		}
+		col.Generic = col.Type.GenericOf()
+		return func(rc *rowCtx) (interface{}, error) {
+			l, err := lf(rc)
+			if err != nil {
+				return nil, err
+			}
+			r, err := rf(rc)
+			if err != nil || l == nil || r == nil {
+				return nil, err
+			}
+			v, err := arith(op, l, r)
+			if err != nil {
+				return nil, newError(line, CodeType, "%v", err)
+			}
+			return v, nil
+		}, col, nil
+	case *isNullExpr:
+		f, _, err := e.compile(x.x, sc, inAgg)
+		if err != nil {
+			return nil, rdb.Column{}, err
+		}
+		not := x.not
+		return func(rc *rowCtx) (interface{}, error) {
+			v, err := f(rc)
+			if err != nil {
+				return nil, err
+			}
+			return (v == nil) != not, nil
+		}, boolColumn(), nil
+	case *inExpr:
+		f, _, err := e.compile(x.x, sc, inAgg)
+		if err != nil {
+			return nil, rdb.Column{}, err
+		}
+		list := make([]evalFunc, len(x.list))
+		for i, item := range x.list {
+			if list[i], _, err = e.compile(item, sc, inAgg); err != nil {
+				return nil, rdb.Column{}, err
+			}
+		}
+		not := x.not
+		return func(rc *rowCtx) (interface{}, error) {
+			v, err := f(rc)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			null := false
+			for _, item := range list {
+				iv, err := item(rc)
+				if err != nil {
+					return nil, err
+				}
+				if iv == nil {
+					null = true
+					continue
+				}
+				c, err := compare(v, iv)
+				if err != nil {
+					return nil, err
+				}
+				if c == 0 {
+					return !not, nil
+				}
+			}
+			if null {
+				return nil, nil
+			}
+			return not, nil
+		}, boolColumn(), nil
+	case *betweenExpr:
+		f, _, err := e.compile(x.x, sc, inAgg)
+		if err != nil {
+			return nil, rdb.Column{}, err
+		}
+		lo, _, err := e.compile(x.lo, sc, inAgg)
+		if err != nil {
+			return nil, rdb.Column{}, err
+		}
+		hi, _, err := e.compile(x.hi, sc, inAgg)
+		if err != nil {
+			return nil, rdb.Column{}, err
+		}
+		not := x.not
+		return func(rc *rowCtx) (interface{}, error) {
+			var v [3]interface{}
+			for i, g := range []evalFunc{f, lo, hi} {
+				if v[i], err = g(rc); err != nil || v[i] == nil {
+					return nil, err
+				}
+			}
+			a, err := compare(v[0], v[1])
+			if err != nil {
+				return nil, err
+			}
+			b, err := compare(v[0], v[2])
+			if err != nil {
+				return nil, err
+			}
+			return (a >= 0 && b <= 0) != not, nil
+		}, boolColumn(), nil
+	case *likeExpr:
+		f, _, err := e.compile(x.x, sc, inAgg)
+		if err != nil {
+			return nil, rdb.Column{}, err
+		}
+		pf, _, err := e.compile(x.pattern, sc, inAgg)
+		if err != nil {
+			return nil, rdb.Column{}, err
+		}
+		not := x.not
+		return func(rc *rowCtx) (interface{}, error) {
+			v, err := f(rc)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			p, err := pf(rc)
+			if err != nil || p == nil {
+				return nil, err
+			}
+			return like(toText(v), toText(p)) != not, nil
+		}, boolColumn(), nil
+	case *callExpr:
+		if aggregates[x.name] {
+			return e.compileAggregate(x, sc, inAgg)
+		}
+		return e.compileCall(x, sc, inAgg)
+	}
+	return nil, rdb.Column{}, errors.Errorf("unknown expression %T", x)
+}
+
+func (e *exec) compileCall(x *callExpr, sc *scope, inAgg bool) (evalFunc, rdb.Column, error) {
+	args := make([]evalFunc, len(x.args))
+	cols := make([]rdb.Column, len(x.args))
+	for i, a := range x.args {
+		var err error
+		if args[i], cols[i], err = e.compile(a, sc, inAgg); err != nil {
+			return nil, rdb.Column{}, err
+		}
+	}
+	want := func(n int) error {
+		if len(args) != n || x.star {
+			return newError(x.line, CodeSyntax, "function %s takes %d argument(s)", x.name, n)
+		}
+		return nil
+	}
+	switch x.name {
+	case "lower", "upper":
+		if err := want(1); err != nil {
+			return nil, rdb.Column{}, err
+		}
+		upper := x.name == "upper"
+		col := cols[0]
+		col.Name = ""
+		return func(rc *rowCtx) (interface{}, error) {
+			v, err := args[0](rc)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			if upper {
+				return strings.ToUpper(toText(v)), nil
+			}
+			return strings.ToLower(toText(v)), nil
+		}, col, nil
+	case "length":
+		if err := want(1); err != nil {
+			return nil, rdb.Column{}, err
+		}
+		return func(rc *rowCtx) (interface{}, error) {
+			v, err := args[0](rc)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			if b, is := v.([]byte); is {
+				return int64(len(b)), nil
+			}
+			return int64(utf8.RuneCountInString(toText(v))), nil
+		}, rdb.Column{Type: rdb.TypeInt64, Generic: rdb.Integer, Nullable: true}, nil
+	case "abs":
+		if err := want(1); err != nil {
+			return nil, rdb.Column{}, err
+		}
+		col := cols[0]
+		col.Name = ""
+		return func(rc *rowCtx) (interface{}, error) {
+			v, err := args[0](rc)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			c, err := compare(v, int64(0))
+			if err != nil || c >= 0 {
+				return v, err
+			}
+			return arith("-", int64(0), v)
+		}, col, nil
+	case "coalesce":
+		if len(args) == 0 || x.star {
+			return nil, rdb.Column{}, newError(x.line, CodeSyntax, "function coalesce requires arguments")
+		}
+		// The type is the type of the first argument that is not null.
+		col := cols[0]
+		for _, c := range cols {
+			if c.Type != rdb.TypeUnknown {
+				col = c
+				break
+			}
+		}
+		col.Name = ""
+		for _, c := range cols {
+			col.Nullable = col.Nullable && c.Nullable
+		}
+		return func(rc *rowCtx) (interface{}, error) {
+			for _, a := range args {
+				v, err := a(rc)
+				if err != nil || v != nil {
+					return v, err
+				}
+			}
+			return nil, nil
+		}, col, nil
+	}
+	return nil, rdb.Column{}, newError(x.line, CodeUndefined, "function %q does not exist", x.name)
+}
+
+func (e *exec) compileAggregate(x *callExpr, sc *scope, inAgg bool) (evalFunc, rdb.Column, error) {
+	if !sc.agg || inAgg {
+		return nil, rdb.Column{}, newError(x.line, CodeSyntax, "aggregate %s not allowed here", x.name)
+	}
+	plain := &scope{sources: sc.sources}
+	if x.star {
+		if x.name != "count" {
+			return nil, rdb.Column{}, newError(x.line, CodeSyntax, "%s(*) is not valid", x.name)
+		}
+		return func(rc *rowCtx) (interface{}, error) {
+			return int64(len(rc.group)), nil
+		}, rdb.Column{Type: rdb.TypeInt64, Generic: rdb.Integer}, nil
+	}
+	if len(x.args) != 1 {
+		return nil, rdb.Column{}, newError(x.line, CodeSyntax, "aggregate %s takes one argument", x.name)
+	}
+	arg, argCol, err := e.compile(x.args[0], plain, true)
+	if err != nil {
+		return nil, rdb.Column{}, err
+	}
+	// values returns the non-null argument values of the group.
+	values := func(rc *rowCtx) ([]interface{}, error) {
+		list := make([]interface{}, 0, len(rc.group))
+		for _, rows := range rc.group {
+			v, err := arg(&rowCtx{rows: rows})
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				list = append(list, v)
+			}
+		}
+		return list, nil
+	}
+	line := x.line
+	switch x.name {
+	case "count":
+		return func(rc *rowCtx) (interface{}, error) {
+			list, err := values(rc)
+			return int64(len(list)), err
+		}, rdb.Column{Type: rdb.TypeInt64, Generic: rdb.Integer}, nil
+	case "min", "max":
+		isMax := x.name == "max"
+		col := argCol
+		col.Name = ""
+		col.Nullable = true
+		return func(rc *rowCtx) (interface{}, error) {
+			list, err := values(rc)
+			if err != nil {
+				return nil, err
+			}
+			var best interface{}
+			for _, v := range list {
+				if best == nil {
+					best = v
+					continue
+				}
+				c, err := compare(v, best)
+				if err != nil {
+					return nil, newError(line, CodeType, "%v", err)
+				}
+				if (c > 0) == isMax && c != 0 {
+					best = v
+				}
+			}
+			return best, nil
+		}, col, nil
+	}
+	isAvg := x.name == "avg"
+	col := rdb.Column{Nullable: true}
+	switch argCol.Generic {
+	case rdb.Integer:
+		col.Type = rdb.TypeInt64
+		if isAvg {
+			col.Type = rdb.TypeFloat64
+		}
+	case rdb.Decimal:
+		col.Type = rdb.TypeDecimal
+	default:
+		col.Type = rdb.TypeFloat64
+	}
+	col.Generic = col.Type.GenericOf()
+	return func(rc *rowCtx) (interface{}, error) {
+		list, err := values(rc)
+		if err != nil || len(list) == 0 {
+			return nil, err
+		}
+		var sum interface{} = int64(0)
+		for _, v := range list {
+			if !isNumber(v) {
+				return nil, newError(line, CodeType, "%s requires numbers, found %T", x.name, v)
+			}
+			if sum, err = arith("+", sum, v); err != nil {
+				return nil, newError(line, CodeType, "%v", err)
+			}
+		}
+		if !isAvg {
+			return sum, nil
+		}
+		if d, is := sum.(rdb.Numeric); is {
+			r := d.Rat()
+			r.Quo(r, new(big.Rat).SetInt64(int64(len(list))))
+			return ratNumeric(r, -1), nil
+		}
+		f, _ := toFloat(sum)
+		return f / float64(len(list)), nil
+	}, col, nil
+}
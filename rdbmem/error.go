@@ -0,0 +1,59 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbmem
+
+import "fmt"
+
+// Error codes reported by Error.ErrorCode.
+const (
+	CodeSyntax     = 1 // The command could not be parsed.
+	CodeUndefined  = 2 // A table, column, parameter, or function does not exist.
+	CodeDuplicate  = 3 // A table or column is defined more then once.
+	CodeConstraint = 4 // A value violates a column type, length, null, or key constraint.
+	CodeType       = 5 // A value has the wrong type for an operation.
+)
+
+// Error is returned for errors in a command. It implements rdb.SQLError.
+type Error struct {
+	Line    int // One based line number in the command.
+	Code    int
+	Message string
+}
+
+func newError(line, code int, format string, v ...interface{}) *Error {
+	return &Error{
+		Line:    line,
+		Code:    code,
+		Message: fmt.Sprintf(format, v...),
+	}
+}
+
+// asError sets the line number of err if it is not already known.
+func asError(line int, err error) error {
+	switch e := err.(type) {
+	case *Error:
+		if e.Line == 0 {
+			c := *e
+			c.Line = line
+			return &c
+		}
+		return e
+	}
+	return &Error{Line: line, Code: CodeType, Message: err.Error()}
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", err.Line, err.Message)
+}
+
+// LineNumber of the command the error occurred on.
+func (err *Error) LineNumber() int {
+	return err.Line
+}
+
+// ErrorCode of the error, one of the Code constants.
+func (err *Error) ErrorCode() int {
+	return err.Code
+}
@@ -0,0 +1,797 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbmem
+
+import (
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kardianos/rdb"
+	"golang.org/x/net/context"
+)
+
+// database holds the tables. The lock must be held to read or write tables.
+type database struct {
+	name   string
+	refs   int
+	lock   chan struct{}
+	tables map[string]*table
+}
+
+type table struct {
+	name    string
+	columns []rdb.Column
+	rows    [][]interface{}
+	serial  int64
+}
+
+func newDatabase(name string) *database {
+	return &database{
+		name:   name,
+		lock:   make(chan struct{}, 1),
+		tables: make(map[string]*table),
+	}
+}
+
+// acquire the database lock or return when ctx is done.
+func (db *database) acquire(ctx context.Context) error {
+	select {
+	case db.lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+func (db *database) release() {
+	<-db.lock
+}
+
+// snapshot returns a copy of the tables that is not changed by later
+// statements.
+func (db *database) snapshot() map[string]*table {
+	return copyTables(db.tables)
+}
+
+// copyTables copies the table list. Stored rows are never modified in place
+// and the row slice capacity is limited so appends do not share storage.
+func copyTables(tables map[string]*table) map[string]*table {
+	c := make(map[string]*table, len(tables))
+	for name, t := range tables {
+		tc := *t
+		tc.rows = t.rows[:len(t.rows):len(t.rows)]
+		c[name] = &tc
+	}
+	return c
+}
+
+func (db *database) table(line int, name string) (*table, error) {
+	t, found := db.tables[strings.ToLower(name)]
+	if !found {
+		return nil, newError(line, CodeUndefined, "table %q does not exist", name)
+	}
+	return t, nil
+}
+
+func (t *table) column(name string) int {
+	for i, col := range t.columns {
+		if strings.EqualFold(col.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// resultSet is a materialized query result.
+type resultSet struct {
+	schema rdb.Schema
+	rows   [][]interface{}
+}
+
+// exec holds the state of a single command execution.
+type exec struct {
+	db       *database
+	params   []rdb.Param
+	trunc    bool
+	affected int64 // Rows inserted, updated and deleted.
+}
+
+// run executes each statement and collects the result sets. Results
+// of statements before an error are returned along with the error.
+// The database lock must be held.
+func (e *exec) run(list []statement) ([]*resultSet, error) {
+	var sets []*resultSet
+	for _, st := range list {
+		set, err := e.statement(st)
+		if err != nil {
+			return sets, asError(st.stmtLine(), err)
+		}
+		if set != nil {
+			sets = append(sets, set)
+		}
+	}
+	return sets, nil
+}
+
+func (e *exec) statement(st statement) (*resultSet, error) {
+	switch st := st.(type) {
+	case *createTable:
+		key := strings.ToLower(st.name)
+		if _, found := e.db.tables[key]; found {
+			if st.ifNotExists {
+				return nil, nil
+			}
+			return nil, newError(st.line, CodeDuplicate, "table %q already exists", st.name)
+		}
+		for i, col := range st.columns {
+			for _, other := range st.columns[:i] {
+				if strings.EqualFold(col.Name, other.Name) {
+					return nil, newError(st.line, CodeDuplicate, "column %q defined more then once", col.Name)
+				}
+			}
+		}
+		e.db.tables[key] = &table{name: st.name, columns: st.columns}
+		return nil, nil
+	case *dropTable:
+		key := strings.ToLower(st.name)
+		if _, found := e.db.tables[key]; !found {
+			if st.ifExists {
+				return nil, nil
+			}
+			return nil, newError(st.line, CodeUndefined, "table %q does not exist", st.name)
+		}
+		delete(e.db.tables, key)
+		return nil, nil
+	case *insertStmt:
+		return nil, e.insert(st)
+	case *updateStmt:
+		return nil, e.update(st)
+	case *deleteStmt:
+		return nil, e.delete(st)
+	case *selectStmt:
+		return e.selectSet(st)
+	}
+	return nil, newError(st.stmtLine(), CodeSyntax, "unknown statement")
+}
+
+func (e *exec) insert(st *insertStmt) error {
+	t, err := e.db.table(st.line, st.table)
+	if err != nil {
+		return err
+	}
+	index := make([]int, len(t.columns))
+	if len(st.columns) == 0 {
+		for i := range index {
+			index[i] = i
+		}
+	} else {
+		index = index[:0]
+		for _, name := range st.columns {
+			i := t.column(name)
+			if i < 0 {
+				return newError(st.line, CodeUndefined, "column %q does not exist in table %q", name, t.name)
+			}
+			index = append(index, i)
+		}
+	}
+	var values [][]interface{}
+	if st.query != nil {
+		set, err := e.selectSet(st.query)
+		if err != nil {
+			return err
+		}
+		values = set.rows
+	} else {
+		sc := &scope{}
+		for _, list := range st.values {
+			row := make([]interface{}, len(list))
+			for i, x := range list {
+				f, _, err := e.compile(x, sc, false)
+				if err != nil {
+					return err
+				}
+				if row[i], err = f(&rowCtx{}); err != nil {
+					return err
+				}
+			}
+			values = append(values, row)
+		}
+	}
+	rows := make([][]interface{}, 0, len(values))
+	serial := t.serial
+	for _, v := range values {
+		if len(v) != len(index) {
+			return newError(st.line, CodeSyntax, "insert has %d values for %d columns", len(v), len(index))
+		}
+		row := make([]interface{}, len(t.columns))
+		set := make([]bool, len(t.columns))
+		for i, ci := range index {
+			row[ci] = v[i]
+			set[ci] = true
+		}
+		for ci, col := range t.columns {
+			if col.Serial && (!set[ci] || row[ci] == nil) {
+				serial++
+				row[ci] = serial
+			}
+			if row[ci], err = coerce(col, row[ci], e.trunc); err != nil {
+				return newError(st.line, CodeConstraint, "%v", err)
+			}
+		}
+		rows = append(rows, row)
+	}
+	all := append(t.rows[:len(t.rows):len(t.rows)], rows...)
+	if err = checkKeys(t, all); err != nil {
+		return asError(st.line, err)
+	}
+	t.rows = all
+	t.serial = serial
+	e.affected += int64(len(rows))
+	return nil
+}
+
+func (e *exec) update(st *updateStmt) error {
+	t, err := e.db.table(st.line, st.table)
+	if err != nil {
+		return err
+	}
+	sc := &scope{sources: []*source{{name: t.name, table: t}}}
+	where, err := e.where(st.where, sc)
+	if err != nil {
+		return err
+	}
+	type assign struct {
+		index int
+		value evalFunc
+	}
+	list := make([]assign, len(st.set))
+	for i, s := range st.set {
+		ci := t.column(s.column)
+		if ci < 0 {
+			return newError(st.line, CodeUndefined, "column %q does not exist in table %q", s.column, t.name)
+		}
+		f, _, err := e.compile(s.value, sc, false)
+		if err != nil {
+			return err
+		}
+		list[i] = assign{index: ci, value: f}
+	}
+	rows := make([][]interface{}, len(t.rows))
+	var n int64
+	for ri, row := range t.rows {
+		rows[ri] = row
+		rc := &rowCtx{rows: [][]interface{}{row}}
+		ok, err := where(rc)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		updated := append([]interface{}(nil), row...)
+		for _, a := range list {
+			v, err := a.value(rc)
+			if err != nil {
+				return err
+			}
+			if updated[a.index], err = coerce(t.columns[a.index], v, e.trunc); err != nil {
+				return newError(st.line, CodeConstraint, "%v", err)
+			}
+		}
+		rows[ri] = updated
+		n++
+	}
+	if err = checkKeys(t, rows); err != nil {
+		return asError(st.line, err)
+	}
+	t.rows = rows
+	e.affected += n
+	return nil
+}
+
+func (e *exec) delete(st *deleteStmt) error {
+	t, err := e.db.table(st.line, st.table)
+	if err != nil {
+		return err
+	}
+	sc := &scope{sources: []*source{{name: t.name, table: t}}}
+	where, err := e.where(st.where, sc)
+	if err != nil {
+		return err
+	}
+	rows := make([][]interface{}, 0, len(t.rows))
+	for _, row := range t.rows {
+		ok, err := where(&rowCtx{rows: [][]interface{}{row}})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			rows = append(rows, row)
+		}
+	}
+	e.affected += int64(len(t.rows) - len(rows))
+	t.rows = rows
+	return nil
+}
+
+// checkKeys returns an error if two rows have the same primary key.
+func checkKeys(t *table, rows [][]interface{}) error {
+	var key []int
+	for i, col := range t.columns {
+		if col.Key {
+			key = append(key, i)
+		}
+	}
+	if len(key) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		parts := make([]string, len(key))
+		for i, ci := range key {
+			parts[i] = toText(row[ci])
+		}
+		k := strings.Join(parts, "\x00")
+		if seen[k] {
+			return newError(0, CodeConstraint, "duplicate primary key (%s) in table %q", strings.Join(parts, ", "), t.name)
+		}
+		seen[k] = true
+	}
+	return nil
+}
+
+// where compiles an optional filter.
+func (e *exec) where(x expr, sc *scope) (func(rc *rowCtx) (bool, error), error) {
+	if x == nil {
+		return func(rc *rowCtx) (bool, error) { return true, nil }, nil
+	}
+	f, _, err := e.compile(x, sc, false)
+	if err != nil {
+		return nil, err
+	}
+	return func(rc *rowCtx) (bool, error) {
+		v, err := f(rc)
+		if err != nil {
+			return false, err
+		}
+		return truth(v)
+	}, nil
+}
+
+type outColumn struct {
+	col  rdb.Column
+	eval evalFunc
+}
+
+func (e *exec) selectSet(st *selectStmt) (*resultSet, error) {
+	sc := &scope{}
+	for _, item := range st.from {
+		t, err := e.db.table(st.line, item.table)
+		if err != nil {
+			return nil, err
+		}
+		name := item.alias
+		if len(name) == 0 {
+			name = t.name
+		}
+		for _, src := range sc.sources {
+			if strings.EqualFold(src.name, name) {
+				return nil, newError(st.line, CodeDuplicate, "table name %q specified more then once", name)
+			}
+		}
+		sc.sources = append(sc.sources, &source{name: name, table: t, nullable: item.join == joinLeft})
+	}
+
+	// Build the joined source rows.
+	var joined [][][]interface{}
+	if len(st.from) == 0 {
+		joined = [][][]interface{}{nil}
+	} else {
+		for _, row := range sc.sources[0].table.rows {
+			joined = append(joined, [][]interface{}{row})
+		}
+	}
+	for i := 1; i < len(st.from); i++ {
+		item := st.from[i]
+		right := sc.sources[i].table
+		// Only sources up to and including this one may be referenced.
+		on, err := e.where(item.on, &scope{sources: sc.sources[:i+1]})
+		if err != nil {
+			return nil, err
+		}
+		var next [][][]interface{}
+		for _, left := range joined {
+			matched := false
+			for _, row := range right.rows {
+				combined := append(left[:len(left):len(left)], row)
+				ok, err := on(&rowCtx{rows: combined})
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					matched = true
+					next = append(next, combined)
+				}
+			}
+			if !matched && item.join == joinLeft {
+				next = append(next, append(left[:len(left):len(left)], nil))
+			}
+		}
+		joined = next
+	}
+
+	where, err := e.where(st.where, sc)
+	if err != nil {
+		return nil, err
+	}
+	filtered := joined[:0:0]
+	for _, rows := range joined {
+		ok, err := where(&rowCtx{rows: rows})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			filtered = append(filtered, rows)
+		}
+	}
+
+	// Grouping.
+	agg := len(st.groupBy) != 0 || st.having != nil
+	for _, item := range st.items {
+		if item.expr != nil && hasAggregate(item.expr) {
+			agg = true
+		}
+	}
+	for _, item := range st.orderBy {
+		if hasAggregate(item.expr) {
+			agg = true
+		}
+	}
+	ctxs := make([]*rowCtx, 0, len(filtered))
+	if agg {
+		groups, err := e.group(st.groupBy, sc, filtered)
+		if err != nil {
+			return nil, err
+		}
+		having, err := e.where(st.having, sc.aggregate())
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range groups {
+			rc := &rowCtx{group: g}
+			if len(g) != 0 {
+				rc.rows = g[0]
+			} else {
+				rc.rows = make([][]interface{}, len(sc.sources))
+			}
+			ok, err := having(rc)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				ctxs = append(ctxs, rc)
+			}
+		}
+	} else {
+		for _, rows := range filtered {
+			ctxs = append(ctxs, &rowCtx{rows: rows})
+		}
+	}
+	evalScope := sc
+	if agg {
+		evalScope = sc.aggregate()
+	}
+
+	// Output columns.
+	var out []outColumn
+	for _, item := range st.items {
+		if item.star {
+			found := false
+			for si, src := range sc.sources {
+				if len(item.starTable) != 0 && !strings.EqualFold(item.starTable, src.name) {
+					continue
+				}
+				found = true
+				for ci, col := range src.table.columns {
+					if agg {
+						return nil, newError(st.line, CodeSyntax, "cannot select * with aggregates")
+					}
+					col.Nullable = col.Nullable || src.nullable
+					si, ci := si, ci
+					out = append(out, outColumn{col: col, eval: func(rc *rowCtx) (interface{}, error) {
+						if rc.rows[si] == nil {
+							return nil, nil
+						}
+						return rc.rows[si][ci], nil
+					}})
+				}
+			}
+			if !found {
+				return nil, newError(st.line, CodeUndefined, "table %q not found in from clause", item.starTable)
+			}
+			continue
+		}
+		f, col, err := e.compile(item.expr, evalScope, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(item.alias) != 0 {
+			col.Name = item.alias
+		}
+		out = append(out, outColumn{col: col, eval: f})
+	}
+	schema := make(rdb.Schema, len(out))
+	for i := range out {
+		schema[i] = out[i].col
+		schema[i].Index = i
+	}
+
+	// Order keys may name output columns or ordinals.
+	order := make([]evalFunc, len(st.orderBy))
+	for i, item := range st.orderBy {
+		switch x := item.expr.(type) {
+		case *literal:
+			if n, ok := x.value.(int64); ok {
+				if n < 1 || int(n) > len(out) {
+					return nil, newError(st.line, CodeSyntax, "order by position %d is not in select list", n)
+				}
+				order[i] = out[n-1].eval
+				continue
+			}
+		case *columnRef:
+			if len(x.table) == 0 {
+				for oi, item := range st.items {
+					if strings.EqualFold(item.alias, x.name) {
+						order[i] = out[oi].eval
+					}
+				}
+				if order[i] != nil {
+					continue
+				}
+			}
+		}
+		f, _, err := e.compile(item.expr, evalScope, false)
+		if err != nil {
+			return nil, err
+		}
+		order[i] = f
+	}
+
+	type outRow struct {
+		values []interface{}
+		keys   []interface{}
+	}
+	result := make([]outRow, 0, len(ctxs))
+	for _, rc := range ctxs {
+		r := outRow{values: make([]interface{}, len(out)), keys: make([]interface{}, len(order))}
+		for i := range out {
+			if r.values[i], err = out[i].eval(rc); err != nil {
+				return nil, err
+			}
+		}
+		for i := range order {
+			if r.keys[i], err = order[i](rc); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, r)
+	}
+	if len(order) != 0 {
+		var sortErr error
+		sort.SliceStable(result, func(i, j int) bool {
+			for k, item := range st.orderBy {
+				c, err := compareNull(result[i].keys[k], result[j].keys[k])
+				if err != nil {
+					sortErr = err
+					return false
+				}
+				if c == 0 {
+					continue
+				}
+				if item.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+		if sortErr != nil {
+			return nil, sortErr
+		}
+	}
+	set := &resultSet{schema: schema}
+	for _, r := range result {
+		if st.distinct && containsRow(set.rows, r.values) {
+			continue
+		}
+		set.rows = append(set.rows, r.values)
+	}
+	offset, err := e.count(st.offset)
+	if err != nil {
+		return nil, err
+	}
+	if offset > len(set.rows) {
+		offset = len(set.rows)
+	}
+	set.rows = set.rows[offset:]
+	if st.limit != nil {
+		limit, err := e.count(st.limit)
+		if err != nil {
+			return nil, err
+		}
+		if limit < len(set.rows) {
+			set.rows = set.rows[:limit]
+		}
+	}
+	return set, nil
+}
+
+// count evaluates a LIMIT or OFFSET expression.
+func (e *exec) count(x expr) (int, error) {
+	if x == nil {
+		return 0, nil
+	}
+	f, _, err := e.compile(x, &scope{}, false)
+	if err != nil {
+		return 0, err
+	}
+	v, err := f(&rowCtx{})
+	if err != nil {
+		return 0, err
+	}
+	n, ok := toBigInt(v)
+	if !ok || n.Sign() < 0 || !n.IsInt64() {
+		return 0, newError(0, CodeType, "limit and offset must be non-negative integers")
+	}
+	return int(n.Int64()), nil
+}
+
+// group splits the rows into groups with equal group by values.
+// Without group by expressions all rows form a single group.
+func (e *exec) group(by []expr, sc *scope, rows [][][]interface{}) ([][][][]interface{}, error) {
+	if len(by) == 0 {
+		return [][][][]interface{}{rows}, nil
+	}
+	keys := make([]evalFunc, len(by))
+	for i, x := range by {
+		f, _, err := e.compile(x, sc, false)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = f
+	}
+	var groups [][][][]interface{}
+	index := make(map[string]int)
+	for _, r := range rows {
+		parts := make([]string, len(keys))
+		for i, f := range keys {
+			v, err := f(&rowCtx{rows: r})
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				parts[i] = "\x01"
+			} else {
+				parts[i] = "\x02" + toText(v)
+			}
+		}
+		k := strings.Join(parts, "\x00")
+		gi, found := index[k]
+		if !found {
+			gi = len(groups)
+			index[k] = gi
+			groups = append(groups, nil)
+		}
+		groups[gi] = append(groups[gi], r)
+	}
+	return groups, nil
+}
+
+func containsRow(rows [][]interface{}, row []interface{}) bool {
+	for _, r := range rows {
+		equal := true
+		for i := range r {
+			c, err := compareNull(r[i], row[i])
+			if err != nil || c != 0 {
+				equal = false
+				break
+			}
+		}
+		if equal {
+			return true
+		}
+	}
+	return false
+}
+
+// compareNull orders NULL before all other values.
+func compareNull(a, b interface{}) (int, error) {
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		return -1, nil
+	case b == nil:
+		return 1, nil
+	}
+	return compare(a, b)
+}
+
+// paramValue returns the value and column for a parameter reference.
+func (e *exec) param(p *paramRef) (interface{}, rdb.Column, error) {
+	var found *rdb.Param
+	if len(p.name) != 0 {
+		for i := range e.params {
+			if strings.EqualFold(strings.TrimLeft(e.params[i].Name, "@:$"), p.name) {
+				found = &e.params[i]
+				break
+			}
+		}
+		if found == nil {
+			return nil, rdb.Column{}, newError(p.line, CodeUndefined, "parameter %q not provided", p.name)
+		}
+	} else {
+		if p.index >= len(e.params) {
+			return nil, rdb.Column{}, newError(p.line, CodeUndefined, "parameter %d not provided", p.index+1)
+		}
+		found = &e.params[p.index]
+	}
+	if found.Out {
+		return nil, rdb.Column{}, newError(p.line, CodeType, "output parameters are not supported")
+	}
+	v := found.Value
+	if r, is := v.(io.Reader); is {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, rdb.Column{}, err
+		}
+		v = b
+		if found.Type.GenericOf() == rdb.Text {
+			v = string(b)
+		}
+		found.Value = v
+	}
+	v, err := valuerValue(v)
+	if err != nil {
+		return nil, rdb.Column{}, err
+	}
+	if found.Type == rdb.TypeUnknown || found.Type.Generic() || found.Type.Driver() {
+		col := inferColumn(v)
+		if found.Type != rdb.TypeUnknown {
+			col.Type = found.Type
+			col.Generic = found.Type.GenericOf()
+		}
+		return v, col, nil
+	}
+	col := rdb.Column{
+		Type:     found.Type,
+		Generic:  found.Type.GenericOf(),
+		Length:   found.Length,
+		Nullable: true,
+	}
+	if col.Length == 0 && (col.Generic == rdb.Text || col.Generic == rdb.Binary) {
+		col.Length = -1
+	}
+	if col.Generic == rdb.Decimal {
+		col.Scale = 18
+	}
+	cv, err := coerce(col, v, e.trunc)
+	if err != nil {
+		return nil, col, newError(p.line, CodeType, "parameter %s: %v", paramLabel(p), err)
+	}
+	if d, is := cv.(rdb.Numeric); is {
+		cv = ratNumeric(d.Rat(), -1)
+	}
+	return cv, col, nil
+}
+
+func paramLabel(p *paramRef) string {
+	if len(p.name) != 0 {
+		return p.name
+	}
+	return strconv.Itoa(p.index + 1)
+}
@@ -0,0 +1,165 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbmem
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind byte
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokQuotedIdent
+	tokNumber
+	tokString
+	tokParam
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// is returns true if the token is the keyword or symbol.
+func (t token) is(s string) bool {
+	switch t.kind {
+	case tokIdent:
+		return strings.EqualFold(t.text, s)
+	case tokSymbol:
+		return t.text == s
+	}
+	return false
+}
+
+// lex splits the SQL text into tokens.
+func lex(text string) ([]token, error) {
+	var list []token
+	line := 1
+	rs := []rune(text)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '-':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			start := line
+			i += 2
+			for ; i < len(rs); i++ {
+				if rs[i] == '\n' {
+					line++
+				}
+				if rs[i] == '*' && i+1 < len(rs) && rs[i+1] == '/' {
+					break
+				}
+			}
+			if i >= len(rs) {
+				return nil, newError(start, CodeSyntax, "unterminated comment")
+			}
+			i += 2
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(rs) && (rs[i] == '_' || unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i])) {
+				i++
+			}
+			list = append(list, token{kind: tokIdent, text: string(rs[start:i]), line: line})
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			start := i
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.') {
+				i++
+			}
+			if i < len(rs) && (rs[i] == 'e' || rs[i] == 'E') {
+				i++
+				if i < len(rs) && (rs[i] == '+' || rs[i] == '-') {
+					i++
+				}
+				for i < len(rs) && unicode.IsDigit(rs[i]) {
+					i++
+				}
+			}
+			list = append(list, token{kind: tokNumber, text: string(rs[start:i]), line: line})
+		case r == '\'':
+			start := line
+			buf := []rune{}
+			i++
+			for {
+				if i >= len(rs) {
+					return nil, newError(start, CodeSyntax, "unterminated string")
+				}
+				if rs[i] == '\'' {
+					if i+1 < len(rs) && rs[i+1] == '\'' {
+						buf = append(buf, '\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				if rs[i] == '\n' {
+					line++
+				}
+				buf = append(buf, rs[i])
+				i++
+			}
+			list = append(list, token{kind: tokString, text: string(buf), line: start})
+		case r == '"' || r == '[' || r == '`':
+			end := r
+			if r == '[' {
+				end = ']'
+			}
+			start := i + 1
+			i++
+			for i < len(rs) && rs[i] != end {
+				i++
+			}
+			if i >= len(rs) {
+				return nil, newError(line, CodeSyntax, "unterminated quoted identifier")
+			}
+			list = append(list, token{kind: tokQuotedIdent, text: string(rs[start:i]), line: line})
+			i++
+		case r == '@' || r == ':' || r == '$':
+			start := i
+			i++
+			for i < len(rs) && (rs[i] == '_' || unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i])) {
+				i++
+			}
+			if i == start+1 {
+				return nil, newError(line, CodeSyntax, "missing parameter name after %q", string(r))
+			}
+			list = append(list, token{kind: tokParam, text: string(rs[start:i]), line: line})
+		case r == '?':
+			list = append(list, token{kind: tokParam, text: "?", line: line})
+			i++
+		default:
+			if i+1 < len(rs) {
+				two := string(rs[i : i+2])
+				switch two {
+				case "<=", ">=", "<>", "!=", "||":
+					list = append(list, token{kind: tokSymbol, text: two, line: line})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("(),;.*+-/%=<>", r) {
+				return nil, newError(line, CodeSyntax, "unexpected character %q", string(r))
+			}
+			list = append(list, token{kind: tokSymbol, text: string(r), line: line})
+			i++
+		}
+	}
+	list = append(list, token{kind: tokEOF, line: line})
+	return list, nil
+}
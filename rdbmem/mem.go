@@ -0,0 +1,455 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+// Package rdbmem is an in-memory database driver. It needs no external
+// server and may be used to test code written against rdb.Pool. It also
+// serves as a reference implementation of the rdb interfaces.
+//
+// Databases are named by the host of the configuration URL. Pools opened
+// with the same name share tables until the last pool is closed. An empty
+// name always opens a new private database.
+//
+//	import _ "github.com/kardianos/rdb/rdbmem"
+//
+//	conf, err := rdb.ParseConfigURL("mem://test?max_cap=4")
+//	pool, err := rdb.Open(ctx, conf)
+//
+// The supported SQL is a practical subset:
+//
+//	CREATE TABLE [IF NOT EXISTS] t (col type [NOT NULL] [PRIMARY KEY], ..., [PRIMARY KEY (col, ...)])
+//	DROP TABLE [IF EXISTS] t
+//	INSERT INTO t [(col, ...)] VALUES (expr, ...), ... | SELECT ...
+//	UPDATE t SET col = expr, ... [WHERE expr]
+//	DELETE FROM t [WHERE expr]
+//	SELECT [DISTINCT] expr [AS name], ... [FROM t [AS a] [[INNER | LEFT] JOIN u ON expr] ...]
+//	   [WHERE expr] [GROUP BY expr, ...] [HAVING expr] [ORDER BY expr [ASC | DESC], ...]
+//	   [LIMIT n] [OFFSET n]
+//
+// Multiple statements are separated by ";" and each SELECT returns a result.
+// Parameters are named (@name, :name) or positional (?, $1).
+// Expressions support comparisons, AND, OR, NOT, IS [NOT] NULL, [NOT] IN,
+// BETWEEN, LIKE, arithmetic, "||", the aggregates count, sum, avg, min, max,
+// and the functions lower, upper, length, abs, coalesce.
+//
+// Commands run one at a time. A transaction holds the database until it is
+// committed or its context is cancelled, so every isolation level is honored.
+package rdbmem // import "github.com/kardianos/rdb/rdbmem"
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/kardianos/rdb"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// DriverName is the config driver name handled by this package.
+const DriverName = "mem"
+
+const defaultCapacity = 10
+
+var (
+	errPoolClosed = errors.New("pool closed")
+	errClosed     = errors.New("query closed")
+	errTxDone     = errors.New("transaction already committed or rolled back")
+	errStmtClosed = errors.New("statement closed")
+)
+
+func init() {
+	rdb.RegisterOpener(&Opener{})
+}
+
+// Opener implements an rdb.Opener for "mem" configs.
+type Opener struct{}
+
+var (
+	dbSync = sync.Mutex{}
+	dbList = make(map[string]*database)
+)
+
+// CanOpen returns true if the config driver name is "mem".
+func (o *Opener) CanOpen(config *rdb.Config) bool {
+	return config.DriverName == DriverName
+}
+
+// Open a pool to the named in-memory database.
+func (o *Opener) Open(ctx context.Context, config *rdb.Config) (rdb.Pool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	capacity := config.PoolMaxCapacity
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+	if config.PoolInitCapacity > capacity {
+		return nil, errors.Errorf("init capacity %d is greater then max capacity %d", config.PoolInitCapacity, capacity)
+	}
+	name := config.Hostname
+	if len(name) == 0 {
+		name = config.Instance
+	}
+
+	dbSync.Lock()
+	defer dbSync.Unlock()
+
+	db := dbList[strings.ToLower(name)]
+	if db == nil {
+		db = newDatabase(name)
+		if len(name) != 0 {
+			dbList[strings.ToLower(name)] = db
+		}
+	}
+	db.refs++
+	p := &pool{
+		db:     db,
+		types:  config.TypeDefaults,
+		slots:  make(chan struct{}, capacity),
+		closed: make(chan struct{}),
+	}
+	return p, nil
+}
+
+// pool implements rdb.Pool. Each query, transaction, and connection
+// takes a slot until it is done.
+type pool struct {
+	db        *database
+	types     rdb.TypeDefaults
+	slots     chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// acquire a pool slot. The returned release function may be called more then once.
+func (p *pool) acquire(ctx context.Context) (func(), error) {
+	select {
+	case <-p.closed:
+		return nil, errPoolClosed
+	default:
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.closed:
+		return nil, errPoolClosed
+	}
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			<-p.slots
+		})
+	}, nil
+}
+
+// run executes the statements while holding the database lock.
+// The number of rows changed is returned with the results.
+func (p *pool) run(ctx context.Context, list []statement, cmd *rdb.Command, params []rdb.Param) ([]*resultSet, int64, error) {
+	if err := p.db.acquire(ctx); err != nil {
+		return nil, 0, err
+	}
+	defer p.db.release()
+
+	params, err := rdb.ResolveParams(params, p.types)
+	if err != nil {
+		return nil, 0, err
+	}
+	if cmd.Validate {
+		if err = rdb.ValidateParams(params, cmd.TruncLongText); err != nil {
+			return nil, 0, err
+		}
+	}
+	e := &exec{db: p.db, params: params, trunc: cmd.TruncLongText}
+	sets, err := e.run(list)
+	return sets, e.affected, err
+}
+
+func (p *pool) query(ctx context.Context, list []statement, cmd *rdb.Command, params []rdb.Param) rdb.Next {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return errNext(err)
+	}
+	sets, affected, err := p.run(ctx, list, cmd, params)
+	n := newNext(ctx, sets, err, cmd.TextAsBytes, release)
+	n.affected = affected
+	return n
+}
+
+// Query runs the command.
+func (p *pool) Query(ctx context.Context, cmd *rdb.Command, params ...rdb.Param) rdb.Next {
+	if err := ctx.Err(); err != nil {
+		return errNext(err)
+	}
+	list, err := parse(cmd.SQL)
+	if err != nil {
+		return errNext(err)
+	}
+	return p.query(ctx, list, cmd, params)
+}
+
+// Prepare parses the command. The statement is closed when ctx is cancelled.
+func (p *pool) Prepare(ctx context.Context, cmd *rdb.Command) (rdb.Statement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	list, err := parse(cmd.SQL)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{pool: p, ctx: ctx, cmd: cmd, list: list}, nil
+}
+
+// Begin a transaction. The transaction holds the database until it is
+// committed or ctx is cancelled, at which point it is rolled back.
+func (p *pool) Begin(ctx context.Context, iso rdb.Isolation) (rdb.Transaction, error) {
+	if iso > rdb.IsoLinearizable {
+		return nil, errors.Errorf("unknown isolation level %d", iso)
+	}
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.db.acquire(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	tx := &transaction{
+		pool:    p,
+		release: release,
+		begin:   p.db.snapshot(),
+	}
+	go func() {
+		<-ctx.Done()
+		tx.rollback()
+	}()
+	return tx, nil
+}
+
+// Close the pool. The database is removed when the last pool to it is closed.
+func (p *pool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+
+		dbSync.Lock()
+		defer dbSync.Unlock()
+
+		p.db.refs--
+		if p.db.refs == 0 && dbList[strings.ToLower(p.db.name)] == p.db {
+			delete(dbList, strings.ToLower(p.db.name))
+		}
+	})
+}
+
+// Connection returns a dedicated connection. It is returned to the pool
+// when Close is called or ctx is cancelled.
+func (p *pool) Connection(ctx context.Context) (rdb.Connection, error) {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c := &connection{
+		pool:    p,
+		cancel:  cancel,
+		release: release,
+	}
+	go func() {
+		<-ctx.Done()
+		release()
+	}()
+	return c, nil
+}
+
+// Ping returns an error if the pool is closed.
+func (p *pool) Ping(ctx context.Context) error {
+	select {
+	case <-p.closed:
+		return errPoolClosed
+	default:
+	}
+	return ctx.Err()
+}
+
+// Status returns the pool status.
+func (p *pool) Status() rdb.PoolStatus {
+	return p
+}
+
+// Capacity returns the max number of connections.
+func (p *pool) Capacity() int {
+	return cap(p.slots)
+}
+
+// Available returns the number of connections not in use.
+func (p *pool) Available() int {
+	return cap(p.slots) - len(p.slots)
+}
+
+type connection struct {
+	pool    *pool
+	cancel  func()
+	release func()
+}
+
+// Close returns the connection to the pool.
+func (c *connection) Close() {
+	c.cancel()
+	c.release()
+}
+
+func (c *connection) Query(ctx context.Context, cmd *rdb.Command, params ...rdb.Param) rdb.Next {
+	if err := ctx.Err(); err != nil {
+		return errNext(err)
+	}
+	list, err := parse(cmd.SQL)
+	if err != nil {
+		return errNext(err)
+	}
+	sets, affected, err := c.pool.run(ctx, list, cmd, params)
+	n := newNext(ctx, sets, err, cmd.TextAsBytes, nil)
+	n.affected = affected
+	return n
+}
+
+type stmt struct {
+	pool *pool
+	ctx  context.Context
+	cmd  *rdb.Command
+	list []statement
+}
+
+func (s *stmt) Exec(ctx context.Context, params ...rdb.Param) rdb.Next {
+	if s.ctx.Err() != nil {
+		return errNext(errStmtClosed)
+	}
+	if err := ctx.Err(); err != nil {
+		return errNext(err)
+	}
+	return s.pool.query(ctx, s.list, s.cmd, params)
+}
+
+type savePoint struct {
+	name   string
+	tables map[string]*table
+}
+
+type transaction struct {
+	pool    *pool
+	release func()
+
+	mu     sync.Mutex
+	done   bool
+	begin  map[string]*table
+	points []savePoint
+}
+
+func (tx *transaction) Query(ctx context.Context, cmd *rdb.Command, params ...rdb.Param) rdb.Next {
+	if err := ctx.Err(); err != nil {
+		return errNext(err)
+	}
+	list, err := parse(cmd.SQL)
+	if err != nil {
+		return errNext(err)
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errNext(errTxDone)
+	}
+	params, err = rdb.ResolveParams(params, tx.pool.types)
+	if err != nil {
+		return errNext(err)
+	}
+	if cmd.Validate {
+		if err = rdb.ValidateParams(params, cmd.TruncLongText); err != nil {
+			return errNext(err)
+		}
+	}
+	e := &exec{db: tx.pool.db, params: params, trunc: cmd.TruncLongText}
+	sets, err := e.run(list)
+	n := newNext(ctx, sets, err, cmd.TextAsBytes, nil)
+	n.affected = e.affected
+	return n
+}
+
+// SavePoint records the current state of the transaction.
+func (tx *transaction) SavePoint(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTxDone
+	}
+	tx.points = append(tx.points, savePoint{name: name, tables: tx.pool.db.snapshot()})
+	return nil
+}
+
+// RollbackTo restores the state recorded by the most recent savepoint
+// with the name. Savepoints created after it are removed.
+func (tx *transaction) RollbackTo(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTxDone
+	}
+	for i := len(tx.points) - 1; i >= 0; i-- {
+		if strings.EqualFold(tx.points[i].name, name) {
+			tx.pool.db.tables = copyTables(tx.points[i].tables)
+			tx.points = tx.points[:i+1]
+			return nil
+		}
+	}
+	return newError(0, CodeUndefined, "savepoint %q does not exist", name)
+}
+
+// Commit the transaction.
+func (tx *transaction) Commit(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTxDone
+	}
+	tx.end()
+	return nil
+}
+
+var _ rdb.Rollbacker = &transaction{}
+
+// Rollback the transaction.
+func (tx *transaction) Rollback(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTxDone
+	}
+	tx.pool.db.tables = tx.begin
+	tx.end()
+	return nil
+}
+
+func (tx *transaction) rollback() {
+	tx.Rollback(context.Background())
+}
+
+func (tx *transaction) end() {
+	tx.done = true
+	tx.begin = nil
+	tx.points = nil
+	tx.pool.db.release()
+	tx.release()
+}
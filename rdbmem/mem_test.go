@@ -0,0 +1,165 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbmem_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/kardianos/rdb"
+	"github.com/kardianos/rdb/rdbconform"
+	"github.com/kardianos/rdb/rdbmem"
+	"golang.org/x/net/context"
+)
+
+var memColumnTypes = map[rdb.Type]string{
+	rdb.TypeText:     "text",
+	rdb.TypeUint8:    "uint8",
+	rdb.TypeUint16:   "uint16",
+	rdb.TypeUint32:   "uint32",
+	rdb.TypeUint64:   "uint64",
+	rdb.TypeInt8:     "tinyint",
+	rdb.TypeSerial16: "smallserial",
+	rdb.TypeSerial32: "serial",
+	rdb.TypeSerial64: "bigserial",
+	rdb.TypeMoney:    "money",
+	rdb.TypeUUID:     "uuid",
+	rdb.TypeEnum:     "enum",
+	rdb.TypeRange:    "range",
+	rdb.TypeArray:    "array",
+	rdb.TypeJSON:     "json",
+}
+
+func TestConformance(t *testing.T) {
+	conf, err := rdb.ParseConfigURL("mem://?max_cap=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdbconform.RunConformance(t, &rdbconform.Config{
+		Open: conf,
+		ColumnType: func(t rdb.Type) string {
+			if name, found := memColumnTypes[t]; found {
+				return name
+			}
+			return rdbconform.StandardColumnType(t)
+		},
+	})
+}
+
+func openPool(t *testing.T) rdb.Pool {
+	t.Helper()
+	conf, err := rdb.ParseConfigURL("mem://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := rdb.Open(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func queryBuffer(ctx context.Context, pool rdb.Pool, sql string) (*rdb.Buffer, error) {
+	next := pool.Query(ctx, &rdb.Command{SQL: sql})
+	defer next.Close()
+	return next.Buffer()
+}
+
+func TestExpr(t *testing.T) {
+	pool := openPool(t)
+	defer pool.Close()
+
+	list := []struct {
+		sql   string
+		value interface{}
+		typ   rdb.Type
+	}{
+		{"select 1 + 2 * 3", int64(7), rdb.TypeInt64},
+		{"select -9223372036854775808", int64(math.MinInt64), rdb.TypeInt64},
+		{"select -(2 - 5)", int64(3), rdb.TypeInt64},
+		{"select 1.5 * 2", 3.0, rdb.TypeFloat64},
+		{"select 'a' || 'b'", "ab", rdb.TypeText},
+		{"select coalesce(null, 'x')", "x", rdb.TypeText},
+		{"select coalesce(null, 2, 3)", int64(2), rdb.TypeInt64},
+		{"select coalesce(null, null)", nil, rdb.TypeUnknown},
+		{"select null is null", true, rdb.TypeBool},
+		{"select null = null", nil, rdb.TypeBool},
+		{"select null and false", false, rdb.TypeBool},
+		{"select null or true", true, rdb.TypeBool},
+		{"select not (1 = 1)", false, rdb.TypeBool},
+		{"select 2 between 1 and 3", true, rdb.TypeBool},
+		{"select 3 in (1, null)", nil, rdb.TypeBool},
+		{"select 3 not in (1, 2)", true, rdb.TypeBool},
+		{"select 'abc' like 'a%'", true, rdb.TypeBool},
+		{"select abs(-3)", int64(3), rdb.TypeInt64},
+		{"select length('héllo')", int64(5), rdb.TypeInt64},
+		{"select upper('a')", "A", rdb.TypeText},
+	}
+	ctx := context.Background()
+	for _, item := range list {
+		buf, err := queryBuffer(ctx, pool, item.sql)
+		if err != nil {
+			t.Errorf("%q: %v", item.sql, err)
+			continue
+		}
+		if len(buf.Row) != 1 {
+			t.Errorf("%q: got %d rows, want 1", item.sql, len(buf.Row))
+			continue
+		}
+		if v := buf.Row[0].Getx(0); !reflect.DeepEqual(v, item.value) {
+			t.Errorf("%q: got %#v, want %#v", item.sql, v, item.value)
+		}
+		if typ := buf.Schema[0].Type; typ != item.typ {
+			t.Errorf("%q: got type %v, want %v", item.sql, typ, item.typ)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	pool := openPool(t)
+	defer pool.Close()
+
+	ctx := context.Background()
+	setup := &rdb.Command{SQL: "create table t (a int not null primary key, b text)"}
+	if err := pool.Query(ctx, setup).Close(); err != nil {
+		t.Fatal(err)
+	}
+	list := []struct {
+		sql  string
+		code int
+		line int
+	}{
+		{"select (1", rdbmem.CodeSyntax, 1},
+		{"select 1;\nselect 'a", rdbmem.CodeSyntax, 2},
+		{"select missing from t", rdbmem.CodeUndefined, 1},
+		{"select a from missing", rdbmem.CodeUndefined, 1},
+		{"select nope(1)", rdbmem.CodeUndefined, 1},
+		{"select @p", rdbmem.CodeUndefined, 1},
+		{"select lower()", rdbmem.CodeSyntax, 1},
+		{"select coalesce()", rdbmem.CodeSyntax, 1},
+		{"select a from t where\nsum(a) > 1", rdbmem.CodeSyntax, 2},
+		{"select 'a' + 1", rdbmem.CodeType, 1},
+		{"select 1 where 1", rdbmem.CodeType, 1},
+		{"create table t (a int)", rdbmem.CodeDuplicate, 1},
+		{"create table u (a int, a int)", rdbmem.CodeDuplicate, 1},
+		{"insert into t (b) values ('x')", rdbmem.CodeConstraint, 1},
+		{"insert into t values (1, 'x'), (1, 'y')", rdbmem.CodeConstraint, 1},
+	}
+	for _, item := range list {
+		_, err := queryBuffer(ctx, pool, item.sql)
+		e, is := err.(*rdbmem.Error)
+		if !is {
+			t.Errorf("%q: got %v, want *rdbmem.Error", item.sql, err)
+			continue
+		}
+		if e.Code != item.code || e.Line != item.line {
+			t.Errorf("%q: got %v code %d, want code %d line %d", item.sql, e, e.Code, item.code, item.line)
+		}
+	}
+	if status := pool.Status(); status.Available() != status.Capacity() {
+		t.Errorf("got %d available, want %d", status.Available(), status.Capacity())
+	}
+}
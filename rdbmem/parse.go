@@ -0,0 +1,966 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbmem
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/kardianos/rdb"
+)
+
+type statement interface {
+	stmtLine() int
+}
+
+type createTable struct {
+	line        int
+	name        string
+	ifNotExists bool
+	columns     []rdb.Column
+}
+
+type dropTable struct {
+	line     int
+	name     string
+	ifExists bool
+}
+
+type insertStmt struct {
+	line    int
+	table   string
+	columns []string
+	values  [][]expr
+	query   *selectStmt
+}
+
+type setClause struct {
+	column string
+	value  expr
+}
+
+type updateStmt struct {
+	line  int
+	table string
+	set   []setClause
+	where expr
+}
+
+type deleteStmt struct {
+	line  int
+	table string
+	where expr
+}
+
+type joinKind byte
+
+const (
+	joinNone joinKind = iota
+	joinInner
+	joinLeft
+	joinCross
+)
+
+type fromItem struct {
+	table string
+	alias string
+	join  joinKind
+	on    expr
+}
+
+type selectItem struct {
+	expr      expr
+	alias     string
+	star      bool
+	starTable string
+}
+
+type orderItem struct {
+	expr expr
+	desc bool
+}
+
+type selectStmt struct {
+	line     int
+	distinct bool
+	items    []selectItem
+	from     []fromItem
+	where    expr
+	groupBy  []expr
+	having   expr
+	orderBy  []orderItem
+	limit    expr
+	offset   expr
+}
+
+func (s *createTable) stmtLine() int { return s.line }
+func (s *dropTable) stmtLine() int   { return s.line }
+func (s *insertStmt) stmtLine() int  { return s.line }
+func (s *updateStmt) stmtLine() int  { return s.line }
+func (s *deleteStmt) stmtLine() int  { return s.line }
+func (s *selectStmt) stmtLine() int  { return s.line }
+
+// Expression nodes.
+type expr interface{}
+
+type literal struct {
+	value interface{}
+}
+
+type paramRef struct {
+	line  int
+	name  string // Name without prefix, empty for positional.
+	index int    // Zero based position for positional parameters.
+}
+
+type columnRef struct {
+	line  int
+	table string
+	name  string
+}
+
+type unaryExpr struct {
+	line int
+	op   string
+	x    expr
+}
+
+type binaryExpr struct {
+	line int
+	op   string
+	l, r expr
+}
+
+type isNullExpr struct {
+	x   expr
+	not bool
+}
+
+type inExpr struct {
+	x    expr
+	list []expr
+	not  bool
+}
+
+type betweenExpr struct {
+	x, lo, hi expr
+	not       bool
+}
+
+type likeExpr struct {
+	line       int
+	x, pattern expr
+	not        bool
+}
+
+type callExpr struct {
+	line int
+	name string // Lower case function name.
+	args []expr
+	star bool
+}
+
+type parser struct {
+	toks     []token
+	pos      int
+	position int // Count of positional "?" parameters.
+}
+
+// parse parses the SQL text into a list of statements.
+func parse(text string) ([]statement, error) {
+	toks, err := lex(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	var list []statement
+	for {
+		for p.peek().is(";") {
+			p.pos++
+		}
+		if p.peek().kind == tokEOF {
+			return list, nil
+		}
+		st, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, st)
+		if t := p.peek(); t.kind != tokEOF && !t.is(";") {
+			return nil, p.unexpected(t)
+		}
+	}
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the keyword or symbol.
+func (p *parser) accept(s string) bool {
+	if p.peek().is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+func (p *parser) expect(s string) error {
+	if p.accept(s) {
+		return nil
+	}
+	t := p.peek()
+	return newError(t.line, CodeSyntax, "expected %q, found %s", s, describe(t))
+}
+func (p *parser) unexpected(t token) error {
+	return newError(t.line, CodeSyntax, "unexpected %s", describe(t))
+}
+
+func describe(t token) string {
+	if t.kind == tokEOF {
+		return "end of command"
+	}
+	return strconv.Quote(t.text)
+}
+
+var reserved = map[string]bool{
+	"select": true, "from": true, "where": true, "join": true, "inner": true,
+	"left": true, "outer": true, "cross": true, "on": true, "order": true,
+	"group": true, "having": true, "limit": true, "offset": true, "as": true,
+	"and": true, "or": true, "not": true, "union": true, "values": true,
+	"set": true, "by": true,
+}
+
+// ident reads an identifier.
+func (p *parser) ident() (string, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokQuotedIdent:
+	case t.kind == tokIdent && !reserved[strings.ToLower(t.text)]:
+	default:
+		return "", newError(t.line, CodeSyntax, "expected identifier, found %s", describe(t))
+	}
+	p.pos++
+	return t.text, nil
+}
+
+func (p *parser) statement() (statement, error) {
+	t := p.peek()
+	switch {
+	case t.is("create"):
+		return p.createTable()
+	case t.is("drop"):
+		return p.dropTable()
+	case t.is("insert"):
+		return p.insert()
+	case t.is("update"):
+		return p.update()
+	case t.is("delete"):
+		return p.delete()
+	case t.is("select"):
+		return p.selectStmt()
+	}
+	return nil, p.unexpected(t)
+}
+
+func (p *parser) createTable() (statement, error) {
+	st := &createTable{line: p.next().line}
+	if err := p.expect("table"); err != nil {
+		return nil, err
+	}
+	if p.accept("if") {
+		if err := p.expect("not"); err != nil {
+			return nil, err
+		}
+		if err := p.expect("exists"); err != nil {
+			return nil, err
+		}
+		st.ifNotExists = true
+	}
+	var err error
+	if st.name, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err = p.expect("("); err != nil {
+		return nil, err
+	}
+	for {
+		if p.accept("primary") {
+			if err = p.expect("key"); err != nil {
+				return nil, err
+			}
+			names, err := p.identList()
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range names {
+				found := false
+				for i := range st.columns {
+					if strings.EqualFold(st.columns[i].Name, name) {
+						st.columns[i].Key = true
+						st.columns[i].Nullable = false
+						found = true
+					}
+				}
+				if !found {
+					return nil, newError(st.line, CodeUndefined, "primary key column %q not defined", name)
+				}
+			}
+		} else {
+			col, err := p.columnDef()
+			if err != nil {
+				return nil, err
+			}
+			col.Index = len(st.columns)
+			st.columns = append(st.columns, col)
+		}
+		if p.accept(")") {
+			break
+		}
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func (p *parser) identList() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if p.accept(")") {
+			return names, nil
+		}
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) columnDef() (rdb.Column, error) {
+	var col rdb.Column
+	var err error
+	if col.Name, err = p.ident(); err != nil {
+		return col, err
+	}
+	t := p.next()
+	if t.kind != tokIdent {
+		return col, newError(t.line, CodeSyntax, "expected column type, found %s", describe(t))
+	}
+	typeName := strings.ToLower(t.text)
+	// Multi-word type names.
+	switch typeName {
+	case "double":
+		if p.accept("precision") {
+			typeName = "double precision"
+		}
+	case "character":
+		if p.accept("varying") {
+			typeName = "character varying"
+		}
+	case "timestamp", "time":
+		if p.peek().is("with") || p.peek().is("without") {
+			zone := strings.ToLower(p.next().text)
+			if err = p.expect("time"); err != nil {
+				return col, err
+			}
+			if err = p.expect("zone"); err != nil {
+				return col, err
+			}
+			typeName += " " + zone + " time zone"
+		}
+	}
+	var args []int
+	if p.accept("(") {
+		for {
+			t := p.next()
+			switch {
+			case t.kind == tokNumber:
+				n, err := strconv.Atoi(t.text)
+				if err != nil {
+					return col, newError(t.line, CodeSyntax, "invalid type length %q", t.text)
+				}
+				args = append(args, n)
+			case t.is("max"):
+				args = append(args, -1)
+			default:
+				return col, newError(t.line, CodeSyntax, "expected type length, found %s", describe(t))
+			}
+			if p.accept(")") {
+				break
+			}
+			if err = p.expect(","); err != nil {
+				return col, err
+			}
+		}
+	}
+	if err = setColumnType(&col, typeName, args); err != nil {
+		return col, newError(t.line, CodeSyntax, "%v", err)
+	}
+	col.Nullable = !col.Serial
+	for {
+		switch {
+		case p.accept("not"):
+			if err = p.expect("null"); err != nil {
+				return col, err
+			}
+			col.Nullable = false
+		case p.accept("null"):
+			col.Nullable = true
+		case p.accept("primary"):
+			if err = p.expect("key"); err != nil {
+				return col, err
+			}
+			col.Key = true
+			col.Nullable = false
+		default:
+			return col, nil
+		}
+	}
+}
+
+func (p *parser) dropTable() (statement, error) {
+	st := &dropTable{line: p.next().line}
+	if err := p.expect("table"); err != nil {
+		return nil, err
+	}
+	if p.accept("if") {
+		if err := p.expect("exists"); err != nil {
+			return nil, err
+		}
+		st.ifExists = true
+	}
+	var err error
+	st.name, err = p.ident()
+	return st, err
+}
+
+func (p *parser) insert() (statement, error) {
+	st := &insertStmt{line: p.next().line}
+	if err := p.expect("into"); err != nil {
+		return nil, err
+	}
+	var err error
+	if st.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if p.peek().is("(") {
+		if st.columns, err = p.identList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().is("select") {
+		st.query, err = p.selectStmt()
+		return st, err
+	}
+	if err = p.expect("values"); err != nil {
+		return nil, err
+	}
+	for {
+		if err = p.expect("("); err != nil {
+			return nil, err
+		}
+		list, err := p.exprList(")")
+		if err != nil {
+			return nil, err
+		}
+		st.values = append(st.values, list)
+		if !p.accept(",") {
+			return st, nil
+		}
+	}
+}
+
+// exprList reads comma separated expressions up to and including the end symbol.
+func (p *parser) exprList(end string) ([]expr, error) {
+	var list []expr
+	if p.accept(end) {
+		return list, nil
+	}
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if p.accept(end) {
+			return list, nil
+		}
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) update() (statement, error) {
+	st := &updateStmt{line: p.next().line}
+	var err error
+	if st.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err = p.expect("set"); err != nil {
+		return nil, err
+	}
+	for {
+		var sc setClause
+		if sc.column, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("="); err != nil {
+			return nil, err
+		}
+		if sc.value, err = p.expr(); err != nil {
+			return nil, err
+		}
+		st.set = append(st.set, sc)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if p.accept("where") {
+		st.where, err = p.expr()
+	}
+	return st, err
+}
+
+func (p *parser) delete() (statement, error) {
+	st := &deleteStmt{line: p.next().line}
+	if err := p.expect("from"); err != nil {
+		return nil, err
+	}
+	var err error
+	if st.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if p.accept("where") {
+		st.where, err = p.expr()
+	}
+	return st, err
+}
+
+func (p *parser) selectStmt() (*selectStmt, error) {
+	st := &selectStmt{line: p.next().line}
+	st.distinct = p.accept("distinct")
+	if !st.distinct {
+		p.accept("all")
+	}
+	var err error
+	for {
+		var item selectItem
+		switch {
+		case p.accept("*"):
+			item.star = true
+		case (p.peek().kind == tokIdent || p.peek().kind == tokQuotedIdent) && p.toks[p.pos+1].is(".") && p.toks[p.pos+2].is("*"):
+			item.star = true
+			item.starTable = p.next().text
+			p.pos += 2
+		default:
+			if item.expr, err = p.expr(); err != nil {
+				return nil, err
+			}
+			if p.accept("as") {
+				if item.alias, err = p.ident(); err != nil {
+					return nil, err
+				}
+			} else if t := p.peek(); t.kind == tokQuotedIdent || (t.kind == tokIdent && !reserved[strings.ToLower(t.text)]) {
+				item.alias = p.next().text
+			}
+		}
+		st.items = append(st.items, item)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if p.accept("from") {
+		if err = p.from(st); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("where") {
+		if st.where, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("group") {
+		if err = p.expect("by"); err != nil {
+			return nil, err
+		}
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			st.groupBy = append(st.groupBy, e)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if p.accept("having") {
+		if st.having, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("order") {
+		if err = p.expect("by"); err != nil {
+			return nil, err
+		}
+		for {
+			var item orderItem
+			if item.expr, err = p.expr(); err != nil {
+				return nil, err
+			}
+			if p.accept("desc") {
+				item.desc = true
+			} else {
+				p.accept("asc")
+			}
+			st.orderBy = append(st.orderBy, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if p.accept("limit") {
+		if st.limit, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("offset") {
+		if st.offset, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func (p *parser) from(st *selectStmt) error {
+	join := joinNone
+	for {
+		var item fromItem
+		var err error
+		item.join = join
+		if item.table, err = p.ident(); err != nil {
+			return err
+		}
+		if p.accept("as") {
+			if item.alias, err = p.ident(); err != nil {
+				return err
+			}
+		} else if t := p.peek(); t.kind == tokQuotedIdent || (t.kind == tokIdent && !reserved[strings.ToLower(t.text)]) {
+			item.alias = p.next().text
+		}
+		if join == joinInner || join == joinLeft {
+			if err = p.expect("on"); err != nil {
+				return err
+			}
+			if item.on, err = p.expr(); err != nil {
+				return err
+			}
+		}
+		st.from = append(st.from, item)
+
+		switch {
+		case p.accept(","):
+			join = joinCross
+		case p.accept("cross"):
+			if err = p.expect("join"); err != nil {
+				return err
+			}
+			join = joinCross
+		case p.accept("join"):
+			join = joinInner
+		case p.accept("inner"):
+			if err = p.expect("join"); err != nil {
+				return err
+			}
+			join = joinInner
+		case p.accept("left"):
+			p.accept("outer")
+			if err = p.expect("join"); err != nil {
+				return err
+			}
+			join = joinLeft
+		default:
+			return nil
+		}
+	}
+}
+
+// Expression parsing, lowest to highest precedence.
+
+func (p *parser) expr() (expr, error) {
+	return p.or()
+}
+
+func (p *parser) or() (expr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("or") {
+		line := p.next().line
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{line: line, op: "or", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) and() (expr, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("and") {
+		line := p.next().line
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{line: line, op: "and", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) not() (expr, error) {
+	if p.peek().is("not") {
+		line := p.next().line
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{line: line, op: "not", x: x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, error) {
+	l, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokSymbol && (t.text == "=" || t.text == "<>" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+			p.pos++
+			r, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			op := t.text
+			if op == "!=" {
+				op = "<>"
+			}
+			l = &binaryExpr{line: t.line, op: op, l: l, r: r}
+		case t.is("is"):
+			p.pos++
+			not := p.accept("not")
+			if err = p.expect("null"); err != nil {
+				return nil, err
+			}
+			l = &isNullExpr{x: l, not: not}
+		default:
+			not := false
+			if t.is("not") && (p.toks[p.pos+1].is("in") || p.toks[p.pos+1].is("like") || p.toks[p.pos+1].is("between")) {
+				p.pos++
+				not = true
+				t = p.peek()
+			}
+			switch {
+			case t.is("in"):
+				p.pos++
+				if err = p.expect("("); err != nil {
+					return nil, err
+				}
+				list, err := p.exprList(")")
+				if err != nil {
+					return nil, err
+				}
+				l = &inExpr{x: l, list: list, not: not}
+			case t.is("like"):
+				p.pos++
+				r, err := p.additive()
+				if err != nil {
+					return nil, err
+				}
+				l = &likeExpr{line: t.line, x: l, pattern: r, not: not}
+			case t.is("between"):
+				p.pos++
+				lo, err := p.additive()
+				if err != nil {
+					return nil, err
+				}
+				if err = p.expect("and"); err != nil {
+					return nil, err
+				}
+				hi, err := p.additive()
+				if err != nil {
+					return nil, err
+				}
+				l = &betweenExpr{x: l, lo: lo, hi: hi, not: not}
+			default:
+				return l, nil
+			}
+		}
+	}
+}
+
+func (p *parser) additive() (expr, error) {
+	l, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !t.is("+") && !t.is("-") && !t.is("||") {
+			return l, nil
+		}
+		p.pos++
+		r, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{line: t.line, op: t.text, l: l, r: r}
+	}
+}
+
+func (p *parser) multiplicative() (expr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !t.is("*") && !t.is("/") && !t.is("%") {
+			return l, nil
+		}
+		p.pos++
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{line: t.line, op: t.text, l: l, r: r}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	t := p.peek()
+	if t.is("-") || t.is("+") {
+		p.pos++
+		// Parse a negative number as one literal so the smallest
+		// int64 is not read as a float.
+		if n := p.peek(); t.text == "-" && n.kind == tokNumber {
+			p.pos++
+			return number(n, "-"+n.text)
+		}
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if t.text == "+" {
+			return x, nil
+		}
+		return &unaryExpr{line: t.line, op: "-", x: x}, nil
+	}
+	return p.primary()
+}
+
+// number returns the literal for the text of the number token t.
+// Numbers without a fraction or exponent that fit are int64.
+func number(t token, text string) (expr, error) {
+	if !strings.ContainsAny(text, ".eE") {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return &literal{value: n}, nil
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, newError(t.line, CodeSyntax, "invalid number %q", t.text)
+	}
+	return &literal{value: f}, nil
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return number(t, t.text)
+	case tokString:
+		return &literal{value: t.text}, nil
+	case tokParam:
+		switch t.text[0] {
+		case '?':
+			p.position++
+			return &paramRef{line: t.line, index: p.position - 1}, nil
+		case '$':
+			if n, err := strconv.Atoi(t.text[1:]); err == nil {
+				if n < 1 {
+					return nil, newError(t.line, CodeSyntax, "invalid parameter %q", t.text)
+				}
+				return &paramRef{line: t.line, index: n - 1}, nil
+			}
+		}
+		return &paramRef{line: t.line, name: t.text[1:], index: -1}, nil
+	case tokSymbol:
+		if t.text == "(" {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		}
+	case tokIdent, tokQuotedIdent:
+		if t.kind == tokIdent {
+			switch strings.ToLower(t.text) {
+			case "null":
+				return &literal{}, nil
+			case "true":
+				return &literal{value: true}, nil
+			case "false":
+				return &literal{value: false}, nil
+			}
+			if reserved[strings.ToLower(t.text)] {
+				break
+			}
+		}
+		if t.kind == tokIdent && p.peek().is("(") {
+			p.pos++
+			c := &callExpr{line: t.line, name: strings.ToLower(t.text)}
+			if p.accept("*") {
+				c.star = true
+				return c, p.expect(")")
+			}
+			var err error
+			c.args, err = p.exprList(")")
+			return c, err
+		}
+		if p.accept(".") {
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			return &columnRef{line: t.line, table: t.text, name: name}, nil
+		}
+		return &columnRef{line: t.line, name: t.text}, nil
+	}
+	return nil, p.unexpected(t)
+}
@@ -0,0 +1,137 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbmem
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	toks, err := lex("select a, 'it''s' -- note\n/* c */ from [t t] where x <= 1.5e3 and y = @p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []token{
+		{tokIdent, "select", 1},
+		{tokIdent, "a", 1},
+		{tokSymbol, ",", 1},
+		{tokString, "it's", 1},
+		{tokIdent, "from", 2},
+		{tokQuotedIdent, "t t", 2},
+		{tokIdent, "where", 2},
+		{tokIdent, "x", 2},
+		{tokSymbol, "<=", 2},
+		{tokNumber, "1.5e3", 2},
+		{tokIdent, "and", 2},
+		{tokIdent, "y", 2},
+		{tokSymbol, "=", 2},
+		{tokParam, "@p", 2},
+		{tokEOF, "", 2},
+	}
+	if !reflect.DeepEqual(toks, want) {
+		t.Errorf("got %v\nwant %v", toks, want)
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	list := []struct {
+		text string
+		line int
+	}{
+		{"select 'a", 1},
+		{"select 1\n/* a", 2},
+		{"select \"a", 1},
+		{"select @", 1},
+		{"select 1\n#", 2},
+	}
+	for _, item := range list {
+		_, err := lex(item.text)
+		e, is := err.(*Error)
+		if !is {
+			t.Errorf("%q: got %v, want *Error", item.text, err)
+			continue
+		}
+		if e.Code != CodeSyntax || e.Line != item.line {
+			t.Errorf("%q: got code %d line %d, want code %d line %d", item.text, e.Code, e.Line, CodeSyntax, item.line)
+		}
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	list := []struct {
+		text string
+		want expr
+	}{
+		{"1", &literal{value: int64(1)}},
+		{"1.5", &literal{value: 1.5}},
+		{"9223372036854775808", &literal{value: 9223372036854775808.0}},
+		{"-9223372036854775808", &literal{value: int64(math.MinInt64)}},
+		{"- 5", &literal{value: int64(-5)}},
+		{"+5", &literal{value: int64(5)}},
+		{"-a", &unaryExpr{line: 1, op: "-", x: &columnRef{line: 1, name: "a"}}},
+		{"null", &literal{}},
+		{"1 + 2 * 3", &binaryExpr{line: 1, op: "+",
+			l: &literal{value: int64(1)},
+			r: &binaryExpr{line: 1, op: "*", l: &literal{value: int64(2)}, r: &literal{value: int64(3)}},
+		}},
+		{"(1 + 2) * 3", &binaryExpr{line: 1, op: "*",
+			l: &binaryExpr{line: 1, op: "+", l: &literal{value: int64(1)}, r: &literal{value: int64(2)}},
+			r: &literal{value: int64(3)},
+		}},
+		{"t.a is not null", &isNullExpr{x: &columnRef{line: 1, table: "t", name: "a"}, not: true}},
+		{"lower(?, $2, :n)", &callExpr{line: 1, name: "lower", args: []expr{
+			&paramRef{line: 1, index: 0},
+			&paramRef{line: 1, index: 1},
+			&paramRef{line: 1, name: "n", index: -1},
+		}}},
+		{"count(*)", &callExpr{line: 1, name: "count", star: true}},
+	}
+	for _, item := range list {
+		st, err := parse("select " + item.text)
+		if err != nil {
+			t.Errorf("%q: %v", item.text, err)
+			continue
+		}
+		got := st[0].(*selectStmt).items[0].expr
+		if !reflect.DeepEqual(got, item.want) {
+			t.Errorf("%q: got %#v, want %#v", item.text, got, item.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	list := []struct {
+		text string
+		line int
+	}{
+		{"selec 1", 1},
+		{"select", 1},
+		{"select 1 2", 1},
+		{"select 1;\nselect (1", 2},
+		{"select 1e999", 1},
+		{"select $0", 1},
+		{"select a from", 1},
+		{"select a\nfrom t where", 2},
+		{"create table t (", 1},
+		{"create table t (a\nnotatype)", 2},
+		{"insert into t values (1", 1},
+		{"update t set", 1},
+		{"delete t", 1},
+		{"select a from t order a", 1},
+	}
+	for _, item := range list {
+		_, err := parse(item.text)
+		e, is := err.(*Error)
+		if !is {
+			t.Errorf("%q: got %v, want *Error", item.text, err)
+			continue
+		}
+		if e.Code != CodeSyntax || e.Line != item.line {
+			t.Errorf("%q: got %v code %d, want code %d line %d", item.text, e, e.Code, CodeSyntax, item.line)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbmem
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kardianos/rdb"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// next implements rdb.Next over materialized result sets.
+type next struct {
+	mu          sync.Mutex
+	sets        []*resultSet
+	err         error // Error from the command, returned after the results.
+	closeErr    error // Set when closed before the last result was read.
+	index       int
+	textAsBytes bool
+	affected    int64
+	release     func()
+	done        chan struct{}
+	doneOnce    sync.Once
+}
+
+func errNext(err error) *next {
+	return &next{err: err, done: make(chan struct{})}
+}
+
+// newNext returns the results. The release function is called after the
+// last row of the last result is read, Close is called, or ctx is cancelled.
+func newNext(ctx context.Context, sets []*resultSet, err error, textAsBytes bool, release func()) *next {
+	n := &next{
+		sets:        sets,
+		err:         err,
+		textAsBytes: textAsBytes,
+		release:     release,
+		done:        make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			n.mu.Lock()
+			if n.closeErr == nil {
+				n.closeErr = ctx.Err()
+			}
+			n.mu.Unlock()
+			n.finish()
+		case <-n.done:
+		}
+	}()
+	return n
+}
+
+// finish releases the connection.
+func (n *next) finish() {
+	n.doneOnce.Do(func() {
+		close(n.done)
+		if n.release != nil {
+			n.release()
+		}
+	})
+}
+
+func (n *next) Result() (rdb.Result, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closeErr != nil {
+		return nil, n.closeErr
+	}
+	if n.index < len(n.sets) {
+		set := n.sets[n.index]
+		n.index++
+		return &result{next: n, set: set}, nil
+	}
+	n.finish()
+	return nil, n.err
+}
+
+func (n *next) Buffer() (*rdb.Buffer, error) {
+	res, err := n.Result()
+	if res == nil {
+		return nil, err
+	}
+	buf := &rdb.Buffer{
+		Schema: res.Schema(),
+	}
+	for {
+		r, err := res.Scan()
+		if err != nil {
+			return buf, err
+		}
+		if r == nil {
+			return buf, nil
+		}
+		buf.Row = append(buf.Row, r)
+	}
+}
+
+func (n *next) BufferSet() (rdb.BufferSet, error) {
+	var set rdb.BufferSet
+	for {
+		buf, err := n.Buffer()
+		if buf != nil {
+			set = append(set, buf)
+		}
+		if err != nil || buf == nil {
+			return set, err
+		}
+	}
+}
+
+var _ rdb.RowsAffecter = &next{}
+
+// RowsAffected returns the number of rows inserted, updated and deleted
+// by the command.
+func (n *next) RowsAffected() (int64, bool) {
+	return n.affected, true
+}
+
+// Close releases the connection. The command error, if any, is returned.
+func (n *next) Close() error {
+	n.mu.Lock()
+	if n.closeErr == nil {
+		n.closeErr = errClosed
+	}
+	n.mu.Unlock()
+
+	n.finish()
+	return n.err
+}
+
+// result implements rdb.Result.
+type result struct {
+	next *next
+	set  *resultSet
+	pos  int
+
+	prepName  map[string]interface{}
+	prepIndex map[int]interface{}
+}
+
+func (r *result) Prep(name string, value interface{}) rdb.Result {
+	if r.prepName == nil {
+		r.prepName = make(map[string]interface{})
+	}
+	r.prepName[name] = value
+	return r
+}
+func (r *result) Prepx(index int, value interface{}) rdb.Result {
+	if r.prepIndex == nil {
+		r.prepIndex = make(map[int]interface{})
+	}
+	r.prepIndex[index] = value
+	return r
+}
+
+func (r *result) Scan() (rdb.Row, error) {
+	r.next.mu.Lock()
+	closeErr := r.next.closeErr
+	r.next.mu.Unlock()
+	if closeErr != nil {
+		return nil, closeErr
+	}
+	if r.pos >= len(r.set.rows) {
+		r.next.mu.Lock()
+		last := r.next.index == len(r.next.sets) && r.next.sets[r.next.index-1] == r.set
+		r.next.mu.Unlock()
+		if last {
+			r.next.finish()
+		}
+		return nil, nil
+	}
+	stored := r.set.rows[r.pos]
+	r.pos++
+
+	values := make([]interface{}, len(stored))
+	for i, v := range stored {
+		values[i] = outputValue(v, r.next.textAsBytes)
+	}
+	prep := make(map[int]interface{}, len(r.prepIndex)+len(r.prepName))
+	for i, v := range r.prepIndex {
+		if i < 0 || i >= len(values) {
+			return nil, errors.Errorf("prepared column index %d out of range", i)
+		}
+		prep[i] = v
+	}
+	for name, v := range r.prepName {
+		i := r.set.schema.Index(name)
+		if i < 0 {
+			return nil, errors.Errorf("prepared column %q not found in result", name)
+		}
+		prep[i] = v
+	}
+	for i, dest := range prep {
+		if w, is := dest.(io.Writer); is {
+			var err error
+			switch v := values[i].(type) {
+			case nil:
+			case []byte:
+				_, err = w.Write(v)
+			case string:
+				_, err = io.WriteString(w, v)
+			default:
+				_, err = fmt.Fprint(w, v)
+			}
+			if err != nil {
+				return nil, err
+			}
+		} else if err := rdb.Assign(dest, values[i]); err != nil {
+			return nil, errors.Wrapf(err, "column %q", r.set.schema[i].Name)
+		}
+		values[i] = nil
+	}
+	return rdb.NewDataRow(r.set.schema, values), nil
+}
+
+func (r *result) Schema() rdb.Schema {
+	return r.set.schema
+}
+
+func (r *result) Close() error {
+	return r.next.Close()
+}
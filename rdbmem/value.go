@@ -0,0 +1,703 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbmem
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kardianos/rdb"
+	"github.com/pkg/errors"
+)
+
+type typeDef struct {
+	t      rdb.Type
+	length int // Default length, zero if not applicable.
+}
+
+var typeNames = map[string]typeDef{
+	"text":              {rdb.TypeText, -1},
+	"ntext":             {rdb.TypeText, -1},
+	"clob":              {rdb.TypeText, -1},
+	"citext":            {rdb.TypeText, -1},
+	"varchar":           {rdb.TypeAnsiVarChar, -1},
+	"nvarchar":          {rdb.TypeVarChar, -1},
+	"character varying": {rdb.TypeVarChar, -1},
+	"char":              {rdb.TypeAnsiChar, 1},
+	"character":         {rdb.TypeAnsiChar, 1},
+	"nchar":             {rdb.TypeChar, 1},
+
+	"binary":    {rdb.TypeBinary, -1},
+	"varbinary": {rdb.TypeBinary, -1},
+	"bytea":     {rdb.TypeBinary, -1},
+	"blob":      {rdb.TypeBinary, -1},
+
+	"bool":    {rdb.TypeBool, 0},
+	"boolean": {rdb.TypeBool, 0},
+	"bit":     {rdb.TypeBool, 0},
+
+	"uint8":    {rdb.TypeUint8, 0},
+	"uint16":   {rdb.TypeUint16, 0},
+	"uint32":   {rdb.TypeUint32, 0},
+	"uint64":   {rdb.TypeUint64, 0},
+	"tinyint":  {rdb.TypeInt8, 0},
+	"smallint": {rdb.TypeInt16, 0},
+	"int2":     {rdb.TypeInt16, 0},
+	"int":      {rdb.TypeInt32, 0},
+	"integer":  {rdb.TypeInt32, 0},
+	"int4":     {rdb.TypeInt32, 0},
+	"bigint":   {rdb.TypeInt64, 0},
+	"int8":     {rdb.TypeInt64, 0},
+
+	"smallserial": {rdb.TypeSerial16, 0},
+	"serial2":     {rdb.TypeSerial16, 0},
+	"serial":      {rdb.TypeSerial32, 0},
+	"serial4":     {rdb.TypeSerial32, 0},
+	"bigserial":   {rdb.TypeSerial64, 0},
+	"serial8":     {rdb.TypeSerial64, 0},
+
+	"real":             {rdb.TypeFloat32, 0},
+	"float4":           {rdb.TypeFloat32, 0},
+	"float":            {rdb.TypeFloat64, 0},
+	"float8":           {rdb.TypeFloat64, 0},
+	"double":           {rdb.TypeFloat64, 0},
+	"double precision": {rdb.TypeFloat64, 0},
+
+	"decimal": {rdb.TypeDecimal, 0},
+	"numeric": {rdb.TypeDecimal, 0},
+	"money":   {rdb.TypeMoney, 0},
+
+	"timestamptz":                 {rdb.TypeTimestampz, 0},
+	"timestamp with time zone":    {rdb.TypeTimestampz, 0},
+	"datetimeoffset":              {rdb.TypeTimestampz, 0},
+	"timestamp":                   {rdb.TypeTimestamp, 0},
+	"timestamp without time zone": {rdb.TypeTimestamp, 0},
+	"datetime":                    {rdb.TypeTimestamp, 0},
+	"datetime2":                   {rdb.TypeTimestamp, 0},
+	"date":                        {rdb.TypeDate, 0},
+	"time":                        {rdb.TypeTime, 0},
+	"timetz":                      {rdb.TypeTime, 0},
+	"time with time zone":         {rdb.TypeTime, 0},
+	"time without time zone":      {rdb.TypeTime, 0},
+	"interval":                    {rdb.TypeDuration, 0},
+
+	"uuid":             {rdb.TypeUUID, 0},
+	"uniqueidentifier": {rdb.TypeUUID, 0},
+	"enum":             {rdb.TypeEnum, 0},
+	"range":            {rdb.TypeRange, 0},
+	"array":            {rdb.TypeArray, 0},
+	"json":             {rdb.TypeJSON, 0},
+	"jsonb":            {rdb.TypeJSON, 0},
+	"xml":              {rdb.TypeXML, 0},
+}
+
+// setColumnType sets the column type from the type name and arguments.
+func setColumnType(col *rdb.Column, name string, args []int) error {
+	def, found := typeNames[name]
+	if !found {
+		return errors.Errorf("unknown type %q", name)
+	}
+	col.Type = def.t
+	col.Generic = def.t.GenericOf()
+	col.Length = def.length
+	switch col.Type {
+	case rdb.TypeSerial16, rdb.TypeSerial32, rdb.TypeSerial64:
+		col.Serial = true
+	case rdb.TypeDecimal:
+		col.Precision, col.Scale = 18, 0
+		if len(args) > 0 {
+			col.Precision = args[0]
+		}
+		if len(args) > 1 {
+			col.Scale = args[1]
+		}
+		if col.Precision < 1 || col.Scale < 0 || col.Scale > col.Precision {
+			return errors.Errorf("invalid decimal precision %d and scale %d", col.Precision, col.Scale)
+		}
+		return nil
+	case rdb.TypeMoney:
+		col.Precision, col.Scale = 19, 4
+	}
+	if len(args) > 0 {
+		if col.Length == 0 {
+			return errors.Errorf("type %q does not take a length", name)
+		}
+		col.Length = args[0]
+	}
+	return nil
+}
+
+// inferColumn returns the column information for a value of unknown source.
+func inferColumn(v interface{}) rdb.Column {
+	t := rdb.InferType(v, rdb.TypeDefaults{})
+	col := rdb.Column{
+		Type:     t,
+		Generic:  t.GenericOf(),
+		Nullable: true,
+	}
+	if col.Generic == rdb.Text || col.Generic == rdb.Binary {
+		col.Length = -1
+	}
+	return col
+}
+
+var integerRange = map[rdb.Type][2]*big.Int{
+	rdb.TypeUint8:    {big.NewInt(0), big.NewInt(math.MaxUint8)},
+	rdb.TypeUint16:   {big.NewInt(0), big.NewInt(math.MaxUint16)},
+	rdb.TypeUint32:   {big.NewInt(0), big.NewInt(math.MaxUint32)},
+	rdb.TypeUint64:   {big.NewInt(0), new(big.Int).SetUint64(math.MaxUint64)},
+	rdb.TypeInt8:     {big.NewInt(math.MinInt8), big.NewInt(math.MaxInt8)},
+	rdb.TypeInt16:    {big.NewInt(math.MinInt16), big.NewInt(math.MaxInt16)},
+	rdb.TypeInt32:    {big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)},
+	rdb.TypeInt64:    {big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)},
+	rdb.TypeSerial16: {big.NewInt(math.MinInt16), big.NewInt(math.MaxInt16)},
+	rdb.TypeSerial32: {big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)},
+	rdb.TypeSerial64: {big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)},
+}
+
+// coerce converts v to the stored form for the column.
+// Text and binary values longer then the column length are truncated
+// if trunc is true, otherwise an error is returned.
+func coerce(col rdb.Column, v interface{}, trunc bool) (interface{}, error) {
+	v, err := valuerValue(v)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		if !col.Nullable {
+			return nil, errors.Errorf("column %q cannot be null", col.Name)
+		}
+		return nil, nil
+	}
+	switch col.Generic {
+	case rdb.Text:
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		case rdb.Numeric:
+			s = v.String()
+		default:
+			return nil, typeError(col, v)
+		}
+		if col.Length > 0 && utf8.RuneCountInString(s) > col.Length {
+			if !trunc {
+				return nil, errors.Errorf("text too long for column %q length %d", col.Name, col.Length)
+			}
+			s = string([]rune(s)[:col.Length])
+		}
+		return s, nil
+	case rdb.Binary:
+		var b []byte
+		switch v := v.(type) {
+		case []byte:
+			b = append([]byte(nil), v...)
+		case string:
+			b = []byte(v)
+		default:
+			return nil, typeError(col, v)
+		}
+		if col.Length > 0 && len(b) > col.Length {
+			if !trunc {
+				return nil, errors.Errorf("binary too long for column %q length %d", col.Name, col.Length)
+			}
+			b = b[:col.Length]
+		}
+		return b, nil
+	case rdb.Bool:
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, typeError(col, v)
+			}
+			return b, nil
+		}
+		if n, ok := toBigInt(v); ok && (n.Sign() == 0 || n.Cmp(big.NewInt(1)) == 0) {
+			return n.Sign() == 1, nil
+		}
+		return nil, typeError(col, v)
+	case rdb.Integer:
+		n, ok := toBigInt(v)
+		if !ok {
+			return nil, typeError(col, v)
+		}
+		r := integerRange[col.Type]
+		if n.Cmp(r[0]) < 0 || n.Cmp(r[1]) > 0 {
+			return nil, errors.Errorf("value %v out of range for column %q", n, col.Name)
+		}
+		switch col.Type {
+		case rdb.TypeUint8:
+			return uint8(n.Uint64()), nil
+		case rdb.TypeUint16:
+			return uint16(n.Uint64()), nil
+		case rdb.TypeUint32:
+			return uint32(n.Uint64()), nil
+		case rdb.TypeUint64:
+			return n.Uint64(), nil
+		case rdb.TypeInt8:
+			return int8(n.Int64()), nil
+		case rdb.TypeInt16, rdb.TypeSerial16:
+			return int16(n.Int64()), nil
+		case rdb.TypeInt32, rdb.TypeSerial32:
+			return int32(n.Int64()), nil
+		}
+		return n.Int64(), nil
+	case rdb.Float:
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, typeError(col, v)
+		}
+		if col.Type == rdb.TypeFloat32 {
+			return float32(f), nil
+		}
+		return f, nil
+	case rdb.Decimal:
+		r, ok := toRat(v)
+		if !ok {
+			return nil, typeError(col, v)
+		}
+		d := ratNumeric(r, col.Scale)
+		if _, err := d.Fit(col.Precision, col.Scale); err != nil {
+			return nil, errors.Errorf("value %s overflows column %q precision %d scale %d", d, col.Name, col.Precision, col.Scale)
+		}
+		return d, nil
+	case rdb.Time:
+		if col.Type == rdb.TypeDuration {
+			switch v := v.(type) {
+			case time.Duration:
+				return v, nil
+			case string:
+				d, err := time.ParseDuration(v)
+				if err != nil {
+					return nil, typeError(col, v)
+				}
+				return d, nil
+			}
+			if n, ok := toBigInt(v); ok && n.IsInt64() {
+				return time.Duration(n.Int64()), nil
+			}
+			return nil, typeError(col, v)
+		}
+		var t time.Time
+		switch v := v.(type) {
+		case time.Time:
+			t = v
+		case string:
+			var err error
+			if t, err = parseTime(v); err != nil {
+				return nil, typeError(col, v)
+			}
+		default:
+			return nil, typeError(col, v)
+		}
+		switch col.Type {
+		case rdb.TypeDate:
+			y, m, d := t.Date()
+			t = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		case rdb.TypeTimestamp:
+			y, m, d := t.Date()
+			t = time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		}
+		return t, nil
+	}
+	return v, nil
+}
+
+func typeError(col rdb.Column, v interface{}) error {
+	if len(col.Name) == 0 {
+		return errors.Errorf("cannot convert %T to type %d", v, col.Type)
+	}
+	return errors.Errorf("cannot convert %T for column %q", v, col.Name)
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("invalid time %q", s)
+}
+
+// toBigInt returns an integer value. Floats and decimals must be integral.
+func toBigInt(v interface{}) (*big.Int, bool) {
+	if d, is := v.(rdb.Numeric); is {
+		r := d.Rat()
+		if !r.IsInt() {
+			return nil, false
+		}
+		return new(big.Int).Set(r.Num()), true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64, reflect.String:
+		r, ok := toRat(v)
+		if !ok || !r.IsInt() {
+			return nil, false
+		}
+		return new(big.Int).Set(r.Num()), true
+	}
+	return nil, false
+}
+
+// toFloat returns a floating point value for a number or numeric text.
+func toFloat(v interface{}) (float64, bool) {
+	if d, is := v.(rdb.Numeric); is {
+		return d.Float64(), true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.String:
+		f, err := strconv.ParseFloat(rv.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toRat returns an exact value for a number or numeric text.
+func toRat(v interface{}) (*big.Rat, bool) {
+	if d, is := v.(rdb.Numeric); is {
+		return d.Rat(), true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(rv.Uint())), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(f), true
+	case reflect.String:
+		r, ok := new(big.Rat).SetString(strings.TrimSpace(rv.String()))
+		return r, ok
+	}
+	return nil, false
+}
+
+func isNumber(v interface{}) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	_, is := v.(rdb.Numeric)
+	return is
+}
+
+func isInteger(v interface{}) bool {
+	switch v.(type) {
+	case time.Duration:
+		return false
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func isFloat(v interface{}) bool {
+	k := reflect.ValueOf(v).Kind()
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+// compare two non-null values.
+func compare(a, b interface{}) (int, error) {
+	switch {
+	case isNumber(a) && isNumber(b),
+		isNumber(a) && reflect.ValueOf(b).Kind() == reflect.String,
+		isNumber(b) && reflect.ValueOf(a).Kind() == reflect.String:
+		if isFloat(a) || isFloat(b) {
+			fa, oka := toFloat(a)
+			fb, okb := toFloat(b)
+			if !oka || !okb {
+				return 0, errors.Errorf("cannot compare %v with %v", a, b)
+			}
+			switch {
+			case fa < fb:
+				return -1, nil
+			case fa > fb:
+				return 1, nil
+			}
+			return 0, nil
+		}
+		ra, oka := toRat(a)
+		rb, okb := toRat(b)
+		if !oka || !okb {
+			return 0, errors.Errorf("cannot compare %v with %v", a, b)
+		}
+		return ra.Cmp(rb), nil
+	}
+	switch a := a.(type) {
+	case string:
+		switch b := b.(type) {
+		case string:
+			return strings.Compare(a, b), nil
+		case []byte:
+			return strings.Compare(a, string(b)), nil
+		case time.Time:
+			t, err := parseTime(a)
+			if err != nil {
+				return 0, err
+			}
+			return compare(t, b)
+		}
+	case []byte:
+		switch b := b.(type) {
+		case []byte:
+			return bytes.Compare(a, b), nil
+		case string:
+			return bytes.Compare(a, []byte(b)), nil
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0, nil
+			case !a:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case time.Time:
+		switch b := b.(type) {
+		case time.Time:
+			switch {
+			case a.Before(b):
+				return -1, nil
+			case a.After(b):
+				return 1, nil
+			}
+			return 0, nil
+		case string:
+			t, err := parseTime(b)
+			if err != nil {
+				return 0, err
+			}
+			return compare(a, t)
+		}
+	case time.Duration:
+		if b, ok := b.(time.Duration); ok {
+			switch {
+			case a < b:
+				return -1, nil
+			case a > b:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if reflect.TypeOf(a) == reflect.TypeOf(b) {
+		if reflect.DeepEqual(a, b) {
+			return 0, nil
+		}
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)), nil
+	}
+	return 0, errors.Errorf("cannot compare %T with %T", a, b)
+}
+
+// arith applies an arithmetic operator to two non-null values.
+func arith(op string, a, b interface{}) (interface{}, error) {
+	if op == "||" {
+		return toText(a) + toText(b), nil
+	}
+	if !isNumber(a) || !isNumber(b) {
+		return nil, errors.Errorf("operator %s requires numbers, found %T and %T", op, a, b)
+	}
+	switch {
+	case isInteger(a) && isInteger(b):
+		x, _ := toBigInt(a)
+		y, _ := toBigInt(b)
+		z := new(big.Int)
+		switch op {
+		case "+":
+			z.Add(x, y)
+		case "-":
+			z.Sub(x, y)
+		case "*":
+			z.Mul(x, y)
+		case "/", "%":
+			if y.Sign() == 0 {
+				return nil, errors.New("division by zero")
+			}
+			if op == "/" {
+				z.Quo(x, y)
+			} else {
+				z.Rem(x, y)
+			}
+		}
+		if !z.IsInt64() {
+			return nil, errors.New("integer overflow")
+		}
+		return z.Int64(), nil
+	case isFloat(a) || isFloat(b):
+		x, _ := toFloat(a)
+		y, _ := toFloat(b)
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/":
+			if y == 0 {
+				return nil, errors.New("division by zero")
+			}
+			return x / y, nil
+		}
+		if y == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return math.Mod(x, y), nil
+	}
+	x, _ := toRat(a)
+	y, _ := toRat(b)
+	z := new(big.Rat)
+	switch op {
+	case "+":
+		z.Add(x, y)
+	case "-":
+		z.Sub(x, y)
+	case "*":
+		z.Mul(x, y)
+	case "/":
+		if y.Sign() == 0 {
+			return nil, errors.New("division by zero")
+		}
+		z.Quo(x, y)
+	default:
+		return nil, errors.Errorf("operator %s not supported for decimals", op)
+	}
+	return ratNumeric(z, -1), nil
+}
+
+// ratNumeric returns an exact value rounded to scale. If scale is negative,
+// the value has the fewest digits, up to 18 decimal places.
+func ratNumeric(r *big.Rat, scale int) rdb.Numeric {
+	if scale >= 0 {
+		d, _ := rdb.ParseNumeric(r.FloatString(scale))
+		return d
+	}
+	s := r.FloatString(18)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	d, _ := rdb.ParseNumeric(s)
+	return d
+}
+
+// valuerValue returns the value of a driver.Valuer.
+// Numeric values are kept exact.
+func valuerValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case rdb.Numeric:
+		return v, nil
+	case rdb.NullDecimal:
+		if !v.Valid {
+			return nil, nil
+		}
+		return v.Numeric, nil
+	case driver.Valuer:
+		return v.Value()
+	}
+	return v, nil
+}
+
+func toText(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case rdb.Numeric:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// like matches SQL LIKE patterns with "%" and "_" wildcards.
+func like(s, pattern string) bool {
+	if len(pattern) == 0 {
+		return len(s) == 0
+	}
+	r, size := utf8.DecodeRuneInString(pattern)
+	switch r {
+	case '%':
+		for i := 0; i <= len(s); {
+			if like(s[i:], pattern[size:]) {
+				return true
+			}
+			if i == len(s) {
+				break
+			}
+			_, n := utf8.DecodeRuneInString(s[i:])
+			i += n
+		}
+		return false
+	case '_':
+		if len(s) == 0 {
+			return false
+		}
+		_, n := utf8.DecodeRuneInString(s)
+		return like(s[n:], pattern[size:])
+	}
+	if len(s) == 0 {
+		return false
+	}
+	sr, n := utf8.DecodeRuneInString(s)
+	return sr == r && like(s[n:], pattern[size:])
+}
+
+// outputValue returns the value given to callers for a stored value.
+func outputValue(v interface{}, textAsBytes bool) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return append([]byte(nil), v...)
+	case string:
+		if textAsBytes {
+			return []byte(v)
+		}
+	}
+	return v
+}
@@ -0,0 +1,612 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+// Package rdbtest provides a scripted rdb.Pool for unit tests.
+//
+// Register the commands the code under test is expected to run, along
+// with what each should return, then check all were run:
+//
+//	m := rdbtest.New()
+//	m.ExpectName("GetUser").WithParams(rdb.Param{Name: "id", Value: 5}).
+//		WillReturn(rdbtest.NewBuffer(rdbtest.Columns("id", "name"), []interface{}{5, "Ann"}))
+//	m.ExpectSQL(`^update account`).WillReturnError(&rdbtest.Error{Code: 1205, Message: "deadlock"})
+//
+//	runCodeUnderTest(ctx, m)
+//
+//	if err := m.ExpectationsWereMet(); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// By default expectations must be met in the order they are registered.
+// Calls that do not match an expectation return an error and are reported
+// by ExpectationsWereMet.
+package rdbtest // import "github.com/kardianos/rdb/rdbtest"
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/kardianos/rdb"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const defaultCapacity = 10
+
+var (
+	errPoolClosed = errors.New("rdbtest: pool closed")
+	errClosed     = errors.New("rdbtest: query closed")
+	errTxDone     = errors.New("rdbtest: transaction already committed or rolled back")
+	errStmtClosed = errors.New("rdbtest: statement closed")
+)
+
+type kind byte
+
+const (
+	kindQuery kind = iota
+	kindBegin
+	kindCommit
+	kindRollback
+	kindSavePoint
+	kindRollbackTo
+)
+
+// Expectation is a call the code under test is expected to make and
+// what the call returns.
+type Expectation struct {
+	kind   kind
+	name   string // Command name or savepoint name.
+	re     *regexp.Regexp
+	params []rdb.Param
+	check  bool // Check params.
+	iso    rdb.Isolation
+	isoSet bool
+
+	result []*rdb.Buffer
+	err    error
+	delay  time.Duration
+
+	met bool
+}
+
+// WithParams sets the parameters the query must be called with.
+// Parameter names and values must be equal. Type and Length are only
+// compared when set in the expected parameter.
+func (e *Expectation) WithParams(params ...rdb.Param) *Expectation {
+	e.params = params
+	e.check = true
+	return e
+}
+
+// WithIsolation sets the isolation level a transaction must begin with.
+func (e *Expectation) WithIsolation(iso rdb.Isolation) *Expectation {
+	e.iso = iso
+	e.isoSet = true
+	return e
+}
+
+// WillReturn sets the results returned by the query, one result per buffer.
+func (e *Expectation) WillReturn(result ...*rdb.Buffer) *Expectation {
+	e.result = result
+	return e
+}
+
+// WillReturnError sets the error returned by the call. If the query also
+// returns results, the error is returned after the last result is read.
+// Use an *Error to return an rdb.SQLError.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// WillDelay waits for the duration before the call returns. If the call
+// context is cancelled first, the call returns the context error.
+func (e *Expectation) WillDelay(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+func (e *Expectation) String() string {
+	var s string
+	switch e.kind {
+	case kindQuery:
+		if e.re != nil {
+			s = fmt.Sprintf("query matching %q", e.re.String())
+		} else {
+			s = fmt.Sprintf("query named %q", e.name)
+		}
+		if e.check {
+			s += fmt.Sprintf(" with params %v", e.params)
+		}
+	case kindBegin:
+		s = "begin"
+		if e.isoSet {
+			s += fmt.Sprintf(" with isolation %d", e.iso)
+		}
+	case kindCommit:
+		s = "commit"
+	case kindRollback:
+		s = "rollback"
+	case kindSavePoint:
+		s = fmt.Sprintf("savepoint %q", e.name)
+	case kindRollbackTo:
+		s = fmt.Sprintf("rollback to savepoint %q", e.name)
+	}
+	return s
+}
+
+// call is a single call made to the mock.
+type call struct {
+	kind   kind
+	name   string
+	cmd    *rdb.Command
+	params []rdb.Param
+	iso    rdb.Isolation
+}
+
+func (c *call) String() string {
+	switch c.kind {
+	case kindQuery:
+		return fmt.Sprintf("query named %q with SQL %q and params %v", c.cmd.Name, c.cmd.SQL, c.params)
+	case kindBegin:
+		return fmt.Sprintf("begin with isolation %d", c.iso)
+	case kindCommit:
+		return "commit"
+	case kindRollback:
+		return "rollback"
+	case kindSavePoint:
+		return fmt.Sprintf("savepoint %q", c.name)
+	case kindRollbackTo:
+		return fmt.Sprintf("rollback to savepoint %q", c.name)
+	}
+	return "unknown"
+}
+
+func (e *Expectation) match(c *call) bool {
+	if e.kind != c.kind {
+		return false
+	}
+	switch c.kind {
+	case kindQuery:
+		if e.re != nil {
+			if !e.re.MatchString(c.cmd.SQL) {
+				return false
+			}
+		} else if e.name != c.cmd.Name {
+			return false
+		}
+		if e.check && !matchParams(e.params, c.params) {
+			return false
+		}
+	case kindBegin:
+		if e.isoSet && e.iso != c.iso {
+			return false
+		}
+	case kindSavePoint, kindRollbackTo:
+		if e.name != c.name {
+			return false
+		}
+	}
+	return true
+}
+
+func matchParams(want, got []rdb.Param) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		w, g := want[i], got[i]
+		if w.Name != g.Name || w.Out != g.Out {
+			return false
+		}
+		if w.Type != 0 && w.Type != g.Type {
+			return false
+		}
+		if w.Length != 0 && w.Length != g.Length {
+			return false
+		}
+		if !reflect.DeepEqual(w.Value, g.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Mock is a scripted rdb.Pool.
+type Mock struct {
+	mu         sync.Mutex
+	expect     []*Expectation
+	unexpected []*call
+	unordered  bool
+	closed     bool
+	inUse      int
+	txs        []*transaction // Open transactions.
+}
+
+var _ rdb.Pool = &Mock{}
+
+// New returns a Mock with no expectations.
+func New() *Mock {
+	return &Mock{}
+}
+
+// MatchUnordered allows expectations to be met in any order.
+func (m *Mock) MatchUnordered() *Mock {
+	m.mu.Lock()
+	m.unordered = true
+	m.mu.Unlock()
+	return m
+}
+
+func (m *Mock) add(e *Expectation) *Expectation {
+	m.mu.Lock()
+	m.expect = append(m.expect, e)
+	m.mu.Unlock()
+	return e
+}
+
+// ExpectName expects a query with a Command.Name equal to name.
+func (m *Mock) ExpectName(name string) *Expectation {
+	return m.add(&Expectation{kind: kindQuery, name: name})
+}
+
+// ExpectSQL expects a query with Command.SQL matching the regular expression.
+// It panics if the expression does not compile.
+func (m *Mock) ExpectSQL(pattern string) *Expectation {
+	return m.add(&Expectation{kind: kindQuery, re: regexp.MustCompile(pattern)})
+}
+
+// ExpectBegin expects a transaction to begin.
+func (m *Mock) ExpectBegin() *Expectation {
+	return m.add(&Expectation{kind: kindBegin})
+}
+
+// ExpectCommit expects a transaction to be committed.
+func (m *Mock) ExpectCommit() *Expectation {
+	return m.add(&Expectation{kind: kindCommit})
+}
+
+// ExpectRollback expects a transaction to be rolled back, either with
+// Rollback or by cancelling its context before it is committed. A
+// cancelled transaction is matched before the next call to the mock or
+// ExpectationsWereMet.
+func (m *Mock) ExpectRollback() *Expectation {
+	return m.add(&Expectation{kind: kindRollback})
+}
+
+// ExpectSavePoint expects a savepoint to be created in a transaction.
+func (m *Mock) ExpectSavePoint(name string) *Expectation {
+	return m.add(&Expectation{kind: kindSavePoint, name: name})
+}
+
+// ExpectRollbackTo expects a transaction to roll back to a savepoint.
+func (m *Mock) ExpectRollbackTo(name string) *Expectation {
+	return m.add(&Expectation{kind: kindRollbackTo, name: name})
+}
+
+// ExpectationsWereMet returns an error listing expectations that were not
+// met and calls that were not expected.
+func (m *Mock) ExpectationsWereMet() error {
+	m.rollbackCancelled()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var list []error
+	for _, e := range m.expect {
+		if !e.met {
+			list = append(list, errors.Errorf("rdbtest: expected %v, not called", e))
+		}
+	}
+	for _, c := range m.unexpected {
+		list = append(list, errors.Errorf("rdbtest: unexpected %v", c))
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	return rdb.ErrorList{List: list}
+}
+
+// find the expectation for the call and mark it met.
+func (m *Mock) find(c *call) (*Expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, errPoolClosed
+	}
+	for _, e := range m.expect {
+		if e.met {
+			continue
+		}
+		if e.match(c) {
+			e.met = true
+			return e, nil
+		}
+		if !m.unordered {
+			break
+		}
+	}
+	m.unexpected = append(m.unexpected, c)
+	return nil, errors.Errorf("rdbtest: unexpected %v", c)
+}
+
+// rollbackCancelled records the rollback of each open transaction whose
+// context is done, so the rollback is matched before any later call.
+func (m *Mock) rollbackCancelled() {
+	m.mu.Lock()
+	var cancelled []*transaction
+	open := m.txs[:0]
+	for _, tx := range m.txs {
+		if tx.ctx.Err() != nil {
+			cancelled = append(cancelled, tx)
+		} else {
+			open = append(open, tx)
+		}
+	}
+	m.txs = open
+	m.mu.Unlock()
+
+	for _, tx := range cancelled {
+		tx.rollback()
+	}
+}
+
+// removeTx removes a finished transaction from the open list.
+func (m *Mock) removeTx(tx *transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, item := range m.txs {
+		if item == tx {
+			m.txs = append(m.txs[:i], m.txs[i+1:]...)
+			return
+		}
+	}
+}
+
+// wait for the expectation delay or the context to be cancelled.
+func wait(ctx context.Context, e *Expectation) error {
+	if e.delay <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(e.delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// call records the call and returns the error for it.
+// Callers run rollbackCancelled first.
+func (m *Mock) call(ctx context.Context, c *call) (*Expectation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e, err := m.find(c)
+	if err != nil {
+		return nil, err
+	}
+	if err = wait(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, e.err
+}
+
+func (m *Mock) query(ctx context.Context, cmd *rdb.Command, params []rdb.Param) rdb.Next {
+	m.rollbackCancelled()
+	if err := ctx.Err(); err != nil {
+		return &next{err: err}
+	}
+	e, err := m.find(&call{kind: kindQuery, cmd: cmd, params: params})
+	if err != nil {
+		return &next{err: err}
+	}
+	if err = wait(ctx, e); err != nil {
+		return &next{err: err}
+	}
+	m.mu.Lock()
+	m.inUse++
+	m.mu.Unlock()
+	return newNext(ctx, e.result, e.err, func() {
+		m.mu.Lock()
+		m.inUse--
+		m.mu.Unlock()
+	})
+}
+
+// Query runs the expectation that matches the command.
+func (m *Mock) Query(ctx context.Context, cmd *rdb.Command, params ...rdb.Param) rdb.Next {
+	return m.query(ctx, cmd, params)
+}
+
+// Prepare returns a statement. Each Exec of the statement is matched
+// as a query of the command.
+func (m *Mock) Prepare(ctx context.Context, cmd *rdb.Command) (rdb.Statement, error) {
+	if err := m.Ping(ctx); err != nil {
+		return nil, err
+	}
+	return &stmt{mock: m, ctx: ctx, cmd: cmd}, nil
+}
+
+// Begin runs the begin expectation. If ctx is cancelled before the
+// transaction is committed, the rollback expectation is run before the
+// next call to the mock or ExpectationsWereMet.
+func (m *Mock) Begin(ctx context.Context, iso rdb.Isolation) (rdb.Transaction, error) {
+	m.rollbackCancelled()
+	if _, err := m.call(ctx, &call{kind: kindBegin, iso: iso}); err != nil {
+		return nil, err
+	}
+	tx := &transaction{mock: m, ctx: ctx}
+	m.mu.Lock()
+	m.txs = append(m.txs, tx)
+	m.mu.Unlock()
+	return tx, nil
+}
+
+// Close the pool. Calls after Close return an error.
+func (m *Mock) Close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+}
+
+// Connection returns a connection. Queries on the connection are matched
+// the same as queries on the pool.
+func (m *Mock) Connection(ctx context.Context) (rdb.Connection, error) {
+	if err := m.Ping(ctx); err != nil {
+		return nil, err
+	}
+	return &connection{mock: m}, nil
+}
+
+// Ping returns an error if the pool is closed.
+func (m *Mock) Ping(ctx context.Context) error {
+	m.mu.Lock()
+	closed := m.closed
+	m.mu.Unlock()
+	if closed {
+		return errPoolClosed
+	}
+	return ctx.Err()
+}
+
+// Status returns the pool status.
+func (m *Mock) Status() rdb.PoolStatus {
+	return m
+}
+
+// Capacity returns the capacity reported by the pool.
+func (m *Mock) Capacity() int {
+	return defaultCapacity
+}
+
+// Available returns the capacity less the number of open queries.
+func (m *Mock) Available() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return defaultCapacity - m.inUse
+}
+
+type connection struct {
+	mock *Mock
+}
+
+func (c *connection) Close() {}
+
+func (c *connection) Query(ctx context.Context, cmd *rdb.Command, params ...rdb.Param) rdb.Next {
+	return c.mock.query(ctx, cmd, params)
+}
+
+type stmt struct {
+	mock *Mock
+	ctx  context.Context
+	cmd  *rdb.Command
+}
+
+func (s *stmt) Exec(ctx context.Context, params ...rdb.Param) rdb.Next {
+	if s.ctx.Err() != nil {
+		return &next{err: errStmtClosed}
+	}
+	return s.mock.query(ctx, s.cmd, params)
+}
+
+type transaction struct {
+	mock *Mock
+	ctx  context.Context
+
+	mu       sync.Mutex
+	finished bool
+}
+
+// active rolls back cancelled transactions, then returns an error if tx
+// is finished.
+func (tx *transaction) active() error {
+	tx.mock.rollbackCancelled()
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.finished {
+		return errTxDone
+	}
+	return nil
+}
+
+func (tx *transaction) Query(ctx context.Context, cmd *rdb.Command, params ...rdb.Param) rdb.Next {
+	if err := tx.active(); err != nil {
+		return &next{err: err}
+	}
+	return tx.mock.query(ctx, cmd, params)
+}
+
+func (tx *transaction) SavePoint(ctx context.Context, name string) error {
+	if err := tx.active(); err != nil {
+		return err
+	}
+	_, err := tx.mock.call(ctx, &call{kind: kindSavePoint, name: name})
+	return err
+}
+
+func (tx *transaction) RollbackTo(ctx context.Context, name string) error {
+	if err := tx.active(); err != nil {
+		return err
+	}
+	_, err := tx.mock.call(ctx, &call{kind: kindRollbackTo, name: name})
+	return err
+}
+
+func (tx *transaction) Commit(ctx context.Context) error {
+	tx.mock.rollbackCancelled()
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.finished {
+		return errTxDone
+	}
+	_, err := tx.mock.call(ctx, &call{kind: kindCommit})
+	if err != nil {
+		return err
+	}
+	tx.finished = true
+	tx.mock.removeTx(tx)
+	return nil
+}
+
+var _ rdb.Rollbacker = &transaction{}
+
+// Rollback the transaction. It is matched to an ExpectRollback expectation.
+func (tx *transaction) Rollback(ctx context.Context) error {
+	tx.mock.rollbackCancelled()
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.finished {
+		return errTxDone
+	}
+	tx.finished = true
+	tx.mock.removeTx(tx)
+	_, err := tx.mock.call(ctx, &call{kind: kindRollback})
+	return err
+}
+
+func (tx *transaction) rollback() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.finished {
+		return
+	}
+	tx.finished = true
+	tx.mock.find(&call{kind: kindRollback})
+}
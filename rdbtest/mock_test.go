@@ -0,0 +1,222 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbtest_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+	"github.com/kardianos/rdb/rdbtest"
+	"golang.org/x/net/context"
+)
+
+func TestRollbackOnCancel(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		m := rdbtest.New()
+		m.ExpectBegin()
+		m.ExpectRollback()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if _, err := m.Begin(ctx, rdb.IsoDefault); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+	}
+}
+
+func TestRollbackBeforeLaterCall(t *testing.T) {
+	m := rdbtest.New()
+	m.ExpectBegin()
+	m.ExpectRollback()
+	m.ExpectName("after")
+
+	ctx := context.Background()
+	txCtx, cancel := context.WithCancel(ctx)
+	tx, err := m.Begin(txCtx, rdb.IsoDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := m.Query(ctx, &rdb.Command{Name: "after"}).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(ctx); err == nil {
+		t.Error("commit after rollback: expected error")
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitNoRollback(t *testing.T) {
+	m := rdbtest.New()
+	m.ExpectBegin()
+	m.ExpectCommit()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tx, err := m.Begin(ctx, rdb.IsoDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	m := rdbtest.New()
+	m.ExpectBegin()
+	m.ExpectRollback()
+
+	ctx := context.Background()
+	tx, err := m.Begin(ctx, rdb.IsoDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := tx.(interface {
+		Rollback(ctx context.Context) error
+	})
+	if !ok {
+		t.Fatal("transaction has no Rollback method")
+	}
+	if err = r.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Rollback(ctx); err == nil {
+		t.Error("second rollback: expected error")
+	}
+	if err = m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatchOrder(t *testing.T) {
+	ctx := context.Background()
+	m := rdbtest.New()
+	m.ExpectName("first")
+	m.ExpectName("second")
+
+	if err := m.Query(ctx, &rdb.Command{Name: "second"}).Close(); err == nil {
+		t.Fatal("out of order query: expected error")
+	}
+	for _, name := range []string{"first", "second"} {
+		if err := m.Query(ctx, &rdb.Command{Name: name}).Close(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	err := m.ExpectationsWereMet()
+	if err == nil || !strings.Contains(err.Error(), `unexpected query named "second"`) {
+		t.Fatalf("expected the out of order call to be reported, got %v", err)
+	}
+}
+
+func TestMatchUnordered(t *testing.T) {
+	ctx := context.Background()
+	m := rdbtest.New().MatchUnordered()
+	m.ExpectSQL(`^select a`)
+	m.ExpectSQL(`^select b`)
+
+	for _, sql := range []string{"select b", "select a"} {
+		if err := m.Query(ctx, &rdb.Command{SQL: sql}).Close(); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatchParams(t *testing.T) {
+	ctx := context.Background()
+	list := []struct {
+		name  string
+		want  rdb.Param
+		got   rdb.Param
+		match bool
+	}{
+		{"equal", rdb.Param{Name: "id", Value: 5}, rdb.Param{Name: "id", Value: 5}, true},
+		{"value", rdb.Param{Name: "id", Value: 5}, rdb.Param{Name: "id", Value: 6}, false},
+		{"value type", rdb.Param{Name: "id", Value: 5}, rdb.Param{Name: "id", Value: int64(5)}, false},
+		{"name", rdb.Param{Name: "id", Value: 5}, rdb.Param{Name: "key", Value: 5}, false},
+		{"type unset", rdb.Param{Name: "id", Value: 5}, rdb.Param{Name: "id", Type: rdb.TypeInt32, Value: 5}, true},
+		{"type set", rdb.Param{Name: "id", Type: rdb.TypeInt64, Value: 5}, rdb.Param{Name: "id", Type: rdb.TypeInt32, Value: 5}, false},
+		{"length set", rdb.Param{Name: "s", Length: 10, Value: "a"}, rdb.Param{Name: "s", Length: 20, Value: "a"}, false},
+	}
+	for _, item := range list {
+		m := rdbtest.New()
+		m.ExpectName("q").WithParams(item.want)
+		err := m.Query(ctx, &rdb.Command{Name: "q"}, item.got).Close()
+		if (err == nil) != item.match {
+			t.Errorf("%s: got error %v, want match %t", item.name, err, item.match)
+		}
+		if met := m.ExpectationsWereMet() == nil; met != item.match {
+			t.Errorf("%s: expectations met %t, want %t", item.name, met, item.match)
+		}
+	}
+
+	m := rdbtest.New()
+	m.ExpectName("q").WithParams(rdb.Param{Name: "a", Value: 1})
+	if err := m.Query(ctx, &rdb.Command{Name: "q"}).Close(); err == nil {
+		t.Error("missing params: expected error")
+	}
+}
+
+func TestUnmetExpectations(t *testing.T) {
+	m := rdbtest.New()
+	m.ExpectName("never")
+	m.ExpectCommit()
+
+	err := m.ExpectationsWereMet()
+	list, ok := err.(rdb.ErrorList)
+	if !ok || len(list.List) != 2 {
+		t.Fatalf("expected two unmet expectations, got %v", err)
+	}
+	if !strings.Contains(list.List[0].Error(), `query named "never"`) || !strings.Contains(list.List[1].Error(), "commit") {
+		t.Errorf("unexpected messages: %v", err)
+	}
+}
+
+func TestReturn(t *testing.T) {
+	ctx := context.Background()
+	m := rdbtest.New()
+	m.ExpectName("get").WillReturn(
+		rdbtest.NewBuffer(rdbtest.Columns("id", "name"), []interface{}{5, "Ann"}),
+		rdbtest.NewBuffer(rdbtest.Columns("n")),
+	)
+	m.ExpectName("fail").WillReturnError(&rdbtest.Error{Line: 2, Code: 1205, Message: "deadlock"})
+	m.ExpectName("slow").WillDelay(time.Second)
+
+	set, err := m.Query(ctx, &rdb.Command{Name: "get"}).BufferSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 2 || len(set[0].Row) != 1 || set[0].Row[0].Get("name") != "Ann" {
+		t.Fatalf("unexpected result %v", set)
+	}
+	_, err = m.Query(ctx, &rdb.Command{Name: "fail"}).BufferSet()
+	if sqlErr, ok := err.(rdb.SQLError); !ok || sqlErr.ErrorCode() != 1205 || sqlErr.LineNumber() != 2 {
+		t.Fatalf("expected rdb.SQLError, got %v", err)
+	}
+	slowCtx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	if err := m.Query(slowCtx, &rdb.Command{Name: "slow"}).Close(); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.Status().Available(); n != m.Status().Capacity() {
+		t.Errorf("queries left open: %d of %d available", n, m.Status().Capacity())
+	}
+}
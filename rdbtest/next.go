@@ -0,0 +1,255 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdbtest
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kardianos/rdb"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// Error implements rdb.SQLError.
+type Error struct {
+	Line    int
+	Code    int
+	Message string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", err.Line, err.Message)
+}
+
+// LineNumber of the error.
+func (err *Error) LineNumber() int {
+	return err.Line
+}
+
+// ErrorCode of the error.
+func (err *Error) ErrorCode() int {
+	return err.Code
+}
+
+// Columns returns a schema with the named columns.
+func Columns(names ...string) rdb.Schema {
+	schema := make(rdb.Schema, len(names))
+	for i, name := range names {
+		schema[i] = rdb.Column{Name: name, Index: i, Nullable: true, Length: -1}
+	}
+	return schema
+}
+
+// NewBuffer returns a buffer with the schema and rows. Each row must have
+// a value for each column in the schema.
+func NewBuffer(schema rdb.Schema, rows ...[]interface{}) *rdb.Buffer {
+	buf := &rdb.Buffer{
+		Schema: schema,
+		Row:    make([]rdb.Row, len(rows)),
+	}
+	for i, values := range rows {
+		if len(values) != len(schema) {
+			panic(fmt.Sprintf("rdbtest: row %d has %d values, schema has %d columns", i, len(values), len(schema)))
+		}
+		buf.Row[i] = rdb.NewDataRow(schema, values)
+	}
+	return buf
+}
+
+// next implements rdb.Next over the expectation results.
+type next struct {
+	mu       sync.Mutex
+	result   []*rdb.Buffer
+	err      error
+	closeErr error
+	index    int
+	release  func()
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newNext(ctx context.Context, result []*rdb.Buffer, err error, release func()) *next {
+	n := &next{
+		result:  result,
+		err:     err,
+		release: release,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			n.mu.Lock()
+			if n.closeErr == nil {
+				n.closeErr = ctx.Err()
+			}
+			n.mu.Unlock()
+			n.finish()
+		case <-n.done:
+		}
+	}()
+	return n
+}
+
+func (n *next) finish() {
+	if n.done == nil {
+		return
+	}
+	n.doneOnce.Do(func() {
+		close(n.done)
+		if n.release != nil {
+			n.release()
+		}
+	})
+}
+
+func (n *next) Result() (rdb.Result, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closeErr != nil {
+		return nil, n.closeErr
+	}
+	if n.index < len(n.result) {
+		buf := n.result[n.index]
+		n.index++
+		return &result{next: n, buf: buf}, nil
+	}
+	n.finish()
+	return nil, n.err
+}
+
+func (n *next) Buffer() (*rdb.Buffer, error) {
+	res, err := n.Result()
+	if res == nil {
+		return nil, err
+	}
+	buf := &rdb.Buffer{
+		Schema: res.Schema(),
+	}
+	for {
+		r, err := res.Scan()
+		if err != nil {
+			return buf, err
+		}
+		if r == nil {
+			return buf, nil
+		}
+		buf.Row = append(buf.Row, r)
+	}
+}
+
+func (n *next) BufferSet() (rdb.BufferSet, error) {
+	var set rdb.BufferSet
+	for {
+		buf, err := n.Buffer()
+		if buf != nil {
+			set = append(set, buf)
+		}
+		if err != nil || buf == nil {
+			return set, err
+		}
+	}
+}
+
+func (n *next) Close() error {
+	n.mu.Lock()
+	if n.closeErr == nil {
+		n.closeErr = errClosed
+	}
+	n.mu.Unlock()
+
+	n.finish()
+	return n.err
+}
+
+// result implements rdb.Result over a buffer.
+type result struct {
+	next *next
+	buf  *rdb.Buffer
+	pos  int
+
+	prepName  map[string]interface{}
+	prepIndex map[int]interface{}
+}
+
+func (r *result) Prep(name string, value interface{}) rdb.Result {
+	if r.prepName == nil {
+		r.prepName = make(map[string]interface{})
+	}
+	r.prepName[name] = value
+	return r
+}
+func (r *result) Prepx(index int, value interface{}) rdb.Result {
+	if r.prepIndex == nil {
+		r.prepIndex = make(map[int]interface{})
+	}
+	r.prepIndex[index] = value
+	return r
+}
+
+func (r *result) Scan() (rdb.Row, error) {
+	r.next.mu.Lock()
+	closeErr := r.next.closeErr
+	r.next.mu.Unlock()
+	if closeErr != nil {
+		return nil, closeErr
+	}
+	if r.pos >= len(r.buf.Row) {
+		return nil, nil
+	}
+	src := r.buf.Row[r.pos]
+	r.pos++
+
+	schema := r.buf.Schema
+	values := make([]interface{}, len(schema))
+	for i := range values {
+		values[i] = src.Getx(i)
+	}
+	prep := make(map[int]interface{}, len(r.prepIndex)+len(r.prepName))
+	for i, v := range r.prepIndex {
+		if i < 0 || i >= len(values) {
+			return nil, errors.Errorf("rdbtest: prepared column index %d out of range", i)
+		}
+		prep[i] = v
+	}
+	for name, v := range r.prepName {
+		i := schema.Index(name)
+		if i < 0 {
+			return nil, errors.Errorf("rdbtest: prepared column %q not found in result", name)
+		}
+		prep[i] = v
+	}
+	for i, dest := range prep {
+		if w, is := dest.(io.Writer); is {
+			var err error
+			switch v := values[i].(type) {
+			case nil:
+			case []byte:
+				_, err = w.Write(v)
+			case string:
+				_, err = io.WriteString(w, v)
+			default:
+				_, err = fmt.Fprint(w, v)
+			}
+			if err != nil {
+				return nil, err
+			}
+		} else if err := rdb.Assign(dest, values[i]); err != nil {
+			return nil, errors.Wrapf(err, "rdbtest: column %q", schema[i].Name)
+		}
+		values[i] = nil
+	}
+	return rdb.NewDataRow(schema, values), nil
+}
+
+func (r *result) Schema() rdb.Schema {
+	return r.buf.Schema
+}
+
+func (r *result) Close() error {
+	return r.next.Close()
+}
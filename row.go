@@ -0,0 +1,317 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// DataRow is a Row that holds the values of a single result row.
+// Drivers may return it from Result.Scan.
+//
+// Typed accessors return the zero value if the column is missing, the value
+// is null, or the value cannot be converted. Errors are collected and
+// returned from Err:
+//
+//	var id int64
+//	row := rdb.NewDataRow(schema, values)
+//	name := row.String("Name")
+//	row.Into("ID", &id)
+//	if err := row.Err(); err != nil {
+//		return err
+//	}
+type DataRow struct {
+	Schema Schema
+	Values []interface{}
+
+	errs []error
+}
+
+var _ Row = &DataRow{}
+
+// NewDataRow returns a row with one value for each column in the schema.
+func NewDataRow(schema Schema, values []interface{}) *DataRow {
+	return &DataRow{
+		Schema: schema,
+		Values: values,
+	}
+}
+
+// Err returns the errors collected from accessors, or nil if there are none.
+// Multiple errors are returned as an ErrorList.
+func (r *DataRow) Err() error {
+	switch len(r.errs) {
+	case 0:
+		return nil
+	case 1:
+		return r.errs[0]
+	}
+	return ErrorList{List: r.errs}
+}
+
+// Index returns the index of the named column or -1 if not found.
+func (r *DataRow) Index(name string) int {
+	return r.Schema.Index(name)
+}
+
+func (r *DataRow) name(index int) string {
+	if index < len(r.Schema) {
+		return r.Schema[index].Name
+	}
+	return fmt.Sprintf("%d", index)
+}
+
+// index returns the index of the named column, recording an error if missing.
+func (r *DataRow) index(name string) int {
+	index := r.Index(name)
+	if index < 0 {
+		r.errs = append(r.errs, fmt.Errorf("Column %q not found", name))
+	}
+	return index
+}
+
+// value returns the value at index, recording an error if out of range.
+func (r *DataRow) value(index int) (interface{}, bool) {
+	if index < 0 || index >= len(r.Values) {
+		if index >= 0 {
+			r.errs = append(r.errs, fmt.Errorf("Column index %d out of range", index))
+		}
+		return nil, false
+	}
+	return r.Values[index], true
+}
+
+// Get returns the value of the named column.
+func (r *DataRow) Get(name string) interface{} {
+	return r.Getx(r.index(name))
+}
+
+// Getx returns the value of the column at index.
+func (r *DataRow) Getx(index int) interface{} {
+	v, _ := r.value(index)
+	return v
+}
+
+// Into sets the value pointed to by value to the named column.
+func (r *DataRow) Into(name string, value interface{}) Row {
+	return r.Intox(r.index(name), value)
+}
+
+// Intox sets the value pointed to by value to the column at index.
+func (r *DataRow) Intox(index int, value interface{}) Row {
+	v, ok := r.value(index)
+	if !ok {
+		return r
+	}
+	if err := Assign(value, v); err != nil {
+		r.errs = append(r.errs, fmt.Errorf("Column %q: %v", r.name(index), err))
+	}
+	return r
+}
+
+// convert sets the value pointed to by dest to the column at index.
+// A null value is an error if null is false.
+func (r *DataRow) convert(index int, dest interface{}, null bool) bool {
+	v, ok := r.value(index)
+	if !ok {
+		return false
+	}
+	if v == nil {
+		if !null {
+			r.errs = append(r.errs, fmt.Errorf("Column %q is null", r.name(index)))
+		}
+		return false
+	}
+	if err := convertAssign(reflect.ValueOf(dest).Elem(), v); err != nil {
+		r.errs = append(r.errs, fmt.Errorf("Column %q: %v", r.name(index), err))
+		return false
+	}
+	return true
+}
+
+// String returns the named column as a string.
+func (r *DataRow) String(name string) string {
+	return r.Stringx(r.index(name))
+}
+
+// Stringx returns the column at index as a string.
+func (r *DataRow) Stringx(index int) string {
+	var v string
+	r.convert(index, &v, false)
+	return v
+}
+
+// Int64 returns the named column as an int64.
+func (r *DataRow) Int64(name string) int64 {
+	return r.Int64x(r.index(name))
+}
+
+// Int64x returns the column at index as an int64.
+func (r *DataRow) Int64x(index int) int64 {
+	var v int64
+	r.convert(index, &v, false)
+	return v
+}
+
+// Float64 returns the named column as a float64.
+func (r *DataRow) Float64(name string) float64 {
+	return r.Float64x(r.index(name))
+}
+
+// Float64x returns the column at index as a float64.
+func (r *DataRow) Float64x(index int) float64 {
+	var v float64
+	r.convert(index, &v, false)
+	return v
+}
+
+// Bool returns the named column as a bool.
+func (r *DataRow) Bool(name string) bool {
+	return r.Boolx(r.index(name))
+}
+
+// Boolx returns the column at index as a bool.
+func (r *DataRow) Boolx(index int) bool {
+	var v bool
+	r.convert(index, &v, false)
+	return v
+}
+
+// Time returns the named column as a time.
+func (r *DataRow) Time(name string) time.Time {
+	return r.Timex(r.index(name))
+}
+
+// Timex returns the column at index as a time.
+func (r *DataRow) Timex(index int) time.Time {
+	var v time.Time
+	r.convert(index, &v, false)
+	return v
+}
+
+// Bytes returns the named column as bytes. A null value returns nil.
+func (r *DataRow) Bytes(name string) []byte {
+	return r.Bytesx(r.index(name))
+}
+
+// Bytesx returns the column at index as bytes. A null value returns nil.
+func (r *DataRow) Bytesx(index int) []byte {
+	var v []byte
+	r.convert(index, &v, true)
+	return v
+}
+
+// Numeric returns the named column as a decimal number.
+func (r *DataRow) Numeric(name string) Numeric {
+	return r.Numericx(r.index(name))
+}
+
+// Numericx returns the column at index as a decimal number.
+func (r *DataRow) Numericx(index int) Numeric {
+	var v Numeric
+	r.convert(index, &v, false)
+	return v
+}
+
+// NullString returns the named column as a string or nil if null.
+func (r *DataRow) NullString(name string) *string {
+	return r.NullStringx(r.index(name))
+}
+
+// NullStringx returns the column at index as a string or nil if null.
+func (r *DataRow) NullStringx(index int) *string {
+	var v string
+	if !r.convert(index, &v, true) {
+		return nil
+	}
+	return &v
+}
+
+// NullInt64 returns the named column as an int64 or nil if null.
+func (r *DataRow) NullInt64(name string) *int64 {
+	return r.NullInt64x(r.index(name))
+}
+
+// NullInt64x returns the column at index as an int64 or nil if null.
+func (r *DataRow) NullInt64x(index int) *int64 {
+	var v int64
+	if !r.convert(index, &v, true) {
+		return nil
+	}
+	return &v
+}
+
+// NullFloat64 returns the named column as a float64 or nil if null.
+func (r *DataRow) NullFloat64(name string) *float64 {
+	return r.NullFloat64x(r.index(name))
+}
+
+// NullFloat64x returns the column at index as a float64 or nil if null.
+func (r *DataRow) NullFloat64x(index int) *float64 {
+	var v float64
+	if !r.convert(index, &v, true) {
+		return nil
+	}
+	return &v
+}
+
+// NullBool returns the named column as a bool or nil if null.
+func (r *DataRow) NullBool(name string) *bool {
+	return r.NullBoolx(r.index(name))
+}
+
+// NullBoolx returns the column at index as a bool or nil if null.
+func (r *DataRow) NullBoolx(index int) *bool {
+	var v bool
+	if !r.convert(index, &v, true) {
+		return nil
+	}
+	return &v
+}
+
+// NullTime returns the named column as a time or nil if null.
+func (r *DataRow) NullTime(name string) *time.Time {
+	return r.NullTimex(r.index(name))
+}
+
+// NullTimex returns the column at index as a time or nil if null.
+func (r *DataRow) NullTimex(index int) *time.Time {
+	var v time.Time
+	if !r.convert(index, &v, true) {
+		return nil
+	}
+	return &v
+}
+
+// NullNumeric returns the named column as a decimal number or nil if null.
+func (r *DataRow) NullNumeric(name string) *Numeric {
+	return r.NullNumericx(r.index(name))
+}
+
+// NullNumericx returns the column at index as a decimal number or nil if null.
+func (r *DataRow) NullNumericx(index int) *Numeric {
+	var v Numeric
+	if !r.convert(index, &v, true) {
+		return nil
+	}
+	return &v
+}
+
+// Assign sets the value pointed to by dest to src, converting between
+// compatible types. If dest implements sql.Scanner, its Scan method is used.
+// Drivers may use Assign to set values passed to Result.Prep.
+func Assign(dest, src interface{}) error {
+	if s, ok := dest.(sql.Scanner); ok {
+		return s.Scan(src)
+	}
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("Destination %T must be a non-nil pointer", dest)
+	}
+	return convertAssign(dv.Elem(), src)
+}
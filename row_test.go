@@ -0,0 +1,185 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+)
+
+var rowSchema = rdb.Schema{
+	{Name: "ID", Type: rdb.TypeInt64},
+	{Name: "Name", Type: rdb.TypeText},
+	{Name: "Price", Type: rdb.TypeDecimal},
+	{Name: "Active", Type: rdb.TypeBool},
+	{Name: "At", Type: rdb.TypeTimestamp},
+	{Name: "Data", Type: rdb.TypeBinary, Nullable: true},
+	{Name: "Note", Type: rdb.TypeText, Nullable: true},
+	{Name: "id", Type: rdb.TypeInt64},
+}
+
+var rowTime = time.Date(2016, 4, 5, 6, 7, 8, 0, time.UTC)
+
+func newRow() *rdb.DataRow {
+	return rdb.NewDataRow(rowSchema, []interface{}{int64(7), "ab", "1.50", int64(1), rowTime, []byte("x"), nil, int64(9)})
+}
+
+func TestDataRowIndex(t *testing.T) {
+	row := newRow()
+	list := []struct {
+		name string
+		want int
+	}{
+		{"ID", 0},
+		{"id", 7},
+		{"name", 1},
+		{"NOTE", 6},
+		{"Missing", -1},
+	}
+	for _, item := range list {
+		if got := row.Index(item.name); got != item.want {
+			t.Errorf("%s: got %d, want %d", item.name, got, item.want)
+		}
+	}
+	if err := row.Err(); err != nil {
+		t.Errorf("Index recorded an error: %v", err)
+	}
+}
+
+func TestDataRowGet(t *testing.T) {
+	row := newRow()
+	if v := row.Get("Name"); v != "ab" {
+		t.Errorf("Get: got %v", v)
+	}
+	if v := row.Getx(0); v != int64(7) {
+		t.Errorf("Getx: got %v", v)
+	}
+	if v := row.Get("Note"); v != nil {
+		t.Errorf("Get null: got %v", v)
+	}
+
+	var id int32
+	var name []byte
+	var note *string
+	var price rdb.Numeric
+	row.Into("ID", &id).Into("Name", &name).Into("Note", &note).Intox(2, &price)
+	if err := row.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if id != 7 || string(name) != "ab" || note != nil || price.String() != "1.50" {
+		t.Errorf("Into: got %d %q %v %s", id, name, note, price)
+	}
+}
+
+func TestDataRowAccessors(t *testing.T) {
+	row := newRow()
+	if v := row.String("Name"); v != "ab" {
+		t.Errorf("String: got %q", v)
+	}
+	if v := row.Stringx(0); v != "7" {
+		t.Errorf("Stringx: got %q", v)
+	}
+	if v := row.Int64("ID"); v != 7 {
+		t.Errorf("Int64: got %d", v)
+	}
+	if v := row.Float64("Price"); v != 1.5 {
+		t.Errorf("Float64: got %g", v)
+	}
+	if v := row.Bool("Active"); !v {
+		t.Errorf("Bool: got %t", v)
+	}
+	if v := row.Time("At"); !v.Equal(rowTime) {
+		t.Errorf("Time: got %v", v)
+	}
+	if v := row.Bytes("Data"); string(v) != "x" {
+		t.Errorf("Bytes: got %q", v)
+	}
+	if v := row.Bytes("Note"); v != nil {
+		t.Errorf("Bytes null: got %q", v)
+	}
+	if v := row.Numeric("Price"); v.Cmp(rdb.NewNumeric(15, 1)) != 0 {
+		t.Errorf("Numeric: got %s", v)
+	}
+	if v := row.NullString("Name"); v == nil || *v != "ab" {
+		t.Errorf("NullString: got %v", v)
+	}
+	if v := row.NullInt64("ID"); v == nil || *v != 7 {
+		t.Errorf("NullInt64: got %v", v)
+	}
+	if row.NullString("Note") != nil || row.NullInt64("Note") != nil || row.NullFloat64("Note") != nil ||
+		row.NullBool("Note") != nil || row.NullTime("Note") != nil || row.NullNumeric("Note") != nil {
+		t.Error("null accessor returned a value for a null column")
+	}
+	if err := row.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDataRowErr(t *testing.T) {
+	list := []struct {
+		name string
+		read func(row *rdb.DataRow)
+		want []string
+	}{
+		{"missing", func(row *rdb.DataRow) { row.String("Missing") }, []string{`Column "Missing" not found`}},
+		{"out of range", func(row *rdb.DataRow) { row.Getx(8) }, []string{"Column index 8 out of range"}},
+		{"null", func(row *rdb.DataRow) { row.Int64("Note") }, []string{`Column "Note" is null`}},
+		{"convert", func(row *rdb.DataRow) { row.Int64("Name") }, []string{`Column "Name": `}},
+		{"into", func(row *rdb.DataRow) {
+			var v bool
+			row.Into("Name", &v)
+		}, []string{`Column "Name": `}},
+		{"into not pointer", func(row *rdb.DataRow) { row.Intox(0, 5) }, []string{`Column "ID": Destination int must be a non-nil pointer`}},
+		{"several", func(row *rdb.DataRow) {
+			row.Int64("Missing")
+			row.Bool("Name")
+			row.NullInt64("Name")
+		}, []string{`Column "Missing" not found`, `Column "Name": `, `Column "Name": `}},
+	}
+	for _, item := range list {
+		row := newRow()
+		item.read(row)
+		err := row.Err()
+		if err == nil {
+			t.Errorf("%s: expected error", item.name)
+			continue
+		}
+		var got []error
+		if l, ok := err.(rdb.ErrorList); ok {
+			got = l.List
+		} else {
+			got = []error{err}
+		}
+		if len(got) != len(item.want) {
+			t.Errorf("%s: got %d errors %v, want %d", item.name, len(got), err, len(item.want))
+			continue
+		}
+		for i, want := range item.want {
+			if !strings.HasPrefix(got[i].Error(), want) {
+				t.Errorf("%s: got %q, want prefix %q", item.name, got[i], want)
+			}
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	var n int16
+	if err := rdb.Assign(&n, "12"); err != nil || n != 12 {
+		t.Errorf("got %d, %v", n, err)
+	}
+	if err := rdb.Assign(&n, int64(1<<20)); err == nil {
+		t.Error("overflow: expected error")
+	}
+	var text rdb.NullText
+	if err := rdb.Assign(&text, "a"); err != nil || text != (rdb.NullText{String: "a", Valid: true}) {
+		t.Errorf("scanner: got %+v, %v", text, err)
+	}
+	if err := rdb.Assign((*int)(nil), 1); err == nil {
+		t.Error("nil pointer: expected error")
+	}
+}
@@ -4,9 +4,27 @@
 
 package rdb
 
+import "strings"
+
 // Schema is a list of columns and related methods.
 type Schema []Column
 
+// Index returns the index of the named column or -1 if not found.
+// Names are matched exactly first, then without regard to case.
+func (s Schema) Index(name string) int {
+	for i := range s {
+		if s[i].Name == name {
+			return i
+		}
+	}
+	for i := range s {
+		if strings.EqualFold(s[i].Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
 // Column information as reported by the database.
 type Column struct {
 	Name    string // Columnn name.
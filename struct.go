@@ -0,0 +1,264 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Struct fields are mapped to columns by the "rdb" field tag:
+//
+//	type Log struct {
+//		ID      int64  `rdb:"ID"`
+//		Message string `rdb:"Message"`
+//		Note    *string                  // Mapped to column "Note".
+//		Extra   string `rdb:",optional"` // Not an error if missing from the result.
+//		Ignored string `rdb:"-"`
+//	}
+//
+// Exported fields without a tag are mapped to a column with the field name.
+// Column names are matched exactly first, then without regard to case.
+// Fields of embedded structs are mapped as if they were in the outer struct.
+//
+// A result column that is nullable must map to a pointer, interface,
+// or sql.Scanner field, such as NullText or NullInteger. Values are
+// converted between compatible types; numbers are range checked.
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+type structField struct {
+	name     string
+	index    []int
+	optional bool
+	typ      reflect.Type
+}
+
+type structInfo struct {
+	name   string
+	fields []structField
+}
+
+var (
+	structSync  sync.RWMutex
+	structCache = make(map[reflect.Type]*structInfo)
+)
+
+// structOf returns the field information for the struct type.
+func structOf(t reflect.Type) *structInfo {
+	structSync.RLock()
+	info, found := structCache[t]
+	structSync.RUnlock()
+	if found {
+		return info
+	}
+	info = &structInfo{name: t.String()}
+	addFields(info, t, nil)
+
+	structSync.Lock()
+	structCache[t] = info
+	structSync.Unlock()
+	return info
+}
+
+func addFields(info *structInfo, t reflect.Type, index []int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("rdb")
+		if tag == "-" {
+			continue
+		}
+		fieldIndex := append(index[:len(index):len(index)], i)
+		if f.Anonymous && len(tag) == 0 {
+			ft := f.Type
+			if ft.Kind() == reflect.Struct && ft != timeType && !reflect.PtrTo(ft).Implements(scannerType) {
+				addFields(info, ft, fieldIndex)
+				continue
+			}
+		}
+		if len(f.PkgPath) != 0 {
+			// Unexported field.
+			continue
+		}
+		name := f.Name
+		var optional bool
+		if len(tag) > 0 {
+			parts := strings.Split(tag, ",")
+			if len(parts[0]) > 0 {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "optional" {
+					optional = true
+				}
+			}
+		}
+		info.fields = append(info.fields, structField{
+			name:     name,
+			index:    fieldIndex,
+			optional: optional,
+			typ:      f.Type,
+		})
+	}
+}
+
+// canBeNull returns true if the type may hold a null value.
+func canBeNull(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return true
+	}
+	return reflect.PtrTo(t).Implements(scannerType)
+}
+
+// structMap maps the columns of a schema to struct fields.
+type structMap struct {
+	info   *structInfo
+	column []int // Column index for each field or -1 if not in the schema.
+	schema Schema
+}
+
+// newStructMap returns the mapping from the schema to the struct type.
+// All schema mismatches are reported.
+func newStructMap(t reflect.Type, schema Schema) (*structMap, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Cannot map columns into %s, must be a struct", t)
+	}
+	m := &structMap{
+		info:   structOf(t),
+		schema: schema,
+	}
+	m.column = make([]int, len(m.info.fields))
+	var list []error
+	for i, f := range m.info.fields {
+		ci := schema.Index(f.name)
+		m.column[i] = ci
+		if ci < 0 {
+			if !f.optional {
+				list = append(list, fmt.Errorf("Column %q for field %s.%s not found in result", f.name, m.info.name, fieldName(t, f.index)))
+			}
+			continue
+		}
+		if schema[ci].Nullable && !canBeNull(f.typ) {
+			list = append(list, fmt.Errorf("Nullable column %q mapped to non-pointer field %s.%s of type %s", schema[ci].Name, m.info.name, fieldName(t, f.index), f.typ))
+		}
+	}
+	switch len(list) {
+	case 0:
+		return m, nil
+	case 1:
+		return nil, list[0]
+	}
+	return nil, ErrorList{List: list}
+}
+
+func fieldName(t reflect.Type, index []int) string {
+	return t.FieldByIndex(index).Name
+}
+
+// fill sets the struct fields of v from the row.
+func (m *structMap) fill(v reflect.Value, row Row) error {
+	for i, f := range m.info.fields {
+		ci := m.column[i]
+		if ci < 0 {
+			continue
+		}
+		fv := v.FieldByIndex(f.index)
+		if err := convertAssign(fv, row.Getx(ci)); err != nil {
+			return fmt.Errorf("Column %q into field %s: %v", m.schema[ci].Name, m.info.name+"."+fieldName(v.Type(), f.index), err)
+		}
+	}
+	return nil
+}
+
+// structValue returns the struct value dest points to.
+func structValue(dest interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return reflect.Value{}, fmt.Errorf("Destination %T must be a non-nil pointer to a struct", dest)
+	}
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("Destination %T must be a non-nil pointer to a struct", dest)
+	}
+	return v, nil
+}
+
+// IntoStruct sets the fields of the struct pointed to by dest from the row.
+func IntoStruct(schema Schema, row Row, dest interface{}) error {
+	v, err := structValue(dest)
+	if err != nil {
+		return err
+	}
+	m, err := newStructMap(v.Type(), schema)
+	if err != nil {
+		return err
+	}
+	return m.fill(v, row)
+}
+
+// Into appends each row of the buffer to the slice pointed to by dest.
+// The slice element must be a struct or a pointer to a struct.
+func (b *Buffer) Into(dest interface{}) error {
+	sv := reflect.ValueOf(dest)
+	if sv.Kind() != reflect.Ptr || sv.IsNil() || sv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("Destination %T must be a non-nil pointer to a slice", dest)
+	}
+	sv = sv.Elem()
+	et := sv.Type().Elem()
+	isPtr := et.Kind() == reflect.Ptr
+	st := et
+	if isPtr {
+		st = et.Elem()
+	}
+	m, err := newStructMap(st, b.Schema)
+	if err != nil {
+		return err
+	}
+	for _, row := range b.Row {
+		item := reflect.New(st)
+		if err := m.fill(item.Elem(), row); err != nil {
+			return err
+		}
+		if isPtr {
+			sv.Set(reflect.Append(sv, item))
+		} else {
+			sv.Set(reflect.Append(sv, item.Elem()))
+		}
+	}
+	return nil
+}
+
+// EachStruct reads each row of the result into the struct pointed to by
+// dest, then calls f. If f returns an error, EachStruct stops and returns it.
+// The struct is not reset between rows.
+func EachStruct(res Result, dest interface{}, f func() error) error {
+	v, err := structValue(dest)
+	if err != nil {
+		return err
+	}
+	m, err := newStructMap(v.Type(), res.Schema())
+	if err != nil {
+		return err
+	}
+	for {
+		row, err := res.Scan()
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			return nil
+		}
+		if err = m.fill(v, row); err != nil {
+			return err
+		}
+		if err = f(); err != nil {
+			return err
+		}
+	}
+}
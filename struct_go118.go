@@ -0,0 +1,29 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package rdb
+
+// BufferAs returns the rows of the buffer as a list of T.
+// T must be a struct or a pointer to a struct.
+func BufferAs[T any](buf *Buffer) ([]T, error) {
+	list := make([]T, 0, len(buf.Row))
+	if err := buf.Into(&list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ResultEach reads each row of the result into a new T and calls f.
+// T must be a struct. If f returns an error, ResultEach stops and returns it.
+func ResultEach[T any](res Result, f func(T) error) error {
+	var item T
+	return EachStruct(res, &item, func() error {
+		v := item
+		item = *new(T)
+		return f(v)
+	})
+}
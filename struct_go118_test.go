@@ -0,0 +1,65 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package rdb_test
+
+import (
+	"testing"
+
+	"github.com/kardianos/rdb"
+)
+
+func TestBufferAs(t *testing.T) {
+	list, err := rdb.BufferAs[logRow](newLogBuffer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLogRows(t, "BufferAs", list)
+
+	ptrs, err := rdb.BufferAs[*logRow](newLogBuffer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	list = list[:0]
+	for _, p := range ptrs {
+		list = append(list, *p)
+	}
+	checkLogRows(t, "BufferAs pointer", list)
+
+	if _, err = rdb.BufferAs[int](newLogBuffer()); err == nil {
+		t.Error("BufferAs int: expected error")
+	}
+}
+
+func TestResultEach(t *testing.T) {
+	var list []logRow
+	err := rdb.ResultEach(&bufferResult{buf: newLogBuffer()}, func(item logRow) error {
+		list = append(list, item)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLogRows(t, "ResultEach", list)
+
+	// Each row is read into a new value.
+	buf := newBuffer(logSchema,
+		[]interface{}{int64(1), "a", "x", int64(1)},
+		[]interface{}{int64(2), "b", nil, int64(2)},
+	)
+	var notes []*string
+	err = rdb.ResultEach(&bufferResult{buf: buf}, func(item logRow) error {
+		notes = append(notes, item.Note)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 2 || notes[0] == nil || *notes[0] != "x" || notes[1] != nil {
+		t.Errorf("got notes %v", notes)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kardianos/rdb"
+)
+
+// newBuffer returns a buffer with a DataRow for each of rows.
+func newBuffer(schema rdb.Schema, rows ...[]interface{}) *rdb.Buffer {
+	buf := &rdb.Buffer{Schema: schema}
+	for _, values := range rows {
+		buf.Row = append(buf.Row, rdb.NewDataRow(schema, values))
+	}
+	return buf
+}
+
+// bufferResult is a Result that reads the rows of a Buffer.
+type bufferResult struct {
+	buf *rdb.Buffer
+	pos int
+}
+
+func (r *bufferResult) Prep(name string, value interface{}) rdb.Result { return r }
+func (r *bufferResult) Prepx(index int, value interface{}) rdb.Result  { return r }
+func (r *bufferResult) Schema() rdb.Schema                             { return r.buf.Schema }
+func (r *bufferResult) Close() error                                   { return nil }
+
+func (r *bufferResult) Scan() (rdb.Row, error) {
+	if r.pos >= len(r.buf.Row) {
+		return nil, nil
+	}
+	r.pos++
+	return r.buf.Row[r.pos-1], nil
+}
+
+var logSchema = rdb.Schema{
+	{Name: "id", Type: rdb.TypeInt64},
+	{Name: "msg", Type: rdb.TypeText},
+	{Name: "note", Type: rdb.TypeText, Nullable: true},
+	{Name: "N", Type: rdb.TypeInt32},
+}
+
+type logBase struct {
+	ID int64 `rdb:"id"`
+}
+
+type logRow struct {
+	logBase
+	Message string `rdb:"msg"`
+	Note    *string
+	Count   uint8        `rdb:"n"`
+	Extra   string       `rdb:",optional"`
+	Skip    string       `rdb:"-"`
+	Null    rdb.NullText `rdb:"note"`
+	private int
+}
+
+func newLogBuffer() *rdb.Buffer {
+	return newBuffer(logSchema,
+		[]interface{}{int64(1), "a", nil, int64(5)},
+		[]interface{}{int64(2), "b", "x", int32(200)},
+	)
+}
+
+func checkLogRows(t *testing.T, name string, list []logRow) {
+	t.Helper()
+	if len(list) != 2 {
+		t.Fatalf("%s: got %d rows, want 2", name, len(list))
+	}
+	a, b := list[0], list[1]
+	if a.ID != 1 || a.Message != "a" || a.Note != nil || a.Count != 5 || a.Null.Valid {
+		t.Errorf("%s: got first row %+v", name, a)
+	}
+	if b.ID != 2 || b.Message != "b" || b.Note == nil || *b.Note != "x" || b.Count != 200 || b.Null != (rdb.NullText{String: "x", Valid: true}) {
+		t.Errorf("%s: got second row %+v", name, b)
+	}
+}
+
+func TestIntoStruct(t *testing.T) {
+	buf := newLogBuffer()
+	var list []logRow
+	for _, row := range buf.Row {
+		item := logRow{Skip: "keep"}
+		if err := rdb.IntoStruct(buf.Schema, row, &item); err != nil {
+			t.Fatal(err)
+		}
+		if item.Skip != "keep" {
+			t.Errorf("ignored field set to %q", item.Skip)
+		}
+		list = append(list, item)
+	}
+	checkLogRows(t, "IntoStruct", list)
+}
+
+func TestBufferInto(t *testing.T) {
+	buf := newLogBuffer()
+
+	var list []logRow
+	if err := buf.Into(&list); err != nil {
+		t.Fatal(err)
+	}
+	checkLogRows(t, "struct", list)
+
+	var ptrs []*logRow
+	if err := buf.Into(&ptrs); err != nil {
+		t.Fatal(err)
+	}
+	list = list[:0]
+	for _, p := range ptrs {
+		list = append(list, *p)
+	}
+	checkLogRows(t, "pointer", list)
+}
+
+func TestEachStruct(t *testing.T) {
+	var item logRow
+	var list []logRow
+	err := rdb.EachStruct(&bufferResult{buf: newLogBuffer()}, &item, func() error {
+		list = append(list, item)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLogRows(t, "EachStruct", list)
+
+	stop := errors.New("stop")
+	var n int
+	err = rdb.EachStruct(&bufferResult{buf: newLogBuffer()}, &item, func() error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("got %v after %d rows, want %v after 1", err, n, stop)
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	type missing struct {
+		ID   int64
+		Gone int
+	}
+	type nullable struct {
+		Note string `rdb:"note"`
+	}
+	type several struct {
+		Note string `rdb:"note"`
+		Gone int
+	}
+	type small struct {
+		N int8
+	}
+	type text struct {
+		N bool
+	}
+	notStruct := 5
+
+	list := []struct {
+		name string
+		dest interface{}
+		want string
+	}{
+		{"missing column", &[]missing{}, `Column "Gone" for field rdb_test.missing.Gone not found in result`},
+		{"nullable column", &[]nullable{}, `Nullable column "note" mapped to non-pointer field rdb_test.nullable.Note of type string`},
+		{"several", &[]several{}, "Nullable column \"note\" mapped to non-pointer field rdb_test.several.Note of type string\nColumn \"Gone\" for field rdb_test.several.Gone not found in result"},
+		{"out of range", &[]small{}, `Column "N" into field rdb_test.small.N`},
+		{"not convertible", &[]text{}, `Column "N" into field rdb_test.text.N`},
+		{"not a slice", &notStruct, "must be a non-nil pointer to a slice"},
+		{"nil", (*[]logRow)(nil), "must be a non-nil pointer to a slice"},
+		{"not a struct", &[]int{}, "Cannot map columns into int, must be a struct"},
+	}
+	for _, item := range list {
+		err := newLogBuffer().Into(item.dest)
+		if err == nil {
+			t.Errorf("%s: expected error", item.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), item.want) {
+			t.Errorf("%s: got %q, want %q", item.name, err, item.want)
+		}
+	}
+
+	buf := newLogBuffer()
+	for _, dest := range []interface{}{logRow{}, &notStruct, (*logRow)(nil)} {
+		if err := rdb.IntoStruct(buf.Schema, buf.Row[0], dest); err == nil {
+			t.Errorf("IntoStruct %T: expected error", dest)
+		}
+		if err := rdb.EachStruct(&bufferResult{buf: buf}, dest, func() error { return nil }); err == nil {
+			t.Errorf("EachStruct %T: expected error", dest)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var typeNames = map[Type]string{
+	TypeUnknown: "TypeUnknown",
+
+	Text:    "Text",
+	Binary:  "Binary",
+	Bool:    "Bool",
+	Integer: "Integer",
+	Float:   "Float",
+	Decimal: "Decimal",
+	Time:    "Time",
+	Other:   "Other",
+
+	TypeText:        "TypeText",
+	TypeAnsiText:    "TypeAnsiText",
+	TypeVarChar:     "TypeVarChar",
+	TypeAnsiVarChar: "TypeAnsiVarChar",
+	TypeChar:        "TypeChar",
+	TypeAnsiChar:    "TypeAnsiChar",
+	TypeBinary:      "TypeBinary",
+	TypeBool:        "TypeBool",
+	TypeUint8:       "TypeUint8",
+	TypeUint16:      "TypeUint16",
+	TypeUint32:      "TypeUint32",
+	TypeUint64:      "TypeUint64",
+	TypeInt8:        "TypeInt8",
+	TypeInt16:       "TypeInt16",
+	TypeInt32:       "TypeInt32",
+	TypeInt64:       "TypeInt64",
+	TypeSerial16:    "TypeSerial16",
+	TypeSerial32:    "TypeSerial32",
+	TypeSerial64:    "TypeSerial64",
+	TypeFloat32:     "TypeFloat32",
+	TypeFloat64:     "TypeFloat64",
+	TypeDecimal:     "TypeDecimal",
+	TypeMoney:       "TypeMoney",
+	TypeTimestampz:  "TypeTimestampz",
+	TypeDuration:    "TypeDuration",
+	TypeTime:        "TypeTime",
+	TypeDate:        "TypeDate",
+	TypeTimestamp:   "TypeTimestamp",
+	TypeUUID:        "TypeUUID",
+	TypeEnum:        "TypeEnum",
+	TypeRange:       "TypeRange",
+	TypeArray:       "TypeArray",
+	TypeJSON:        "TypeJSON",
+	TypeXML:         "TypeXML",
+	TypeTable:       "TypeTable",
+}
+
+// standardOfGeneric is the standard type used for each generic type.
+var standardOfGeneric = map[Type]Type{
+	Text:    TypeText,
+	Binary:  TypeBinary,
+	Bool:    TypeBool,
+	Integer: TypeInt64,
+	Float:   TypeFloat64,
+	Decimal: TypeDecimal,
+	Time:    TypeTimestampz,
+}
+
+// TypeInfo describes a driver defined type.
+type TypeInfo struct {
+	Type Type   // Driver type, at least TypeDriverThresh.
+	Name string // Name within the driver namespace, such as "hstore".
+
+	// Standard is the closest standard type or TypeUnknown.
+	Standard Type
+
+	// Generic is the generic type. If zero, the generic type of
+	// Standard is used.
+	Generic Type
+}
+
+type driverType struct {
+	namespace string
+	info      TypeInfo
+}
+
+var (
+	typeSync       sync.RWMutex
+	typeNamespaces = make(map[string]bool)
+	typeByValue    = make(map[Type]*driverType)
+	typeByName     = make(map[string]Type) // Key is lower case "namespace.name".
+)
+
+// RegisterTypes registers the types of a driver under namespace.
+// Driver types are named "namespace.name", such as "pq.hstore".
+// An error is returned if the namespace is already registered or a type
+// value is already registered by another namespace; nothing is registered.
+func RegisterTypes(namespace string, types ...TypeInfo) error {
+	if len(namespace) == 0 || strings.ContainsAny(namespace, ". \t\n") {
+		return fmt.Errorf("Invalid type namespace %q", namespace)
+	}
+	typeSync.Lock()
+	defer typeSync.Unlock()
+
+	if typeNamespaces[namespace] {
+		return fmt.Errorf("Type namespace %q already registered", namespace)
+	}
+	add := make(map[Type]*driverType, len(types))
+	names := make(map[string]Type, len(types))
+	for _, info := range types {
+		if !info.Type.Driver() {
+			return fmt.Errorf("Type %s.%s value %d is less than TypeDriverThresh", namespace, info.Name, info.Type)
+		}
+		if len(info.Name) == 0 || strings.ContainsAny(info.Name, ".()") {
+			return fmt.Errorf("Invalid type name %q in namespace %q", info.Name, namespace)
+		}
+		if info.Standard.Driver() || info.Standard.Generic() {
+			return fmt.Errorf("Type %s.%s standard type must be a standard type", namespace, info.Name)
+		}
+		if info.Generic == TypeUnknown {
+			info.Generic = info.Standard.GenericOf()
+		}
+		if !info.Generic.Generic() && info.Generic != TypeUnknown {
+			return fmt.Errorf("Type %s.%s generic type must be a generic type", namespace, info.Name)
+		}
+		if dt, found := typeByValue[info.Type]; found {
+			return fmt.Errorf("Type %s.%s value %d already registered as %s.%s", namespace, info.Name, info.Type, dt.namespace, dt.info.Name)
+		}
+		if _, found := add[info.Type]; found {
+			return fmt.Errorf("Type %s.%s value %d registered twice", namespace, info.Name, info.Type)
+		}
+		key := strings.ToLower(namespace + "." + info.Name)
+		if _, found := names[key]; found {
+			return fmt.Errorf("Type %s.%s registered twice", namespace, info.Name)
+		}
+		add[info.Type] = &driverType{namespace: namespace, info: info}
+		names[key] = info.Type
+	}
+	typeNamespaces[namespace] = true
+	for t, dt := range add {
+		typeByValue[t] = dt
+	}
+	for key, t := range names {
+		typeByName[key] = t
+	}
+	return nil
+}
+
+func lookupDriverType(t Type) (*driverType, bool) {
+	typeSync.RLock()
+	dt, found := typeByValue[t]
+	typeSync.RUnlock()
+	return dt, found
+}
+
+// String returns the name of the type. Standard and generic types are
+// named as their constant, such as "TypeInt32" or "Integer". Registered
+// driver types are named "namespace.name". Other types are "Type(n)".
+func (t Type) String() string {
+	if name, found := typeNames[t]; found {
+		return name
+	}
+	if dt, found := lookupDriverType(t); found {
+		return dt.namespace + "." + dt.info.Name
+	}
+	return "Type(" + strconv.FormatUint(uint64(t), 10) + ")"
+}
+
+// ParseType returns the type for a name returned by Type.String.
+// Names are matched without regard to case.
+func ParseType(name string) (Type, error) {
+	s := strings.TrimSpace(name)
+	for t, n := range typeNames {
+		if strings.EqualFold(n, s) {
+			return t, nil
+		}
+	}
+	if strings.HasPrefix(s, "Type(") && strings.HasSuffix(s, ")") {
+		n, err := strconv.ParseUint(s[5:len(s)-1], 10, 32)
+		if err == nil {
+			return Type(n), nil
+		}
+	}
+	typeSync.RLock()
+	t, found := typeByName[strings.ToLower(s)]
+	typeSync.RUnlock()
+	if found {
+		return t, nil
+	}
+	return TypeUnknown, fmt.Errorf("Unknown type %q", name)
+}
+
+// Standard returns the standard type for t. Standard types return
+// themselves. Generic types return a standard type that can hold any
+// value of the generic type, and registered driver types return their
+// registered standard type. Other types return TypeUnknown.
+func (t Type) Standard() Type {
+	switch {
+	case t.Driver():
+		if dt, found := lookupDriverType(t); found {
+			return dt.info.Standard
+		}
+		return TypeUnknown
+	case t.Generic():
+		return standardOfGeneric[t]
+	}
+	if _, found := typeNames[t]; found {
+		return t
+	}
+	return TypeUnknown
+}
+
+// GenericOf returns the generic type for t. Generic types return
+// themselves and registered driver types return their registered
+// generic type. Types without a generic type return Other.
+func (t Type) GenericOf() Type {
+	switch {
+	case t == TypeUnknown:
+		return TypeUnknown
+	case t.Generic():
+		return t
+	case t.Driver():
+		if dt, found := lookupDriverType(t); found && dt.info.Generic != TypeUnknown {
+			return dt.info.Generic
+		}
+		return Other
+	case t >= TypeText && t <= TypeAnsiChar:
+		return Text
+	case t == TypeBinary:
+		return Binary
+	case t == TypeBool:
+		return Bool
+	case t >= TypeUint8 && t <= TypeSerial64:
+		return Integer
+	case t == TypeFloat32 || t == TypeFloat64:
+		return Float
+	case t == TypeDecimal || t == TypeMoney:
+		return Decimal
+	case t >= TypeTimestampz && t <= TypeTimestamp:
+		return Time
+	}
+	return Other
+}
+
+// MarshalText implements encoding.TextMarshaler using the type name.
+func (t Type) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseType.
+func (t *Type) UnmarshalText(text []byte) error {
+	v, err := ParseType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kardianos/rdb"
+)
+
+const (
+	testHstore = rdb.TypeDriverThresh + 100 + iota
+	testPoint
+	testTag
+	testUnregistered
+)
+
+func init() {
+	err := rdb.RegisterTypes("rdbtest",
+		rdb.TypeInfo{Type: testHstore, Name: "hstore", Standard: rdb.TypeText},
+		rdb.TypeInfo{Type: testPoint, Name: "Point"},
+		rdb.TypeInfo{Type: testTag, Name: "tag", Standard: rdb.TypeText, Generic: rdb.Binary},
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestTypeString(t *testing.T) {
+	list := []struct {
+		t    rdb.Type
+		name string
+	}{
+		{rdb.TypeUnknown, "TypeUnknown"},
+		{rdb.Integer, "Integer"},
+		{rdb.TypeInt32, "TypeInt32"},
+		{rdb.TypeXML, "TypeXML"},
+		{testHstore, "rdbtest.hstore"},
+		{testPoint, "rdbtest.Point"},
+		{testUnregistered, "Type(65639)"},
+		{rdb.Type(900), "Type(900)"},
+	}
+	for _, item := range list {
+		if got := item.t.String(); got != item.name {
+			t.Errorf("%d: got %q, want %q", item.t, got, item.name)
+		}
+		got, err := rdb.ParseType(item.name)
+		if err != nil {
+			t.Errorf("%q: %v", item.name, err)
+			continue
+		}
+		if got != item.t {
+			t.Errorf("%q: got %d, want %d", item.name, got, item.t)
+		}
+	}
+}
+
+func TestParseType(t *testing.T) {
+	list := []struct {
+		name string
+		want rdb.Type
+	}{
+		{"typeint64", rdb.TypeInt64},
+		{" TypeText ", rdb.TypeText},
+		{"DECIMAL", rdb.Decimal},
+		{"RDBTEST.HSTORE", testHstore},
+		{"rdbtest.point", testPoint},
+		{"Type(1040)", rdb.Type(1040)},
+	}
+	for _, item := range list {
+		got, err := rdb.ParseType(item.name)
+		if err != nil {
+			t.Errorf("%q: %v", item.name, err)
+			continue
+		}
+		if got != item.want {
+			t.Errorf("%q: got %v, want %v", item.name, got, item.want)
+		}
+	}
+	for _, bad := range []string{"", "Int", "TypeInt128", "rdbtest.missing", "hstore", "Type(x)", "Type(-1)", "Type(4294967296)"} {
+		if got, err := rdb.ParseType(bad); err == nil {
+			t.Errorf("%q: expected error, got %v", bad, got)
+		}
+	}
+}
+
+func TestTypeStandardGeneric(t *testing.T) {
+	list := []struct {
+		t        rdb.Type
+		standard rdb.Type
+		generic  rdb.Type
+	}{
+		{rdb.TypeUnknown, rdb.TypeUnknown, rdb.TypeUnknown},
+		{rdb.Text, rdb.TypeText, rdb.Text},
+		{rdb.Integer, rdb.TypeInt64, rdb.Integer},
+		{rdb.Time, rdb.TypeTimestampz, rdb.Time},
+		{rdb.Other, rdb.TypeUnknown, rdb.Other},
+		{rdb.TypeAnsiChar, rdb.TypeAnsiChar, rdb.Text},
+		{rdb.TypeBinary, rdb.TypeBinary, rdb.Binary},
+		{rdb.TypeBool, rdb.TypeBool, rdb.Bool},
+		{rdb.TypeUint8, rdb.TypeUint8, rdb.Integer},
+		{rdb.TypeSerial64, rdb.TypeSerial64, rdb.Integer},
+		{rdb.TypeFloat32, rdb.TypeFloat32, rdb.Float},
+		{rdb.TypeMoney, rdb.TypeMoney, rdb.Decimal},
+		{rdb.TypeDate, rdb.TypeDate, rdb.Time},
+		{rdb.TypeUUID, rdb.TypeUUID, rdb.Other},
+		{rdb.TypeJSON, rdb.TypeJSON, rdb.Other},
+		{testHstore, rdb.TypeText, rdb.Text},
+		{testPoint, rdb.TypeUnknown, rdb.Other},
+		{testTag, rdb.TypeText, rdb.Binary},
+		{testUnregistered, rdb.TypeUnknown, rdb.Other},
+		{rdb.Type(2000), rdb.TypeUnknown, rdb.Other},
+	}
+	for _, item := range list {
+		if got := item.t.Standard(); got != item.standard {
+			t.Errorf("%v: got standard %v, want %v", item.t, got, item.standard)
+		}
+		if got := item.t.GenericOf(); got != item.generic {
+			t.Errorf("%v: got generic %v, want %v", item.t, got, item.generic)
+		}
+	}
+}
+
+func TestRegisterTypesError(t *testing.T) {
+	list := []struct {
+		name      string
+		namespace string
+		types     []rdb.TypeInfo
+	}{
+		{"empty namespace", "", nil},
+		{"dotted namespace", "a.b", nil},
+		{"registered namespace", "rdbtest", nil},
+		{"standard value", "rdbtest1", []rdb.TypeInfo{{Type: rdb.TypeText, Name: "a"}}},
+		{"empty name", "rdbtest2", []rdb.TypeInfo{{Type: rdb.TypeDriverThresh + 200}}},
+		{"dotted name", "rdbtest3", []rdb.TypeInfo{{Type: rdb.TypeDriverThresh + 200, Name: "a.b"}}},
+		{"generic standard", "rdbtest4", []rdb.TypeInfo{{Type: rdb.TypeDriverThresh + 200, Name: "a", Standard: rdb.Text}}},
+		{"standard generic", "rdbtest5", []rdb.TypeInfo{{Type: rdb.TypeDriverThresh + 200, Name: "a", Generic: rdb.TypeText}}},
+		{"taken value", "rdbtest6", []rdb.TypeInfo{{Type: testHstore, Name: "a"}}},
+		{"value twice", "rdbtest7", []rdb.TypeInfo{{Type: rdb.TypeDriverThresh + 200, Name: "a"}, {Type: rdb.TypeDriverThresh + 200, Name: "b"}}},
+		{"name twice", "rdbtest8", []rdb.TypeInfo{{Type: rdb.TypeDriverThresh + 200, Name: "a"}, {Type: rdb.TypeDriverThresh + 201, Name: "A"}}},
+	}
+	for _, item := range list {
+		if err := rdb.RegisterTypes(item.namespace, item.types...); err == nil {
+			t.Errorf("%s: expected error", item.name)
+		}
+	}
+	// Nothing is registered after an error.
+	if s := rdb.Type(rdb.TypeDriverThresh + 200).String(); s != "Type(65736)" {
+		t.Errorf("type registered as %s", s)
+	}
+	for _, name := range []string{"rdbtest7.a", "rdbtest8.a"} {
+		if _, err := rdb.ParseType(name); err == nil {
+			t.Errorf("%s registered", name)
+		}
+	}
+}
+
+func TestTypeText(t *testing.T) {
+	var v struct {
+		A, B rdb.Type
+	}
+	if err := json.Unmarshal([]byte(`{"A":"typedate","B":"rdbtest.hstore"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != rdb.TypeDate || v.B != testHstore {
+		t.Errorf("got %v and %v", v.A, v.B)
+	}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) != `{"A":"TypeDate","B":"rdbtest.hstore"}` {
+		t.Errorf("got %s, %v", data, err)
+	}
+	if err = json.Unmarshal([]byte(`{"A":"nope"}`), &v); err == nil {
+		t.Error("expected error for an unknown type")
+	}
+}
@@ -17,6 +17,7 @@ const (
 // Values over TypeDriverThresh establish thier own namespace for types.
 // Driver types are often limited to 16 bits so that leaves enough space Open
 // for more then one type spaces or user types.
+// Drivers register their types and namespace with RegisterTypes.
 type Type uint32
 
 // Driver returns true if this is a driver defined type.
@@ -0,0 +1,122 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// UUID is a universally unique identifier for TypeUUID values.
+// The bytes are in RFC 4122 order. The text form is
+// "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx".
+type UUID [16]byte
+
+// ParseUUID parses the text form of a UUID. Hex digits may be upper or
+// lower case. Braces, a "urn:uuid:" prefix, and missing dashes are accepted.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	text := strings.TrimPrefix(strings.TrimSpace(s), "urn:uuid:")
+	if len(text) > 2 && text[0] == '{' && text[len(text)-1] == '}' {
+		text = text[1 : len(text)-1]
+	}
+	if len(text) == 36 {
+		if text[8] != '-' || text[13] != '-' || text[18] != '-' || text[23] != '-' {
+			return u, fmt.Errorf("Invalid UUID %q", s)
+		}
+		text = text[:8] + text[9:13] + text[14:18] + text[19:23] + text[24:]
+	}
+	if len(text) != 32 {
+		return u, fmt.Errorf("Invalid UUID %q", s)
+	}
+	if _, err := hex.Decode(u[:], []byte(text)); err != nil {
+		return u, fmt.Errorf("Invalid UUID %q", s)
+	}
+	return u, nil
+}
+
+// String returns the text form of the UUID in lower case.
+func (u UUID) String() string {
+	b, _ := u.MarshalText()
+	return string(b)
+}
+
+// SwapGUID returns the UUID with the first three groups byte swapped.
+// Microsoft GUIDs store these groups in little-endian order.
+func (u UUID) SwapGUID() UUID {
+	u[0], u[1], u[2], u[3] = u[3], u[2], u[1], u[0]
+	u[4], u[5] = u[5], u[4]
+	u[6], u[7] = u[7], u[6]
+	return u
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (u UUID) MarshalText() ([]byte, error) {
+	b := make([]byte, 36)
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return b, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *UUID) UnmarshalText(text []byte) error {
+	v, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (u UUID) MarshalBinary() ([]byte, error) {
+	return append([]byte(nil), u[:]...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (u *UUID) UnmarshalBinary(data []byte) error {
+	if len(data) != len(u) {
+		return fmt.Errorf("Invalid UUID length %d", len(data))
+	}
+	copy(u[:], data)
+	return nil
+}
+
+// Scan implements sql.Scanner. Sixteen bytes are read as binary,
+// other text is parsed. Null values cannot be scanned, use a pointer.
+func (u *UUID) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return fmt.Errorf("Cannot assign null to rdb.UUID")
+	case UUID:
+		*u = v
+		return nil
+	case [16]byte:
+		*u = v
+		return nil
+	case []byte:
+		if len(v) == len(u) {
+			return u.UnmarshalBinary(v)
+		}
+		return u.UnmarshalText(v)
+	case string:
+		return u.UnmarshalText([]byte(v))
+	}
+	return fmt.Errorf("Cannot convert %T to rdb.UUID", value)
+}
+
+// Value implements driver.Valuer. The UUID is sent as text.
+func (u UUID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kardianos/rdb"
+)
+
+const testUUID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+func mustUUID(t *testing.T, s string) rdb.UUID {
+	t.Helper()
+	u, err := rdb.ParseUUID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestParseUUID(t *testing.T) {
+	list := []struct {
+		in string
+		ok bool
+	}{
+		{testUUID, true},
+		{"0F8FAD5B-D9CB-469F-A165-70867728950E", true},
+		{"{0f8fad5b-d9cb-469f-a165-70867728950e}", true},
+		{"urn:uuid:0f8fad5b-d9cb-469f-a165-70867728950e", true},
+		{" 0f8fad5bd9cb469fa16570867728950e ", true},
+		{"0f8fad5b-d9cb-469f-a165-70867728950", false},
+		{"0f8fad5b-d9cb-469f-a165-70867728950e0", false},
+		{"0f8fad5bxd9cb-469f-a165-70867728950e", false},
+		{"0g8fad5b-d9cb-469f-a165-70867728950e", false},
+		{"{}", false},
+		{"", false},
+	}
+	for _, item := range list {
+		u, err := rdb.ParseUUID(item.in)
+		if !item.ok {
+			if err == nil {
+				t.Errorf("%q: expected error", item.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", item.in, err)
+			continue
+		}
+		if got := u.String(); got != testUUID {
+			t.Errorf("%q: got %s, want %s", item.in, got, testUUID)
+		}
+	}
+}
+
+func TestUUIDScan(t *testing.T) {
+	u := mustUUID(t, testUUID)
+	bin, err := u.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := []struct {
+		name string
+		src  interface{}
+		ok   bool
+	}{
+		{"uuid", u, true},
+		{"array", [16]byte(u), true},
+		{"binary", bin, true},
+		{"text bytes", []byte(testUUID), true},
+		{"string", testUUID, true},
+		{"null", nil, false},
+		{"short bytes", bin[:15], false},
+		{"integer", int64(1), false},
+	}
+	for _, item := range list {
+		var got rdb.UUID
+		err := got.Scan(item.src)
+		if !item.ok {
+			if err == nil {
+				t.Errorf("%s: expected error", item.name)
+			}
+			continue
+		}
+		if err != nil || got != u {
+			t.Errorf("%s: got %s, %v", item.name, got, err)
+		}
+	}
+	if v, err := u.Value(); err != nil || v != testUUID {
+		t.Errorf("value: got %v, %v", v, err)
+	}
+}
+
+func TestUUIDEncoding(t *testing.T) {
+	u := mustUUID(t, testUUID)
+	var back rdb.UUID
+	bin, _ := u.MarshalBinary()
+	if err := back.UnmarshalBinary(bin); err != nil || back != u {
+		t.Errorf("binary: got %s, %v", back, err)
+	}
+	if err := back.UnmarshalBinary(bin[1:]); err == nil {
+		t.Error("binary: expected error for 15 bytes")
+	}
+	data, err := json.Marshal(u)
+	if err != nil || string(data) != `"`+testUUID+`"` {
+		t.Errorf("JSON: got %s, %v", data, err)
+	}
+	back = rdb.UUID{}
+	if err = json.Unmarshal(data, &back); err != nil || back != u {
+		t.Errorf("JSON: got %s, %v", back, err)
+	}
+
+	g := u.SwapGUID()
+	if got, want := g.String(), "5bad8f0f-cbd9-9f46-a165-70867728950e"; got != want {
+		t.Errorf("SwapGUID: got %s, want %s", got, want)
+	}
+	if g.SwapGUID() != u {
+		t.Error("SwapGUID twice did not return the UUID")
+	}
+}
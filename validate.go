@@ -0,0 +1,214 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
+	"unicode/utf8"
+)
+
+// ParamError is returned for a parameter that cannot be resolved or
+// fails validation.
+type ParamError struct {
+	Index int    // Position of the parameter.
+	Name  string // Parameter name, empty if not named.
+	Type  Type   // Parameter type after it is resolved.
+	Err   error  // Reason the parameter is invalid.
+}
+
+// Error returns the error message.
+func (err *ParamError) Error() string {
+	if err.Type == TypeUnknown {
+		return fmt.Sprintf("Parameter %s: %v", paramName(err.Index, err.Name), err.Err)
+	}
+	return fmt.Sprintf("Parameter %s of type %s: %v", paramName(err.Index, err.Name), err.Type, err.Err)
+}
+
+// Unwrap returns the reason the parameter is invalid.
+func (err *ParamError) Unwrap() error {
+	return err.Err
+}
+
+var integerRange = map[Type][2]Numeric{
+	TypeUint8:    {NewNumeric(0, 0), NewNumeric(math.MaxUint8, 0)},
+	TypeUint16:   {NewNumeric(0, 0), NewNumeric(math.MaxUint16, 0)},
+	TypeUint32:   {NewNumeric(0, 0), NewNumeric(math.MaxUint32, 0)},
+	TypeUint64:   {NewNumeric(0, 0), NewNumericBig(new(big.Int).SetUint64(math.MaxUint64), 0)},
+	TypeInt8:     {NewNumeric(math.MinInt8, 0), NewNumeric(math.MaxInt8, 0)},
+	TypeInt16:    {NewNumeric(math.MinInt16, 0), NewNumeric(math.MaxInt16, 0)},
+	TypeInt32:    {NewNumeric(math.MinInt32, 0), NewNumeric(math.MaxInt32, 0)},
+	TypeInt64:    {NewNumeric(math.MinInt64, 0), NewNumeric(math.MaxInt64, 0)},
+	TypeSerial16: {NewNumeric(math.MinInt16, 0), NewNumeric(math.MaxInt16, 0)},
+	TypeSerial32: {NewNumeric(math.MinInt32, 0), NewNumeric(math.MaxInt32, 0)},
+	TypeSerial64: {NewNumeric(math.MinInt64, 0), NewNumeric(math.MaxInt64, 0)},
+}
+
+// ValidateParams checks each input parameter before a command is sent.
+// Drivers call it after ResolveParams if the Command Validate field is set.
+//
+// A nil value, a nil pointer, or a driver.Valuer that returns nil is an
+// error if NotNull is set. Integer values must be whole and in range of
+// the type, and float32 values in range. Decimal values must fit
+// Precision and Scale if Precision is set. Bool and time values must
+// convert to the Go type; time text is parsed as RFC 3339 or a date
+// and time such as "2006-01-02 15:04:05". If Length is positive, text
+// longer than Length characters and binary longer than Length bytes are
+// an error unless truncLongText is set. Generic and driver types are
+// checked as their standard type. Output parameters and io.Reader values
+// are not checked.
+//
+// Each failure is a *ParamError. Multiple failures are returned
+// as an ErrorList.
+func ValidateParams(params []Param, truncLongText bool) error {
+	var list []error
+	for i, p := range params {
+		if p.Out {
+			continue
+		}
+		if err := validateParam(p, truncLongText); err != nil {
+			list = append(list, &ParamError{Index: i, Name: p.Name, Type: p.Type, Err: err})
+		}
+	}
+	switch len(list) {
+	case 0:
+		return nil
+	case 1:
+		return list[0]
+	}
+	return ErrorList{List: list}
+}
+
+func validateParam(p Param, truncLongText bool) error {
+	v, err := checkValue(p.Value)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		if p.NotNull {
+			return fmt.Errorf("Null value not allowed")
+		}
+		return nil
+	}
+	if _, is := v.(io.Reader); is {
+		return nil
+	}
+	t := p.Type.Standard()
+	switch t.GenericOf() {
+	case Integer:
+		var n Numeric
+		if err := n.Scan(v); err != nil {
+			return err
+		}
+		if n.Round(0).Cmp(n) != 0 {
+			return fmt.Errorf("Value %s is not an integer", n)
+		}
+		if r, found := integerRange[t]; found && (n.Cmp(r[0]) < 0 || n.Cmp(r[1]) > 0) {
+			return fmt.Errorf("Value %s out of range [%s, %s]", n, r[0], r[1])
+		}
+	case Float:
+		f, err := asFloat64(v)
+		if err != nil {
+			return err
+		}
+		if t == TypeFloat32 && !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+			return fmt.Errorf("Value %g out of range for float32", f)
+		}
+	case Decimal:
+		var n Numeric
+		if err := n.Scan(v); err != nil {
+			return err
+		}
+		if p.Precision > 0 {
+			if _, err := n.Fit(p.Precision, p.Scale); err != nil {
+				return err
+			}
+		}
+	case Bool:
+		var b bool
+		return convertAssign(reflect.ValueOf(&b).Elem(), v)
+	case Time:
+		switch v := v.(type) {
+		case string:
+			return validateTimeText(t, v)
+		case []byte:
+			return validateTimeText(t, string(v))
+		}
+		if t == TypeDuration {
+			var d time.Duration
+			return convertAssign(reflect.ValueOf(&d).Elem(), v)
+		}
+		var tm time.Time
+		return convertAssign(reflect.ValueOf(&tm).Elem(), v)
+	case Text:
+		if p.Length <= 0 || truncLongText {
+			return nil
+		}
+		var n int
+		switch v := v.(type) {
+		case string:
+			n = utf8.RuneCountInString(v)
+		case []byte:
+			n = utf8.RuneCount(v)
+		default:
+			return nil
+		}
+		if n > p.Length {
+			return fmt.Errorf("Text length %d longer than length %d", n, p.Length)
+		}
+	case Binary:
+		if p.Length <= 0 || truncLongText {
+			return nil
+		}
+		var n int
+		switch v := v.(type) {
+		case string:
+			n = len(v)
+		case []byte:
+			n = len(v)
+		default:
+			return nil
+		}
+		if n > p.Length {
+			return fmt.Errorf("Binary length %d longer than length %d", n, p.Length)
+		}
+	}
+	return nil
+}
+
+// checkValue returns the value to check. Pointers are followed and
+// driver.Valuer values return their driver value. An io.Reader is
+// returned as is, even if it is a pointer.
+func checkValue(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, nil
+	}
+	if valuer, is := v.(driver.Valuer); is {
+		return valuer.Value()
+	}
+	if _, is := v.(io.Reader); is {
+		return v, nil
+	}
+	if rv.Kind() == reflect.Ptr {
+		return checkValue(rv.Elem().Interface())
+	}
+	return v, nil
+}
+
+// validateTimeText checks text sent as a time. Duration text is
+// not checked as its form varies by database.
+func validateTimeText(t Type, s string) error {
+	if t == TypeDuration {
+		return nil
+	}
+	_, err := parseElem(t, s)
+	return err
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package rdb_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/rdb"
+)
+
+func TestValidateParams(t *testing.T) {
+	var nilText *string
+	five := int64(5)
+	list := []struct {
+		name  string
+		param rdb.Param
+		trunc bool
+		err   string // Empty if valid, otherwise the start of the reason.
+	}{
+		{"null", rdb.Param{Type: rdb.TypeInt32}, false, ""},
+		{"not null", rdb.Param{Type: rdb.TypeInt32, NotNull: true}, false, "Null value not allowed"},
+		{"not null pointer", rdb.Param{Type: rdb.TypeText, Value: nilText, NotNull: true}, false, "Null value not allowed"},
+		{"not null valuer", rdb.Param{Type: rdb.TypeInt64, Value: rdb.NullInteger{}, NotNull: true}, false, "Null value not allowed"},
+		{"valuer error", rdb.Param{Type: rdb.TypeInt64, Value: testValuer{errors.New("no value")}}, false, "no value"},
+		{"int8 max", rdb.Param{Type: rdb.TypeInt8, Value: int64(127)}, false, ""},
+		{"int8 over", rdb.Param{Type: rdb.TypeInt8, Value: int64(128)}, false, "Value 128 out of range [-128, 127]"},
+		{"int8 under", rdb.Param{Type: rdb.TypeInt8, Value: -129}, false, "Value -129 out of range"},
+		{"uint8 negative", rdb.Param{Type: rdb.TypeUint8, Value: int64(-1)}, false, "Value -1 out of range [0, 255]"},
+		{"uint64 max", rdb.Param{Type: rdb.TypeUint64, Value: uint64(math.MaxUint64)}, false, ""},
+		{"int32 pointer", rdb.Param{Type: rdb.TypeInt32, Value: &five}, false, ""},
+		{"int32 text", rdb.Param{Type: rdb.TypeInt32, Value: "2147483648"}, false, "Value 2147483648 out of range"},
+		{"integer fraction", rdb.Param{Type: rdb.TypeInt64, Value: 1.5}, false, "Value 1.5 is not an integer"},
+		{"integer whole float", rdb.Param{Type: rdb.TypeInt16, Value: 2.0}, false, ""},
+		{"integer not a number", rdb.Param{Type: rdb.TypeInt64, Value: "x"}, false, "Invalid"},
+		{"generic integer", rdb.Param{Type: rdb.Integer, Value: "9223372036854775808"}, false, "Value 9223372036854775808 out of range"},
+		{"float32 over", rdb.Param{Type: rdb.TypeFloat32, Value: 1e39}, false, "Value 1e+39 out of range for float32"},
+		{"float32 infinity", rdb.Param{Type: rdb.TypeFloat32, Value: math.Inf(1)}, false, ""},
+		{"float64", rdb.Param{Type: rdb.TypeFloat64, Value: 1e39}, false, ""},
+		{"decimal fits", rdb.Param{Type: rdb.TypeDecimal, Value: "123.45", Precision: 5, Scale: 2}, false, ""},
+		{"decimal too large", rdb.Param{Type: rdb.TypeDecimal, Value: "1234.5", Precision: 5, Scale: 2}, false, "Numeric 1234.5 overflows precision 5 scale 2"},
+		{"decimal no precision", rdb.Param{Type: rdb.TypeDecimal, Value: "1234.567"}, false, ""},
+		{"decimal not a number", rdb.Param{Type: rdb.TypeDecimal, Value: "x"}, false, "Invalid"},
+		{"bool", rdb.Param{Type: rdb.TypeBool, Value: "true"}, false, ""},
+		{"bool text", rdb.Param{Type: rdb.TypeBool, Value: "maybe"}, false, `Cannot convert "maybe" to bool`},
+		{"time", rdb.Param{Type: rdb.TypeTimestampz, Value: time.Now()}, false, ""},
+		{"time text", rdb.Param{Type: rdb.TypeTimestamp, Value: "2006-01-02 15:04:05"}, false, ""},
+		{"date text", rdb.Param{Type: rdb.TypeDate, Value: []byte("2006-01-02")}, false, ""},
+		{"time bad text", rdb.Param{Type: rdb.TypeDate, Value: "yesterday"}, false, `Cannot convert "yesterday" to time`},
+		{"time integer", rdb.Param{Type: rdb.TypeTimestampz, Value: int64(1)}, false, "Cannot"},
+		{"duration", rdb.Param{Type: rdb.TypeDuration, Value: time.Second}, false, ""},
+		{"duration text", rdb.Param{Type: rdb.TypeDuration, Value: "1 day"}, false, ""},
+		{"text length", rdb.Param{Type: rdb.TypeVarChar, Value: "ábc", Length: 3}, false, ""},
+		{"text too long", rdb.Param{Type: rdb.TypeVarChar, Value: "abcd", Length: 3}, false, "Text length 4 longer than length 3"},
+		{"text truncated", rdb.Param{Type: rdb.TypeVarChar, Value: "abcd", Length: 3}, true, ""},
+		{"text unlimited", rdb.Param{Type: rdb.TypeText, Value: "abcd", Length: -1}, false, ""},
+		{"binary too long", rdb.Param{Type: rdb.TypeBinary, Value: []byte("ab"), Length: 1}, false, "Binary length 2 longer than length 1"},
+		{"binary truncated", rdb.Param{Type: rdb.TypeBinary, Value: []byte("ab"), Length: 1}, true, ""},
+		{"reader", rdb.Param{Type: rdb.TypeInt32, Value: strings.NewReader("x")}, false, ""},
+		{"output", rdb.Param{Type: rdb.TypeInt8, Value: int64(500), Out: true}, false, ""},
+		{"driver type", rdb.Param{Type: testHstore, Value: "abcd", Length: 2}, false, "Text length 4 longer than length 2"},
+		{"unknown type", rdb.Param{Value: "abcd", Length: 2}, false, ""},
+	}
+	for _, item := range list {
+		err := rdb.ValidateParams([]rdb.Param{{Value: 1}, item.param}, item.trunc)
+		if len(item.err) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", item.name, err)
+			}
+			continue
+		}
+		pe, ok := err.(*rdb.ParamError)
+		if !ok {
+			t.Errorf("%s: got %T %v, want *rdb.ParamError", item.name, err, err)
+			continue
+		}
+		if pe.Index != 1 || pe.Type != item.param.Type {
+			t.Errorf("%s: got index %d type %v", item.name, pe.Index, pe.Type)
+		}
+		if !strings.HasPrefix(pe.Err.Error(), item.err) {
+			t.Errorf("%s: got %q, want %q", item.name, pe.Err, item.err)
+		}
+	}
+}
+
+func TestValidateParamsList(t *testing.T) {
+	err := rdb.ValidateParams([]rdb.Param{
+		{Name: "a", Type: rdb.TypeInt8, Value: 1000},
+		{Name: "b", Type: rdb.TypeInt8, Value: 1},
+		{Type: rdb.TypeText, NotNull: true},
+	}, false)
+	list, ok := err.(rdb.ErrorList)
+	if !ok {
+		t.Fatalf("got %T %v, want rdb.ErrorList", err, err)
+	}
+	want := []string{
+		`Parameter "a" of type TypeInt8: Value 1000 out of range [-128, 127]`,
+		`Parameter 2 of type TypeText: Null value not allowed`,
+	}
+	if len(list.List) != len(want) {
+		t.Fatalf("got %d errors, want %d:\n%v", len(list.List), len(want), err)
+	}
+	for i, w := range want {
+		if got := list.List[i].Error(); got != w {
+			t.Errorf("got %q, want %q", got, w)
+		}
+	}
+	if err = rdb.ValidateParams(nil, false); err != nil {
+		t.Errorf("no params: %v", err)
+	}
+}
+
+func TestParamError(t *testing.T) {
+	reason := errors.New("bad")
+	list := []struct {
+		err  *rdb.ParamError
+		want string
+	}{
+		{&rdb.ParamError{Index: 0, Err: reason}, "Parameter 0: bad"},
+		{&rdb.ParamError{Index: 3, Name: "id", Err: reason}, `Parameter "id": bad`},
+		{&rdb.ParamError{Index: 1, Type: rdb.TypeInt32, Err: reason}, "Parameter 1 of type TypeInt32: bad"},
+	}
+	for _, item := range list {
+		if got := item.err.Error(); got != item.want {
+			t.Errorf("got %q, want %q", got, item.want)
+		}
+		if !errors.Is(item.err, reason) {
+			t.Errorf("%s: errors.Is did not find the reason", item.want)
+		}
+		if item.err.Unwrap() != reason {
+			t.Errorf("%s: Unwrap did not return the reason", item.want)
+		}
+	}
+}